@@ -22,6 +22,7 @@ set -euo pipefail
 
 SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
 PIXIU_CONFIG="${PIXIU_CONFIG:-${SCRIPT_DIR}/pixiu/conf.yaml}"
+FLEET_FILE="${FLEET_FILE:-${SCRIPT_DIR}/fleet.yaml}"
 PIXIU_URL="${PIXIU_URL:-http://127.0.0.1:18888}"
 LMCACHE_ADMIN="${LMCACHE_ADMIN:-http://127.0.0.1:18081}"
 ENGINE_A_ADMIN="${ENGINE_A_ADMIN:-http://127.0.0.1:18091}"
@@ -33,14 +34,10 @@ export NO_PROXY="${NO_PROXY:-127.0.0.1,localhost}"
 export no_proxy="${no_proxy:-127.0.0.1,localhost}"
 
 WORK_DIR="$(mktemp -d /tmp/kvcache-mock-XXXXXX)"
-CONTROLLER_LOG="${WORK_DIR}/mock_controller.log"
-ENGINE_A_LOG="${WORK_DIR}/mock_engine_a.log"
-ENGINE_B_LOG="${WORK_DIR}/mock_engine_b.log"
+MOCK_LOG="${WORK_DIR}/mock_fleet.log"
 PIXIU_LOG="${WORK_DIR}/pixiu.log"
 
-CONTROLLER_PID=""
-ENGINE_A_PID=""
-ENGINE_B_PID=""
+MOCK_PID=""
 PIXIU_PID=""
 
 REQ_BODY='{"model":"mock-model","messages":[{"role":"user","content":"please explain kv cache routing"}]}'
@@ -51,17 +48,9 @@ cleanup() {
     kill "${PIXIU_PID}" >/dev/null 2>&1
     wait "${PIXIU_PID}" >/dev/null 2>&1
   fi
-  if [[ -n "${CONTROLLER_PID}" ]] && kill -0 "${CONTROLLER_PID}" >/dev/null 2>&1; then
-    kill "${CONTROLLER_PID}" >/dev/null 2>&1
-    wait "${CONTROLLER_PID}" >/dev/null 2>&1
-  fi
-  if [[ -n "${ENGINE_A_PID}" ]] && kill -0 "${ENGINE_A_PID}" >/dev/null 2>&1; then
-    kill "${ENGINE_A_PID}" >/dev/null 2>&1
-    wait "${ENGINE_A_PID}" >/dev/null 2>&1
-  fi
-  if [[ -n "${ENGINE_B_PID}" ]] && kill -0 "${ENGINE_B_PID}" >/dev/null 2>&1; then
-    kill "${ENGINE_B_PID}" >/dev/null 2>&1
-    wait "${ENGINE_B_PID}" >/dev/null 2>&1
+  if [[ -n "${MOCK_PID}" ]] && kill -0 "${MOCK_PID}" >/dev/null 2>&1; then
+    kill "${MOCK_PID}" >/dev/null 2>&1
+    wait "${MOCK_PID}" >/dev/null 2>&1
   fi
 }
 trap cleanup EXIT INT TERM
@@ -107,21 +96,9 @@ wait_for_pixiu() {
 start_mocks() {
   (
     cd "${SCRIPT_DIR}"
-    env GOCACHE="${GO_CACHE_DIR}" GOMODCACHE="${GO_MOD_CACHE_DIR}" go run ./server/controller >"${CONTROLLER_LOG}" 2>&1
-  ) &
-  CONTROLLER_PID="$!"
-
-  (
-    cd "${SCRIPT_DIR}"
-    env GOCACHE="${GO_CACHE_DIR}" GOMODCACHE="${GO_MOD_CACHE_DIR}" go run ./server/engine-a >"${ENGINE_A_LOG}" 2>&1
-  ) &
-  ENGINE_A_PID="$!"
-
-  (
-    cd "${SCRIPT_DIR}"
-    env GOCACHE="${GO_CACHE_DIR}" GOMODCACHE="${GO_MOD_CACHE_DIR}" go run ./server/engine-b >"${ENGINE_B_LOG}" 2>&1
+    env GOCACHE="${GO_CACHE_DIR}" GOMODCACHE="${GO_MOD_CACHE_DIR}" go run ./server/app -fleet "${FLEET_FILE}" >"${MOCK_LOG}" 2>&1
   ) &
-  ENGINE_B_PID="$!"
+  MOCK_PID="$!"
 
   wait_for_health "${LMCACHE_ADMIN}/health"
   wait_for_health "${ENGINE_A_ADMIN}/health"
@@ -204,13 +181,11 @@ echo "response#2: ${resp2}"
 echo "controller_stats: ${controller_stats}"
 echo "engine_a_stats: ${engine_a_stats}"
 echo "engine_b_stats: ${engine_b_stats}"
-echo "controller log: ${CONTROLLER_LOG}"
-echo "engine-a log: ${ENGINE_A_LOG}"
-echo "engine-b log: ${ENGINE_B_LOG}"
+echo "mock fleet log: ${MOCK_LOG}"
 echo "pixiu log: ${PIXIU_LOG}"
 
 if [[ "${fail}" -ne 0 ]]; then
   exit 1
 fi
 
-echo "PASS: kvcache mock sample validated with 3 isolated mocks (controller + two engines)."
+echo "PASS: kvcache mock sample validated with the mock fleet (controller + two engines)."
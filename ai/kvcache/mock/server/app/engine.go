@@ -0,0 +1,243 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type engineStats struct {
+	mu sync.Mutex
+
+	tokenizeCalls int
+	chatCalls     int
+	running       int
+	waiting       int
+
+	// inflightTokens are the prompt and completion tokens of the running
+	// requests, which occupy KV memory next to the cached prefixes.
+	inflightTokens int
+	override       loadOverride
+
+	// Per-model series exported on /metrics.
+	modelRunning     map[string]int
+	modelWaiting     map[string]int
+	promptTokens     map[string]int
+	generationTokens map[string]int
+	requestSuccess   map[string]int
+}
+
+func newEngineStats() *engineStats {
+	return &engineStats{
+		modelRunning:     map[string]int{},
+		modelWaiting:     map[string]int{},
+		promptTokens:     map[string]int{},
+		generationTokens: map[string]int{},
+		requestSuccess:   map[string]int{},
+	}
+}
+
+// buildEngineMux serves one mock OpenAI-compatible engine. At most
+// cfg.Capacity chat completions run at a time; the rest wait for a slot.
+// The KV usage on /metrics is read from the controller's index.
+func buildEngineMux(cfg engineConfig, index *kvIndex) http.Handler {
+	stats := newEngineStats()
+	faults := newFaultInjector(cfg.FaultProfile)
+	var slots chan struct{}
+	if cfg.Capacity > 0 {
+		slots = make(chan struct{}, cfg.Capacity)
+	}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "engine": cfg.ID, "models": cfg.Models, "tokenize_enabled": true})
+	})
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, _ *http.Request) {
+		load := stats.load(cfg, index)
+		stats.mu.Lock()
+		defer stats.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]any{
+			"tokenize_calls":       stats.tokenizeCalls,
+			"chat_calls":           stats.chatCalls,
+			"running":              stats.running,
+			"waiting":              stats.waiting,
+			"load":                 load,
+			"engine_id":            cfg.ID,
+			"timestamp_unix_milli": time.Now().UnixMilli(),
+		})
+	})
+	mux.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		stats.mu.Lock()
+		stats.tokenizeCalls = 0
+		stats.chatCalls = 0
+		stats.override = loadOverride{}
+		for _, counters := range []map[string]int{stats.promptTokens, stats.generationTokens, stats.requestSuccess} {
+			clear(counters)
+		}
+		stats.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	})
+	mux.HandleFunc("/fault", faults.handleFault)
+	mux.HandleFunc("/load", stats.handleLoad(cfg, index))
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		stats.writeMetrics(w, cfg, index)
+	})
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, _ *http.Request) {
+		data := make([]map[string]any, 0, len(cfg.Models))
+		for _, model := range cfg.Models {
+			data = append(data, map[string]any{"id": model, "object": "model", "owned_by": cfg.ID})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+	})
+	mux.HandleFunc("/tokenize", func(w http.ResponseWriter, r *http.Request) {
+		if !serveTokenize(w, r, cfg.ChunkSize) {
+			return
+		}
+		stats.mu.Lock()
+		stats.tokenizeCalls++
+		stats.mu.Unlock()
+	})
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		var req llmRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Model == "" {
+			req.Model = cfg.Models[0]
+		}
+		if !cfg.serves(req.Model) {
+			writeJSON(w, http.StatusNotFound, map[string]any{
+				"error": map[string]any{
+					"message": fmt.Sprintf("The model `%s` does not exist.", req.Model),
+					"type":    "NotFoundError",
+					"code":    http.StatusNotFound,
+				},
+			})
+			return
+		}
+
+		content := fmt.Sprintf("mock response from %s", cfg.ID)
+		usage := chatUsage(req, content)
+
+		stats.mu.Lock()
+		stats.chatCalls++
+		stats.mu.Unlock()
+		if !acquireSlot(r, slots, stats, req.Model, usage["total_tokens"]) {
+			return
+		}
+		defer releaseSlot(slots, stats, req.Model, usage["total_tokens"])
+
+		fault := faults.decide()
+		if !fault.apply(w, r) {
+			return
+		}
+		if req.Stream {
+			streamCfg := cfg.Stream
+			streamCfg.firstTokenDelay += cfg.Jitter.sample()
+			if streamChatCompletion(w, r, req, cfg.ID, content, usage, streamCfg, fault.cut) {
+				stats.recordSuccess(req.Model, usage)
+			}
+			return
+		}
+		if delay := cfg.ResponseDelay + cfg.Jitter.sample(); delay > 0 {
+			time.Sleep(delay)
+		}
+
+		resp := llmResponse{
+			ID:       nextEventID("chatcmpl"),
+			Object:   "chat.completion",
+			Model:    req.Model,
+			ServedBy: cfg.ID,
+			Choices: []llmChoice{{
+				Index: 0,
+				Message: llmMessage{
+					Role:    "assistant",
+					Content: content,
+				},
+			}},
+			Usage: usage,
+		}
+		if fault.cut {
+			writeCutJSON(w, resp)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		stats.recordSuccess(req.Model, usage)
+	})
+
+	return mux
+}
+
+// acquireSlot waits for a free running slot, counting the request as
+// waiting meanwhile. It returns false if the client gave up first. A running
+// request holds tokens of KV memory until it is released.
+func acquireSlot(r *http.Request, slots chan struct{}, stats *engineStats, model string, tokens int) bool {
+	stats.mu.Lock()
+	stats.waiting++
+	stats.modelWaiting[model]++
+	stats.mu.Unlock()
+
+	acquired := true
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		case <-r.Context().Done():
+			acquired = false
+		}
+	}
+
+	stats.mu.Lock()
+	stats.waiting--
+	stats.modelWaiting[model]--
+	if acquired {
+		stats.running++
+		stats.modelRunning[model]++
+		stats.inflightTokens += tokens
+	}
+	stats.mu.Unlock()
+	return acquired
+}
+
+func releaseSlot(slots chan struct{}, stats *engineStats, model string, tokens int) {
+	if slots != nil {
+		<-slots
+	}
+	stats.mu.Lock()
+	stats.running--
+	stats.modelRunning[model]--
+	stats.inflightTokens -= tokens
+	stats.mu.Unlock()
+}
+
+func (s *engineStats) recordSuccess(model string, usage map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.promptTokens[model] += usage["prompt_tokens"]
+	s.generationTokens[model] += usage["completion_tokens"]
+	s.requestSuccess[model]++
+}
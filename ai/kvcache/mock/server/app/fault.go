@@ -0,0 +1,321 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// faultProfile describes which failures an engine injects into chat
+// completion requests. Percentages are in the range [0, 100].
+type faultProfile struct {
+	Name string `json:"name"`
+
+	// ErrorPercent of requests are answered with one of ErrorCodes. 429 and
+	// 503 responses carry a Retry-After header of RetryAfterSeconds.
+	ErrorPercent      float64 `json:"error_percent"`
+	ErrorCodes        []int   `json:"error_codes,omitempty"`
+	RetryAfterSeconds int     `json:"retry_after_seconds"`
+
+	// ResetPercent of requests have their TCP connection reset before any
+	// response byte is written.
+	ResetPercent float64 `json:"reset_percent"`
+
+	// CutPercent of responses are disconnected half way through the body:
+	// after a few chunks for streams, after half the JSON otherwise.
+	CutPercent float64 `json:"cut_percent"`
+
+	// Latency adds a random delay drawn from one of the distributions
+	// "fixed", "uniform", "normal" or "exponential".
+	Latency latencyDist `json:"latency"`
+
+	// FirstByteDelayMS holds back the response headers, so the request looks
+	// accepted but slow to first byte.
+	FirstByteDelayMS int `json:"first_byte_delay_ms"`
+}
+
+type latencyDist struct {
+	Distribution string `json:"distribution,omitempty" yaml:"distribution"`
+	MeanMS       int    `json:"mean_ms,omitempty" yaml:"mean_ms"`
+	StddevMS     int    `json:"stddev_ms,omitempty" yaml:"stddev_ms"`
+	MinMS        int    `json:"min_ms,omitempty" yaml:"min_ms"`
+	MaxMS        int    `json:"max_ms,omitempty" yaml:"max_ms"`
+}
+
+// faultPresets are the named profiles selectable via the admin endpoint or
+// the LLM_*_FAULT_PROFILE environment variables.
+var faultPresets = map[string]faultProfile{
+	"none": {Name: "none"},
+	"flaky": {
+		Name:              "flaky",
+		ErrorPercent:      20,
+		ErrorCodes:        []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		RetryAfterSeconds: 1,
+	},
+	"overloaded": {
+		Name:              "overloaded",
+		ErrorPercent:      80,
+		ErrorCodes:        []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+		RetryAfterSeconds: 2,
+	},
+	"down": {
+		Name:         "down",
+		ErrorPercent: 100,
+		ErrorCodes:   []int{http.StatusServiceUnavailable},
+	},
+	"reset": {Name: "reset", ResetPercent: 30},
+	"cut":   {Name: "cut", CutPercent: 30},
+	"jitter": {
+		Name:    "jitter",
+		Latency: latencyDist{Distribution: "normal", MeanMS: 200, StddevMS: 80},
+	},
+	"long-tail": {
+		Name:    "long-tail",
+		Latency: latencyDist{Distribution: "exponential", MeanMS: 300},
+	},
+	"slow-first-byte": {Name: "slow-first-byte", FirstByteDelayMS: 2000},
+}
+
+var defaultErrorCodes = []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable}
+
+// faultDecision is the outcome of rolling a profile for one request.
+type faultDecision struct {
+	delay      time.Duration
+	reset      bool
+	status     int
+	retryAfter int
+	cut        bool
+}
+
+// faultInjector holds the active profile of one engine and counts the
+// faults it injected.
+type faultInjector struct {
+	mu sync.Mutex
+
+	profile  faultProfile
+	injected map[string]int
+}
+
+func newFaultInjector(preset string) *faultInjector {
+	f := &faultInjector{profile: faultPresets["none"], injected: map[string]int{}}
+	if p, ok := faultPresets[preset]; ok {
+		f.profile = p
+	}
+	return f
+}
+
+func (p faultProfile) validate() error {
+	for name, v := range map[string]float64{"error_percent": p.ErrorPercent, "reset_percent": p.ResetPercent, "cut_percent": p.CutPercent} {
+		if v < 0 || v > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", name)
+		}
+	}
+	for _, code := range p.ErrorCodes {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("error code %d is not an HTTP error status", code)
+		}
+	}
+	return p.Latency.validate()
+}
+
+func (d latencyDist) validate() error {
+	switch d.Distribution {
+	case "", "fixed", "uniform", "normal", "exponential":
+	default:
+		return fmt.Errorf("unknown latency distribution %q", d.Distribution)
+	}
+	if d.MinMS > d.MaxMS && d.Distribution == "uniform" {
+		return fmt.Errorf("latency min_ms must not exceed max_ms")
+	}
+	return nil
+}
+
+func (d latencyDist) sample() time.Duration {
+	var ms float64
+	switch d.Distribution {
+	case "fixed":
+		ms = float64(d.MeanMS)
+	case "uniform":
+		ms = float64(d.MinMS) + rand.Float64()*float64(d.MaxMS-d.MinMS)
+	case "normal":
+		ms = float64(d.MeanMS) + rand.NormFloat64()*float64(d.StddevMS)
+	case "exponential":
+		ms = rand.ExpFloat64() * float64(d.MeanMS)
+	}
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+func rollPercent(p float64) bool {
+	return p > 0 && rand.Float64()*100 < p
+}
+
+// decide rolls the active profile for one request. At most one of reset,
+// status and cut is set.
+func (f *faultInjector) decide() faultDecision {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := f.profile
+	d := faultDecision{delay: time.Duration(p.FirstByteDelayMS)*time.Millisecond + p.Latency.sample()}
+	switch {
+	case rollPercent(p.ResetPercent):
+		d.reset = true
+		f.injected["reset"]++
+	case rollPercent(p.ErrorPercent):
+		codes := p.ErrorCodes
+		if len(codes) == 0 {
+			codes = defaultErrorCodes
+		}
+		d.status = codes[rand.IntN(len(codes))]
+		if d.status == http.StatusTooManyRequests || d.status == http.StatusServiceUnavailable {
+			d.retryAfter = p.RetryAfterSeconds
+		}
+		f.injected[strconv.Itoa(d.status)]++
+	case rollPercent(p.CutPercent):
+		d.cut = true
+		f.injected["cut"]++
+	}
+	return d
+}
+
+// apply carries out the parts of d that end the request early. It returns
+// false when the handler must not write a normal response.
+func (d faultDecision) apply(w http.ResponseWriter, r *http.Request) bool {
+	if !sleepOrDone(r, d.delay) {
+		return false
+	}
+	if d.reset {
+		resetConnection(w)
+		return false
+	}
+	if d.status != 0 {
+		if d.retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(d.retryAfter))
+		}
+		writeJSON(w, d.status, map[string]any{
+			"error": map[string]any{
+				"message": fmt.Sprintf("injected fault: %s", http.StatusText(d.status)),
+				"type":    "mock_fault",
+				"code":    d.status,
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// resetConnection closes the client connection with SO_LINGER=0 so the peer
+// observes a TCP RST instead of a clean close.
+func resetConnection(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+// writeCutJSON announces the full body length but sends only half of it
+// before aborting the connection.
+func writeCutJSON(w http.ResponseWriter, payload any) {
+	data, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data[:len(data)/2])
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// handleFault serves the per-engine fault admin endpoint. GET shows the
+// active profile, presets and counters; POST/PUT switches to a preset
+// ({"preset": "flaky"}) or a custom profile; DELETE restores "none".
+func (f *faultInjector) handleFault(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		var req struct {
+			Preset string `json:"preset"`
+			faultProfile
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+			return
+		}
+		profile := req.faultProfile
+		if req.Preset != "" {
+			preset, ok := faultPresets[req.Preset]
+			if !ok {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown preset %q", req.Preset)})
+				return
+			}
+			profile = preset
+		} else if profile.Name == "" {
+			profile.Name = "custom"
+		}
+		if err := profile.validate(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		f.mu.Lock()
+		f.profile = profile
+		f.injected = map[string]int{}
+		f.mu.Unlock()
+	case http.MethodDelete:
+		f.mu.Lock()
+		f.profile = faultPresets["none"]
+		f.injected = map[string]int{}
+		f.mu.Unlock()
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	presets := make([]string, 0, len(faultPresets))
+	for name := range faultPresets {
+		presets = append(presets, name)
+	}
+	sort.Strings(presets)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"profile":  f.profile,
+		"presets":  presets,
+		"injected": f.injected,
+	})
+}
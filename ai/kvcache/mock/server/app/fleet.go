@@ -0,0 +1,481 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultModel        = "mock-model"
+	defaultHost         = "127.0.0.1"
+	defaultClusterName  = "mock_llm"
+	defaultLBPolicy     = "round_robin"
+	defaultRetryPolicy  = "NoRetry"
+	defaultCapacity     = 8
+	defaultKVCapacity   = 4096
+	defaultChunkSize    = 256
+	defaultDelayMS      = 150
+	defaultTokenRate    = 20
+	defaultControllerAt = ":18081"
+)
+
+// fleetSpec is the YAML description of a mock fleet: one controller and any
+// number of engines, each serving one or more models.
+type fleetSpec struct {
+	Controller controllerSpec `yaml:"controller"`
+	// Defaults fills in every field an engine leaves unset.
+	Defaults engineSpec   `yaml:"defaults"`
+	Engines  []engineSpec `yaml:"engines"`
+	Pixiu    pixiuSpec    `yaml:"pixiu"`
+}
+
+type controllerSpec struct {
+	Addr      string `yaml:"addr"`
+	ChunkSize int    `yaml:"chunk_size"`
+	// Preferred is the engine /lookup reports when no engine caches any
+	// prefix of the request, so that routing a cold cache is deterministic.
+	Preferred string `yaml:"preferred"`
+}
+
+// engineSpec describes one engine, or Replicas engines named <id>-0 ...
+// <id>-N-1 listening on consecutive ports starting at Port.
+type engineSpec struct {
+	ID       string   `yaml:"id"`
+	Replicas int      `yaml:"replicas"`
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Models   []string `yaml:"models"`
+	// Capacity is the number of requests the engine runs concurrently;
+	// further requests wait for a free slot. Zero means unlimited.
+	Capacity int `yaml:"capacity"`
+	// KVCapacityTokens is the KV memory size tracked by the controller.
+	KVCapacityTokens int            `yaml:"kv_capacity_tokens"`
+	Location         string         `yaml:"location"`
+	Latency          latencyProfile `yaml:"latency"`
+	FaultProfile     string         `yaml:"fault_profile"`
+}
+
+type latencyProfile struct {
+	ResponseDelayMS   *int        `yaml:"response_delay_ms"`
+	FirstTokenDelayMS *int        `yaml:"first_token_delay_ms"`
+	TokenRate         int         `yaml:"token_rate"`
+	Jitter            latencyDist `yaml:"jitter"`
+}
+
+type pixiuSpec struct {
+	// Cluster is the cluster name for a single-model fleet and the prefix
+	// of the per-model cluster names otherwise.
+	Cluster     string `yaml:"cluster"`
+	LBPolicy    string `yaml:"lb_policy"`
+	RetryPolicy string `yaml:"retry_policy"`
+	RetryTimes  int    `yaml:"retry_times"`
+}
+
+// engineConfig is a fully resolved engine of the fleet.
+type engineConfig struct {
+	ID               string
+	Host             string
+	Port             int
+	Models           []string
+	Capacity         int
+	KVCapacityTokens int
+	Location         string
+	ResponseDelay    time.Duration
+	Stream           streamConfig
+	Jitter           latencyDist
+	FaultProfile     string
+	ChunkSize        int
+}
+
+func (e engineConfig) addr() string {
+	return ":" + strconv.Itoa(e.Port)
+}
+
+func (e engineConfig) serves(model string) bool {
+	return containsModel(e.Models, model)
+}
+
+func loadFleet(path string) (*fleetSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fleet spec: %w", err)
+	}
+	spec := &fleetSpec{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(spec); err != nil {
+		return nil, fmt.Errorf("parse fleet spec %s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// defaultFleet is the controller plus the two engines the sample has always
+// shipped with, configured through the environment.
+func defaultFleet() *fleetSpec {
+	responseDelay := int(envDurationMSOrDefault("MOCK_LLM_RESPONSE_DELAY_MS", defaultDelayMS) / time.Millisecond)
+	firstTokenDelay := int(envDurationMSOrDefault("MOCK_LLM_FIRST_TOKEN_DELAY_MS", defaultDelayMS) / time.Millisecond)
+	return &fleetSpec{
+		Controller: controllerSpec{
+			Addr:      envOrDefault("LMCACHE_ADDR", defaultControllerAt),
+			ChunkSize: envIntOrDefault("LMCACHE_CHUNK_SIZE", defaultChunkSize),
+			Preferred: envOrDefault("PREFERRED_ENDPOINT_ID", envOrDefault("LLM_B_ID", "mock-llm-b")),
+		},
+		Defaults: engineSpec{
+			Models:           []string{defaultModel},
+			Capacity:         envIntOrDefault("MOCK_LLM_CAPACITY", defaultCapacity),
+			KVCapacityTokens: envIntOrDefault("LMCACHE_CAPACITY_TOKENS", defaultKVCapacity),
+			Latency: latencyProfile{
+				ResponseDelayMS:   &responseDelay,
+				FirstTokenDelayMS: &firstTokenDelay,
+				TokenRate:         envIntOrDefault("MOCK_LLM_TOKEN_RATE", defaultTokenRate),
+			},
+		},
+		Engines: []engineSpec{
+			{
+				ID:           envOrDefault("LLM_A_ID", "mock-llm-a"),
+				Port:         portOf(envOrDefault("LLM_A_ADDR", ":18091")),
+				Location:     "ram-a",
+				FaultProfile: os.Getenv("LLM_A_FAULT_PROFILE"),
+			},
+			{
+				ID:           envOrDefault("LLM_B_ID", "mock-llm-b"),
+				Port:         portOf(envOrDefault("LLM_B_ADDR", ":18092")),
+				Location:     "ram-b",
+				FaultProfile: os.Getenv("LLM_B_FAULT_PROFILE"),
+			},
+		},
+	}
+}
+
+func portOf(addr string) int {
+	port, _ := strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+	return port
+}
+
+// engines expands replicas, applies defaults and validates the result.
+func (f *fleetSpec) engines() ([]engineConfig, error) {
+	if len(f.Engines) == 0 {
+		return nil, fmt.Errorf("fleet has no engines")
+	}
+	chunkSize := f.Controller.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	var out []engineConfig
+	ids := map[string]bool{}
+	ports := map[int]string{}
+	for i, spec := range f.Engines {
+		spec = spec.withDefaults(f.Defaults)
+		if spec.ID == "" {
+			return nil, fmt.Errorf("engine #%d has no id", i)
+		}
+		if spec.Port <= 0 || spec.Port > 65535 {
+			return nil, fmt.Errorf("engine %s has invalid port %d", spec.ID, spec.Port)
+		}
+		if err := spec.Latency.Jitter.validate(); err != nil {
+			return nil, fmt.Errorf("engine %s: %w", spec.ID, err)
+		}
+		if _, ok := faultPresets[spec.FaultProfile]; spec.FaultProfile != "" && !ok {
+			return nil, fmt.Errorf("engine %s: unknown fault profile %q", spec.ID, spec.FaultProfile)
+		}
+
+		replicas := max(spec.Replicas, 1)
+		for r := 0; r < replicas; r++ {
+			cfg := spec.resolve(chunkSize)
+			if spec.Replicas > 0 {
+				cfg.ID = fmt.Sprintf("%s-%d", spec.ID, r)
+				cfg.Port = spec.Port + r
+				if spec.Location != "" {
+					cfg.Location = fmt.Sprintf("%s-%d", spec.Location, r)
+				} else {
+					cfg.Location = "ram-" + cfg.ID
+				}
+			}
+			if ids[cfg.ID] {
+				return nil, fmt.Errorf("duplicate engine id %s", cfg.ID)
+			}
+			if other, ok := ports[cfg.Port]; ok {
+				return nil, fmt.Errorf("engines %s and %s share port %d", other, cfg.ID, cfg.Port)
+			}
+			ids[cfg.ID] = true
+			ports[cfg.Port] = cfg.ID
+			out = append(out, cfg)
+		}
+	}
+	if p := f.Controller.Preferred; p != "" && !ids[p] {
+		return nil, fmt.Errorf("controller.preferred %s is not an engine", p)
+	}
+	return out, nil
+}
+
+func (s engineSpec) withDefaults(d engineSpec) engineSpec {
+	if s.Host == "" {
+		s.Host = d.Host
+	}
+	if len(s.Models) == 0 {
+		s.Models = d.Models
+	}
+	if s.Capacity == 0 {
+		s.Capacity = d.Capacity
+	}
+	if s.KVCapacityTokens == 0 {
+		s.KVCapacityTokens = d.KVCapacityTokens
+	}
+	if s.Latency.ResponseDelayMS == nil {
+		s.Latency.ResponseDelayMS = d.Latency.ResponseDelayMS
+	}
+	if s.Latency.FirstTokenDelayMS == nil {
+		s.Latency.FirstTokenDelayMS = d.Latency.FirstTokenDelayMS
+	}
+	if s.Latency.TokenRate == 0 {
+		s.Latency.TokenRate = d.Latency.TokenRate
+	}
+	if s.Latency.Jitter.Distribution == "" {
+		s.Latency.Jitter = d.Latency.Jitter
+	}
+	if s.FaultProfile == "" {
+		s.FaultProfile = d.FaultProfile
+	}
+	return s
+}
+
+func (s engineSpec) resolve(chunkSize int) engineConfig {
+	cfg := engineConfig{
+		ID:               s.ID,
+		Host:             s.Host,
+		Port:             s.Port,
+		Models:           s.Models,
+		Capacity:         s.Capacity,
+		KVCapacityTokens: s.KVCapacityTokens,
+		Location:         s.Location,
+		Jitter:           s.Latency.Jitter,
+		FaultProfile:     s.FaultProfile,
+		ChunkSize:        chunkSize,
+	}
+	if cfg.Host == "" {
+		cfg.Host = defaultHost
+	}
+	if len(cfg.Models) == 0 {
+		cfg.Models = []string{defaultModel}
+	}
+	if cfg.KVCapacityTokens <= 0 {
+		cfg.KVCapacityTokens = defaultKVCapacity
+	}
+	if cfg.Location == "" {
+		cfg.Location = "ram-" + s.ID
+	}
+	responseDelay, firstTokenDelay, tokenRate := defaultDelayMS, defaultDelayMS, defaultTokenRate
+	if s.Latency.ResponseDelayMS != nil {
+		responseDelay = *s.Latency.ResponseDelayMS
+	}
+	if s.Latency.FirstTokenDelayMS != nil {
+		firstTokenDelay = *s.Latency.FirstTokenDelayMS
+	}
+	if s.Latency.TokenRate > 0 {
+		tokenRate = s.Latency.TokenRate
+	}
+	cfg.ResponseDelay = time.Duration(responseDelay) * time.Millisecond
+	cfg.Stream = newStreamConfig(time.Duration(firstTokenDelay)*time.Millisecond, tokenRate)
+	return cfg
+}
+
+// writeClusters renders the Pixiu clusters block for the fleet, indented to
+// sit under static_resources. A single-model fleet yields one cluster named
+// pixiu.cluster; otherwise there is one cluster per model, named
+// <pixiu.cluster>_<model>, holding every engine that serves the model.
+func (f *fleetSpec) writeClusters(engines []engineConfig) string {
+	lbPolicy := f.Pixiu.LBPolicy
+	if lbPolicy == "" {
+		lbPolicy = defaultLBPolicy
+	}
+	retryPolicy := f.Pixiu.RetryPolicy
+	if retryPolicy == "" {
+		retryPolicy = defaultRetryPolicy
+	}
+
+	models, names := f.clusters(engines)
+	var b strings.Builder
+	b.WriteString("  clusters:\n")
+	for i, model := range models {
+		name := names[i]
+		fmt.Fprintf(&b, "    - name: %q\n", name)
+		fmt.Fprintf(&b, "      lb_policy: %q\n", lbPolicy)
+		b.WriteString("      endpoints:\n")
+		for _, e := range engines {
+			if !e.serves(model) {
+				continue
+			}
+			fmt.Fprintf(&b, "        - ID: %q\n", e.ID)
+			b.WriteString("          socket_address:\n")
+			fmt.Fprintf(&b, "            address: %q\n", e.Host)
+			fmt.Fprintf(&b, "            port: %d\n", e.Port)
+			b.WriteString("          llm_meta:\n")
+			b.WriteString("            retry_policy:\n")
+			fmt.Fprintf(&b, "              name: %q\n", retryPolicy)
+			if f.Pixiu.RetryTimes > 0 {
+				fmt.Fprintf(&b, "              times: %d\n", f.Pixiu.RetryTimes)
+			}
+		}
+	}
+	return b.String()
+}
+
+// clusters returns the models the fleet serves and the Pixiu cluster name of
+// each.
+func (f *fleetSpec) clusters(engines []engineConfig) ([]string, []string) {
+	prefix := f.Pixiu.Cluster
+	if prefix == "" {
+		prefix = defaultClusterName
+	}
+	var models []string
+	for _, e := range engines {
+		for _, m := range e.Models {
+			if !containsModel(models, m) {
+				models = append(models, m)
+			}
+		}
+	}
+	names := make([]string, len(models))
+	for i, model := range models {
+		names[i] = prefix
+		if len(models) > 1 {
+			names[i] = prefix + "_" + clusterSafe(model)
+		}
+	}
+	return models, names
+}
+
+var (
+	confRouteCluster = regexp.MustCompile(`(?m)^\s+cluster:\s*"?([^"\s]+)"?\s*$`)
+	confEndpoint     = regexp.MustCompile(`(?m)^\s+(vllm_endpoint|lmcache_endpoint):\s*"?([^"\s]+)"?\s*$`)
+)
+
+// syncClusters replaces the clusters block of the Pixiu config at path with
+// the one rendered for the fleet, leaving the rest of the file untouched. It
+// refuses to write a config whose routes or kvcache endpoints would not match
+// the fleet, as Pixiu would then answer every request from a missing cluster.
+func (f *fleetSpec) syncClusters(path string, engines []engineConfig) error {
+	if models, names := f.clusters(engines); len(models) > 1 {
+		return fmt.Errorf("the fleet serves %d models and needs one route per cluster (%s), which Pixiu cannot select by model; "+
+			"print the clusters with -emit-clusters and write the routes by hand", len(models), strings.Join(names, ", "))
+	}
+	clusters := f.writeClusters(engines)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.TrimRight(line, "\r\n") == "  clusters:" {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return fmt.Errorf("%s has no static_resources.clusters block", path)
+	}
+	end := start + 1
+	for end < len(lines) {
+		line := strings.TrimRight(lines[end], "\r\n")
+		if line != "" && !strings.HasPrefix(line, "   ") && !strings.HasPrefix(line, "  -") {
+			break
+		}
+		end++
+	}
+	// Keep the blank lines that separated the block from the next key.
+	for end > start+1 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+
+	out := strings.Join(lines[:start], "") + clusters + strings.Join(lines[end:], "")
+	if err := f.checkConf(out, engines); err != nil {
+		return fmt.Errorf("%s does not match the fleet: %w", path, err)
+	}
+	return os.WriteFile(path, []byte(out), 0o644)
+}
+
+// checkConf verifies that every route of a Pixiu config targets a cluster of
+// the fleet, that vllm_endpoint is one of its engines and that
+// lmcache_endpoint is its controller.
+func (f *fleetSpec) checkConf(conf string, engines []engineConfig) error {
+	_, names := f.clusters(engines)
+	for _, m := range confRouteCluster.FindAllStringSubmatch(conf, -1) {
+		if !containsModel(names, m[1]) {
+			return fmt.Errorf("route cluster %q is not one of %s", m[1], strings.Join(names, ", "))
+		}
+	}
+	controllerAddr := f.Controller.Addr
+	if controllerAddr == "" {
+		controllerAddr = defaultControllerAt
+	}
+	for _, m := range confEndpoint.FindAllStringSubmatch(conf, -1) {
+		u, err := url.Parse(m[2])
+		if err != nil {
+			return fmt.Errorf("%s: %w", m[1], err)
+		}
+		port, _ := strconv.Atoi(u.Port())
+		switch {
+		case m[1] == "lmcache_endpoint" && port != portOf(controllerAddr):
+			return fmt.Errorf("lmcache_endpoint %s is not the controller at %s", m[2], controllerAddr)
+		case m[1] == "vllm_endpoint" && !hasEnginePort(engines, port):
+			return fmt.Errorf("vllm_endpoint %s is not an engine of the fleet", m[2])
+		}
+	}
+	return nil
+}
+
+func hasEnginePort(engines []engineConfig, port int) bool {
+	for _, e := range engines {
+		if e.Port == port {
+			return true
+		}
+	}
+	return false
+}
+
+func clusterSafe(model string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, model)
+}
+
+func containsModel(models []string, model string) bool {
+	for _, m := range models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
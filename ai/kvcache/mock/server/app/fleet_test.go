@@ -0,0 +1,145 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFleetMatchesPixiuConf fails when pixiu/conf.yaml drifts from
+// fleet.yaml; fix it with -sync-conf.
+func TestFleetMatchesPixiuConf(t *testing.T) {
+	fleet, err := loadFleet("../../fleet.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engines, err := fleet.engines()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := os.ReadFile("../../pixiu/conf.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	synced := filepath.Join(t.TempDir(), "conf.yaml")
+	if err := os.WriteFile(synced, conf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fleet.syncClusters(synced, engines); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(synced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(conf) {
+		t.Fatalf("pixiu/conf.yaml clusters do not match fleet.yaml; run: go run ./server/app -fleet fleet.yaml -sync-conf pixiu/conf.yaml")
+	}
+}
+
+// TestSyncClustersRefusesMismatch checks that -sync-conf leaves the config
+// alone when its route or kvcache endpoints would not match the fleet.
+func TestSyncClustersRefusesMismatch(t *testing.T) {
+	conf, err := os.ReadFile("../../pixiu/conf.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "conf.yaml")
+	if err := os.WriteFile(path, conf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	large, err := loadFleet("../../fleet-large.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engines, err := large.engines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := large.syncClusters(path, engines); err == nil || !strings.Contains(err.Error(), "2 models") {
+		t.Fatalf("expected a multi-model fleet to be refused, got %v", err)
+	}
+
+	elsewhere := &fleetSpec{Engines: []engineSpec{{ID: "x", Port: 19000}}}
+	if engines, err = elsewhere.engines(); err != nil {
+		t.Fatal(err)
+	}
+	if err := elsewhere.syncClusters(path, engines); err == nil || !strings.Contains(err.Error(), "vllm_endpoint") {
+		t.Fatalf("expected a vllm_endpoint outside the fleet to be refused, got %v", err)
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != string(conf) {
+		t.Fatal("a refused sync changed the config")
+	}
+}
+
+func TestFleetEngines(t *testing.T) {
+	delay := 0
+	fleet := &fleetSpec{
+		Defaults: engineSpec{
+			Models:   []string{"small"},
+			Capacity: 4,
+			Latency:  latencyProfile{ResponseDelayMS: &delay, TokenRate: 50},
+		},
+		Engines: []engineSpec{
+			{ID: "s", Replicas: 3, Port: 19000},
+			{ID: "big", Port: 19100, Models: []string{"small", "large"}, Capacity: 1, KVCapacityTokens: 100},
+		},
+	}
+	engines, err := fleet.engines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(engines) != 4 {
+		t.Fatalf("expected 4 engines, got %d", len(engines))
+	}
+	if engines[2].ID != "s-2" || engines[2].Port != 19002 || engines[2].Capacity != 4 || engines[2].Location != "ram-s-2" {
+		t.Fatalf("unexpected replica: %+v", engines[2])
+	}
+	if engines[0].ResponseDelay != 0 || engines[0].Stream.tokenInterval != 20*time.Millisecond {
+		t.Fatalf("defaults not applied: %+v", engines[0])
+	}
+	if engines[3].Capacity != 1 || engines[3].KVCapacityTokens != 100 || engines[3].Location != "ram-big" {
+		t.Fatalf("engine overrides not kept: %+v", engines[3])
+	}
+
+	clusters := fleet.writeClusters(engines)
+	if !strings.Contains(clusters, `name: "mock_llm_small"`) || !strings.Contains(clusters, `name: "mock_llm_large"`) {
+		t.Fatalf("expected one cluster per model:\n%s", clusters)
+	}
+	if n := strings.Count(clusters, `ID: "big"`); n != 2 {
+		t.Fatalf("expected the two-model engine in both clusters, found %d times", n)
+	}
+
+	fleet.Controller.Preferred = "missing"
+	if _, err := fleet.engines(); err == nil {
+		t.Fatal("expected an unknown preferred engine to be rejected")
+	}
+	fleet.Controller.Preferred = "big"
+
+	fleet.Engines = append(fleet.Engines, engineSpec{ID: "dup", Port: 19001})
+	if _, err := fleet.engines(); err == nil {
+		t.Fatal("expected a port conflict to be rejected")
+	}
+}
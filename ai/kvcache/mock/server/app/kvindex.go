@@ -0,0 +1,363 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// compressRatio is how many cached tokens fit into one unit of capacity once
+// an entry has been compressed.
+const compressRatio = 2
+
+var errCapacityExhausted = errors.New("not enough cache capacity")
+
+// kvIndex simulates the controller's view of which token prefixes every
+// engine holds in its KV cache. Each engine has a token capacity; unpinned
+// entries are evicted in LRU order when a new prefix does not fit.
+type kvIndex struct {
+	mu sync.Mutex
+
+	defaultCapacity int
+	configs         map[string]cacheConfig
+	engines         map[string]*engineCache
+	lruEvicts       int
+}
+
+// cacheConfig is the KV memory layout of one engine.
+type cacheConfig struct {
+	location string
+	capacity int
+}
+
+type engineCache struct {
+	lru      *list.List // of *cacheEntry, most recently used first
+	used     int
+	capacity int
+}
+
+type cacheEntry struct {
+	tokens     []int
+	location   string
+	pinned     bool
+	method     string
+	lastAccess time.Time
+	hits       int
+}
+
+type cacheEntryState struct {
+	Tokens           []int  `json:"tokens"`
+	NumTokens        int    `json:"num_tokens"`
+	Size             int    `json:"size"`
+	Location         string `json:"location"`
+	Pinned           bool   `json:"pinned"`
+	Compressed       bool   `json:"compressed"`
+	Method           string `json:"method,omitempty"`
+	Hits             int    `json:"hits"`
+	LastAccessUnixMs int64  `json:"last_access_unix_milli"`
+}
+
+type engineCacheState struct {
+	Capacity  int               `json:"capacity"`
+	UsedSize  int               `json:"used_size"`
+	NumTokens int               `json:"num_tokens"`
+	Entries   []cacheEntryState `json:"entries"`
+}
+
+// newKVIndex creates an index for the given engines. Engines that are not
+// configured but show up in requests may hold up to defaultCapacity tokens.
+func newKVIndex(defaultCapacity int, configs map[string]cacheConfig) *kvIndex {
+	idx := &kvIndex{defaultCapacity: defaultCapacity, configs: configs}
+	idx.reset()
+	return idx
+}
+
+func (idx *kvIndex) reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.engines = make(map[string]*engineCache, len(idx.configs))
+	for id := range idx.configs {
+		idx.engine(id)
+	}
+	idx.lruEvicts = 0
+}
+
+// engine returns the cache of the given engine, creating it on first use so
+// that instance IDs outside the default pair can be exercised as well.
+func (idx *kvIndex) engine(id string) *engineCache {
+	ec, ok := idx.engines[id]
+	if !ok {
+		ec = &engineCache{lru: list.New(), capacity: idx.defaultCapacity}
+		if cfg, ok := idx.configs[id]; ok && cfg.capacity > 0 {
+			ec.capacity = cfg.capacity
+		}
+		idx.engines[id] = ec
+	}
+	return ec
+}
+
+func (e *cacheEntry) size() int {
+	if e.method != "" {
+		return (len(e.tokens) + compressRatio - 1) / compressRatio
+	}
+	return len(e.tokens)
+}
+
+// lookup returns, for every engine holding at least one matching token, the
+// length of the longest cached prefix of tokens and its location. The entry
+// that produced the hit is moved to the front of the engine's LRU list.
+func (idx *kvIndex) lookup(tokens []int) map[string]map[string]any {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	now := time.Now()
+	layout := make(map[string]map[string]any)
+	for id, ec := range idx.engines {
+		var best *list.Element
+		bestLen := 0
+		for el := ec.lru.Front(); el != nil; el = el.Next() {
+			if n := commonPrefixLen(el.Value.(*cacheEntry).tokens, tokens); n > bestLen {
+				best, bestLen = el, n
+			}
+		}
+		if best == nil {
+			continue
+		}
+		entry := best.Value.(*cacheEntry)
+		entry.hits++
+		entry.lastAccess = now
+		ec.lru.MoveToFront(best)
+		layout[id] = map[string]any{"0": entry.location, "1": bestLen}
+	}
+	return layout
+}
+
+// admit records that engine id now caches tokens. An existing entry that is
+// a prefix of tokens is replaced; one that already covers tokens is only
+// touched. It returns the admitted entry.
+func (idx *kvIndex) admit(id string, tokens []int, location string) (*cacheEntry, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.admitLocked(id, tokens, location)
+}
+
+func (idx *kvIndex) admitLocked(id string, tokens []int, location string) (*cacheEntry, error) {
+	ec := idx.engine(id)
+	now := time.Now()
+	if location == "" {
+		location = idx.configs[id].location
+	}
+
+	entry := &cacheEntry{tokens: append([]int(nil), tokens...), location: location, lastAccess: now}
+	var replaced []*list.Element
+	for el := ec.lru.Front(); el != nil; el = el.Next() {
+		existing := el.Value.(*cacheEntry)
+		switch {
+		case hasTokenPrefix(existing.tokens, tokens):
+			existing.lastAccess = now
+			ec.lru.MoveToFront(el)
+			return existing, nil
+		case hasTokenPrefix(tokens, existing.tokens):
+			replaced = append(replaced, el)
+		}
+	}
+
+	// Refuse before touching the cache, so a failed admission neither drops
+	// the replaced entries nor evicts anything.
+	if !ec.fits(entry.size(), replaced) {
+		return nil, errCapacityExhausted
+	}
+	for _, el := range replaced {
+		existing := el.Value.(*cacheEntry)
+		entry.pinned = entry.pinned || existing.pinned
+		entry.hits += existing.hits
+		ec.used -= existing.size()
+		ec.lru.Remove(el)
+	}
+	idx.makeRoom(ec, entry.size())
+	ec.lru.PushFront(entry)
+	ec.used += entry.size()
+	return entry, nil
+}
+
+// fits reports whether need more units fit into the engine's capacity once
+// the replaced entries are gone and every unpinned entry may be evicted.
+func (ec *engineCache) fits(need int, replaced []*list.Element) bool {
+	pinned := 0
+	for el := ec.lru.Front(); el != nil; el = el.Next() {
+		if entry := el.Value.(*cacheEntry); entry.pinned {
+			pinned += entry.size()
+		}
+	}
+	for _, el := range replaced {
+		if entry := el.Value.(*cacheEntry); entry.pinned {
+			pinned -= entry.size()
+		}
+	}
+	return pinned+need <= ec.capacity
+}
+
+// makeRoom evicts unpinned entries from the back of the LRU list until need
+// more units fit into the engine's capacity. Callers check fits first.
+func (idx *kvIndex) makeRoom(ec *engineCache, need int) {
+	for el := ec.lru.Back(); el != nil && ec.used+need > ec.capacity; {
+		prev := el.Prev()
+		if entry := el.Value.(*cacheEntry); !entry.pinned {
+			ec.used -= entry.size()
+			ec.lru.Remove(el)
+			idx.lruEvicts++
+		}
+		el = prev
+	}
+}
+
+// pin protects the entry covering tokens on engine id from LRU eviction,
+// admitting it first when the engine does not hold it yet.
+func (idx *kvIndex) pin(id string, tokens []int, location string) (int, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	entry, err := idx.admitLocked(id, tokens, location)
+	if err != nil {
+		return 0, err
+	}
+	entry.pinned = true
+	if location != "" {
+		entry.location = location
+	}
+	return len(tokens), nil
+}
+
+// compress shrinks every entry on engine id that starts with tokens and
+// returns the number of cached tokens affected.
+func (idx *kvIndex) compress(id string, tokens []int, method string, location string) int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if method == "" {
+		method = "default"
+	}
+	ec := idx.engine(id)
+	affected := 0
+	for el := ec.lru.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*cacheEntry)
+		if !hasTokenPrefix(entry.tokens, tokens) || entry.method != "" {
+			continue
+		}
+		before := entry.size()
+		entry.method = method
+		if location != "" {
+			entry.location = location
+		}
+		ec.used -= before - entry.size()
+		affected += len(entry.tokens)
+	}
+	return affected
+}
+
+// evict drops every unpinned entry that starts with tokens. An empty id
+// applies the eviction to all engines. It returns the number of tokens
+// removed.
+func (idx *kvIndex) evict(id string, tokens []int) int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	targets := idx.engines
+	if id != "" {
+		targets = map[string]*engineCache{id: idx.engine(id)}
+	}
+	removed := 0
+	for _, ec := range targets {
+		for el := ec.lru.Front(); el != nil; {
+			next := el.Next()
+			entry := el.Value.(*cacheEntry)
+			if !entry.pinned && hasTokenPrefix(entry.tokens, tokens) {
+				ec.used -= entry.size()
+				ec.lru.Remove(el)
+				removed += len(entry.tokens)
+			}
+			el = next
+		}
+	}
+	return removed
+}
+
+// snapshot dumps the index for the /state endpoint, listing entries from
+// most to least recently used.
+func (idx *kvIndex) snapshot() map[string]engineCacheState {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	out := make(map[string]engineCacheState, len(idx.engines))
+	for id, ec := range idx.engines {
+		state := engineCacheState{Capacity: ec.capacity, UsedSize: ec.used, Entries: []cacheEntryState{}}
+		for el := ec.lru.Front(); el != nil; el = el.Next() {
+			entry := el.Value.(*cacheEntry)
+			state.NumTokens += len(entry.tokens)
+			state.Entries = append(state.Entries, cacheEntryState{
+				Tokens:           entry.tokens,
+				NumTokens:        len(entry.tokens),
+				Size:             entry.size(),
+				Location:         entry.location,
+				Pinned:           entry.pinned,
+				Compressed:       entry.method != "",
+				Method:           entry.method,
+				Hits:             entry.hits,
+				LastAccessUnixMs: entry.lastAccess.UnixMilli(),
+			})
+		}
+		out[id] = state
+	}
+	return out
+}
+
+// location returns the configured KV location of engine id.
+func (idx *kvIndex) location(id string) string {
+	return idx.configs[id].location
+}
+
+func (idx *kvIndex) lruEvictions() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.lruEvicts
+}
+
+// usage returns the capacity units engine id currently occupies and its
+// total capacity.
+func (idx *kvIndex) usage(id string) (int, int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	ec := idx.engine(id)
+	return ec.used, ec.capacity
+}
+
+func commonPrefixLen(a []int, b []int) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// hasTokenPrefix reports whether tokens starts with prefix.
+func hasTokenPrefix(tokens []int, prefix []int) bool {
+	return len(prefix) <= len(tokens) && commonPrefixLen(tokens, prefix) == len(prefix)
+}
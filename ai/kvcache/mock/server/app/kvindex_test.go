@@ -0,0 +1,56 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAdmitRefusedWithoutEvicting(t *testing.T) {
+	idx := newKVIndex(0, map[string]cacheConfig{"e": {location: "ram-e", capacity: 10}})
+	if _, err := idx.admit("e", []int{1, 2, 3, 4}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.pin("e", []int{5, 6, 7, 8}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// 7 more units do not fit next to the 4 pinned ones even after evicting
+	// the unpinned entry
+	if _, err := idx.admit("e", []int{9, 10, 11, 12, 13, 14, 15}, ""); !errors.Is(err, errCapacityExhausted) {
+		t.Fatalf("expected errCapacityExhausted, got %v", err)
+	}
+	// extending the pinned prefix past the capacity keeps the old entry
+	if _, err := idx.admit("e", []int{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, ""); !errors.Is(err, errCapacityExhausted) {
+		t.Fatalf("expected errCapacityExhausted, got %v", err)
+	}
+
+	state := idx.snapshot()["e"]
+	if state.UsedSize != 8 || len(state.Entries) != 2 || idx.lruEvictions() != 0 {
+		t.Fatalf("refused admissions changed the cache: %+v, %d evictions", state, idx.lruEvictions())
+	}
+
+	// 6 tokens fit once the unpinned entry is evicted
+	if _, err := idx.admit("e", []int{9, 10, 11, 12, 13, 14}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if used, _ := idx.usage("e"); used != 10 || idx.lruEvictions() != 1 {
+		t.Fatalf("expected 10 units used after one eviction, got %d and %d evictions", used, idx.lruEvictions())
+	}
+}
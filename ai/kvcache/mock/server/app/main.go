@@ -19,6 +19,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -36,30 +37,22 @@ type controllerStats struct {
 	lookupCalls   int
 	lookupSuccess int
 	lookupFailure int
+	lookupHits    int
+	coldStarts    int
 	pinCalls      int
 	compressCalls int
 	evictCalls    int
 }
 
-type engineAStats struct {
-	mu sync.Mutex
-
-	tokenizeCalls int
-	chatCalls     int
-}
-
-type engineBStats struct {
-	mu sync.Mutex
-
-	chatCalls int
-}
-
 type lookupRequest struct {
 	Tokens []int `json:"tokens"`
 }
 
 type tokensRequest struct {
-	Tokens []int `json:"tokens"`
+	InstanceID string `json:"instance_id"`
+	Location   string `json:"location"`
+	Method     string `json:"method"`
+	Tokens     []int  `json:"tokens"`
 }
 
 type eventResp struct {
@@ -68,7 +61,11 @@ type eventResp struct {
 }
 
 type llmRequest struct {
-	Model string `json:"model"`
+	Model         string         `json:"model"`
+	Prompt        any            `json:"prompt"`
+	Messages      []llmMessage   `json:"messages"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options"`
 }
 
 type llmMessage struct {
@@ -90,33 +87,55 @@ type llmResponse struct {
 	Usage    map[string]int `json:"usage"`
 }
 
-type tokenizeResponse struct {
-	Count  int   `json:"count"`
-	Tokens []int `json:"tokens"`
-	MaxLen int   `json:"max_model_len"`
-}
-
 var globalEventCounter uint64
 
 func main() {
-	controllerAddr := envOrDefault("LMCACHE_ADDR", ":18081")
-	engineAAddr := envOrDefault("LLM_A_ADDR", ":18091")
-	engineBAddr := envOrDefault("LLM_B_ADDR", ":18092")
-	preferredEndpoint := envOrDefault("PREFERRED_ENDPOINT_ID", "mock-llm-b")
-	engineAID := envOrDefault("LLM_A_ID", "mock-llm-a")
-	engineBID := envOrDefault("LLM_B_ID", "mock-llm-b")
-	responseDelay := envDurationMSOrDefault("MOCK_LLM_RESPONSE_DELAY_MS", 150)
-
-	controller := buildControllerMux(preferredEndpoint)
-	engineA := buildEngineAMux(engineAID, responseDelay)
-	engineB := buildEngineBMux(engineBID, responseDelay)
-
-	errCh := make(chan error, 3)
-	go serve("mock-controller", controllerAddr, controller, errCh)
-	go serve("mock-engine-a", engineAAddr, engineA, errCh)
-	go serve("mock-engine-b", engineBAddr, engineB, errCh)
-
-	err := <-errCh
+	fleetFile := flag.String("fleet", os.Getenv("MOCK_FLEET_FILE"), "YAML fleet spec; defaults to the controller plus mock-llm-a and mock-llm-b")
+	emitClusters := flag.Bool("emit-clusters", false, "print the Pixiu clusters block for the fleet and exit")
+	syncConf := flag.String("sync-conf", "", "rewrite the clusters block of this Pixiu config to match the fleet and exit")
+	flag.Parse()
+
+	fleet := defaultFleet()
+	if *fleetFile != "" {
+		var err error
+		if fleet, err = loadFleet(*fleetFile); err != nil {
+			log.Fatalf("load fleet: %v", err)
+		}
+	}
+	engines, err := fleet.engines()
+	if err != nil {
+		log.Fatalf("invalid fleet: %v", err)
+	}
+
+	if *emitClusters {
+		fmt.Print(fleet.writeClusters(engines))
+		return
+	}
+	if *syncConf != "" {
+		if err := fleet.syncClusters(*syncConf, engines); err != nil {
+			log.Fatalf("sync %s: %v", *syncConf, err)
+		}
+		log.Printf("updated clusters of %s for %d engines", *syncConf, len(engines))
+		return
+	}
+
+	caches := make(map[string]cacheConfig, len(engines))
+	for _, e := range engines {
+		caches[e.ID] = cacheConfig{location: e.Location, capacity: e.KVCapacityTokens}
+	}
+	controllerAddr := fleet.Controller.Addr
+	if controllerAddr == "" {
+		controllerAddr = defaultControllerAt
+	}
+
+	index := newKVIndex(defaultKVCapacity, caches)
+	errCh := make(chan error, len(engines)+1)
+	go serve("mock-controller", controllerAddr, buildControllerMux(index, fleet.Controller.Preferred), errCh)
+	for _, e := range engines {
+		go serve("mock-engine "+e.ID, e.addr(), buildEngineMux(e, index), errCh)
+	}
+
+	err = <-errCh
 	log.Fatalf("kvcache mock app exited: %v", err)
 }
 
@@ -132,7 +151,10 @@ func serve(name string, addr string, handler http.Handler, errCh chan<- error) {
 	}
 }
 
-func buildControllerMux(preferred string) http.Handler {
+// buildControllerMux serves the mock LMCache controller. /lookup answers
+// from the index; when no engine caches any prefix of the request it reports
+// the preferred engine as holding the whole prompt, flagged as cold_start.
+func buildControllerMux(index *kvIndex, preferred string) http.Handler {
 	stats := &controllerStats{}
 	mux := http.NewServeMux()
 
@@ -146,10 +168,23 @@ func buildControllerMux(preferred string) http.Handler {
 			"lookup_calls":         stats.lookupCalls,
 			"lookup_success":       stats.lookupSuccess,
 			"lookup_failure":       stats.lookupFailure,
+			"lookup_hits":          stats.lookupHits,
+			"cold_starts":          stats.coldStarts,
+			"preferred_endpoint":   preferred,
 			"pin_calls":            stats.pinCalls,
 			"compress_calls":       stats.compressCalls,
 			"evict_calls":          stats.evictCalls,
-			"preferred_endpoint":   preferred,
+			"lru_evictions":        index.lruEvictions(),
+			"timestamp_unix_milli": time.Now().UnixMilli(),
+		})
+	})
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"engines":              index.snapshot(),
 			"timestamp_unix_milli": time.Now().UnixMilli(),
 		})
 	})
@@ -162,10 +197,13 @@ func buildControllerMux(preferred string) http.Handler {
 		stats.lookupCalls = 0
 		stats.lookupSuccess = 0
 		stats.lookupFailure = 0
+		stats.lookupHits = 0
+		stats.coldStarts = 0
 		stats.pinCalls = 0
 		stats.compressCalls = 0
 		stats.evictCalls = 0
 		stats.mu.Unlock()
+		index.reset()
 		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 	})
 	mux.HandleFunc("/lookup", func(w http.ResponseWriter, r *http.Request) {
@@ -181,52 +219,54 @@ func buildControllerMux(preferred string) http.Handler {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
 			return
 		}
-		tokenCount := len(req.Tokens)
-		if tokenCount == 0 {
-			tokenCount = 1
-		}
-		layout := map[string]map[string]any{
-			"mock-llm-a": {"0": "ram-a", "1": max(tokenCount/2, 1)},
-			"mock-llm-b": {"0": "ram-b", "1": tokenCount + 3},
-		}
-		if preferred == "mock-llm-a" {
-			layout["mock-llm-a"]["1"] = tokenCount + 3
-			layout["mock-llm-b"]["1"] = max(tokenCount/2, 1)
+		layout := index.lookup(req.Tokens)
+		coldStart := len(layout) == 0 && len(req.Tokens) > 0 && preferred != ""
+		if coldStart {
+			layout[preferred] = map[string]any{"0": index.location(preferred), "1": len(req.Tokens)}
 		}
 
 		stats.mu.Lock()
 		stats.lookupCalls++
 		stats.lookupSuccess++
+		switch {
+		case coldStart:
+			stats.coldStarts++
+		case len(layout) > 0:
+			stats.lookupHits++
+		}
 		stats.mu.Unlock()
 
 		writeJSON(w, http.StatusOK, map[string]any{
 			"event_id":    nextEventID("lookup"),
 			"layout_info": layout,
+			"cold_start":  coldStart,
 		})
 	})
+	mux.HandleFunc("/admit", func(w http.ResponseWriter, r *http.Request) {
+		handleTokenEvent(index, stats, w, r, "admit")
+	})
 	mux.HandleFunc("/pin", func(w http.ResponseWriter, r *http.Request) {
-		handleTokenEvent(stats, w, r, "pin")
+		handleTokenEvent(index, stats, w, r, "pin")
 	})
 	mux.HandleFunc("/compress", func(w http.ResponseWriter, r *http.Request) {
-		handleTokenEvent(stats, w, r, "compress")
+		handleTokenEvent(index, stats, w, r, "compress")
 	})
 	mux.HandleFunc("/evict", func(w http.ResponseWriter, r *http.Request) {
-		handleTokenEvent(stats, w, r, "evict")
+		handleTokenEvent(index, stats, w, r, "evict")
 	})
 
 	return mux
 }
 
-func handleTokenEvent(stats *controllerStats, w http.ResponseWriter, r *http.Request, op string) {
+// handleTokenEvent applies an admit/pin/compress/evict request to the index.
+// num_tokens in the response is the number of cached tokens the operation
+// actually touched.
+func handleTokenEvent(index *kvIndex, stats *controllerStats, w http.ResponseWriter, r *http.Request, op string) {
 	if r.Method != http.MethodPost {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 		return
 	}
-	var req tokensRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
-		return
-	}
+	// Count every call, so a request the index cannot apply still shows up.
 	stats.mu.Lock()
 	switch op {
 	case "pin":
@@ -237,212 +277,40 @@ func handleTokenEvent(stats *controllerStats, w http.ResponseWriter, r *http.Req
 		stats.evictCalls++
 	}
 	stats.mu.Unlock()
-	writeJSON(w, http.StatusOK, eventResp{EventID: nextEventID(op), NumTokens: len(req.Tokens)})
-}
-
-func buildEngineAMux(engineID string, responseDelay time.Duration) http.Handler {
-	stats := &engineAStats{}
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "engine": engineID, "tokenize_enabled": true})
-	})
-	mux.HandleFunc("/stats", func(w http.ResponseWriter, _ *http.Request) {
-		stats.mu.Lock()
-		defer stats.mu.Unlock()
-		writeJSON(w, http.StatusOK, map[string]any{
-			"tokenize_calls":       stats.tokenizeCalls,
-			"chat_calls":           stats.chatCalls,
-			"engine_id":            engineID,
-			"timestamp_unix_milli": time.Now().UnixMilli(),
-		})
-	})
-	mux.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-			return
-		}
-		stats.mu.Lock()
-		stats.tokenizeCalls = 0
-		stats.chatCalls = 0
-		stats.mu.Unlock()
-		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
-	})
-	mux.HandleFunc("/tokenize", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-			return
-		}
-		prompt := extractPrompt(r)
-		tokens := tokenizePrompt(prompt)
 
-		stats.mu.Lock()
-		stats.tokenizeCalls++
-		stats.mu.Unlock()
-
-		writeJSON(w, http.StatusOK, tokenizeResponse{Count: len(tokens), Tokens: tokens, MaxLen: 8192})
-	})
-	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-			return
-		}
-		var req llmRequest
-		_ = json.NewDecoder(r.Body).Decode(&req)
-		if req.Model == "" {
-			req.Model = "mock-model"
-		}
-		stats.mu.Lock()
-		stats.chatCalls++
-		stats.mu.Unlock()
-		if responseDelay > 0 {
-			time.Sleep(responseDelay)
-		}
-
-		resp := llmResponse{
-			ID:       nextEventID("chatcmpl"),
-			Object:   "chat.completion",
-			Model:    req.Model,
-			ServedBy: engineID,
-			Choices: []llmChoice{{
-				Index: 0,
-				Message: llmMessage{
-					Role:    "assistant",
-					Content: fmt.Sprintf("mock response from %s", engineID),
-				},
-			}},
-			Usage: map[string]int{"prompt_tokens": 8, "completion_tokens": 8, "total_tokens": 16},
-		}
-		writeJSON(w, http.StatusOK, resp)
-	})
-
-	return mux
-}
-
-func buildEngineBMux(engineID string, responseDelay time.Duration) http.Handler {
-	stats := &engineBStats{}
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "engine": engineID, "tokenize_enabled": false})
-	})
-	mux.HandleFunc("/stats", func(w http.ResponseWriter, _ *http.Request) {
-		stats.mu.Lock()
-		defer stats.mu.Unlock()
-		writeJSON(w, http.StatusOK, map[string]any{
-			"chat_calls":           stats.chatCalls,
-			"engine_id":            engineID,
-			"timestamp_unix_milli": time.Now().UnixMilli(),
-		})
-	})
-	mux.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-			return
-		}
-		stats.mu.Lock()
-		stats.chatCalls = 0
-		stats.mu.Unlock()
-		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
-	})
-	mux.HandleFunc("/tokenize", func(w http.ResponseWriter, _ *http.Request) {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "tokenize not available on this instance"})
-	})
-	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-			return
-		}
-		var req llmRequest
-		_ = json.NewDecoder(r.Body).Decode(&req)
-		if req.Model == "" {
-			req.Model = "mock-model"
-		}
-
-		stats.mu.Lock()
-		stats.chatCalls++
-		stats.mu.Unlock()
-		if responseDelay > 0 {
-			time.Sleep(responseDelay)
-		}
-
-		resp := llmResponse{
-			ID:       nextEventID("chatcmpl"),
-			Object:   "chat.completion",
-			Model:    req.Model,
-			ServedBy: engineID,
-			Choices: []llmChoice{{
-				Index: 0,
-				Message: llmMessage{
-					Role:    "assistant",
-					Content: fmt.Sprintf("mock response from %s", engineID),
-				},
-			}},
-			Usage: map[string]int{"prompt_tokens": 8, "completion_tokens": 8, "total_tokens": 16},
-		}
-		writeJSON(w, http.StatusOK, resp)
-	})
-
-	return mux
-}
-
-func extractPrompt(r *http.Request) string {
-	if r == nil || r.Body == nil {
-		return ""
-	}
-	var payload map[string]any
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		return ""
-	}
-	if prompt, ok := payload["prompt"]; ok {
-		switch v := prompt.(type) {
-		case string:
-			return strings.TrimSpace(v)
-		case []any:
-			parts := make([]string, 0, len(v))
-			for _, item := range v {
-				if str, ok := item.(string); ok {
-					parts = append(parts, str)
-				}
-			}
-			return strings.Join(parts, "\n")
-		}
+	var req tokensRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
 	}
-	messages, ok := payload["messages"].([]any)
-	if !ok {
-		return ""
+	if op != "evict" && (req.InstanceID == "" || len(req.Tokens) == 0) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "instance_id and tokens are required"})
+		return
 	}
-	parts := make([]string, 0, len(messages))
-	for _, item := range messages {
-		msgMap, ok := item.(map[string]any)
-		if !ok {
-			continue
-		}
-		if content, ok := msgMap["content"].(string); ok {
-			parts = append(parts, content)
+
+	var (
+		numTokens int
+		err       error
+	)
+	switch op {
+	case "admit":
+		_, err = index.admit(req.InstanceID, req.Tokens, req.Location)
+		if err == nil {
+			numTokens = len(req.Tokens)
 		}
+	case "pin":
+		numTokens, err = index.pin(req.InstanceID, req.Tokens, req.Location)
+	case "compress":
+		numTokens = index.compress(req.InstanceID, req.Tokens, req.Method, req.Location)
+	case "evict":
+		numTokens = index.evict(req.InstanceID, req.Tokens)
 	}
-	return strings.Join(parts, "\n")
-}
 
-func tokenizePrompt(prompt string) []int {
-	prompt = strings.TrimSpace(prompt)
-	if prompt == "" {
-		return []int{0}
-	}
-	words := strings.Fields(prompt)
-	if len(words) == 0 {
-		return []int{0}
-	}
-	tokens := make([]int, 0, len(words))
-	for idx, word := range words {
-		sum := 0
-		for _, r := range word {
-			sum += int(r)
-		}
-		tokens = append(tokens, (sum%997)+idx+1)
+	if err != nil {
+		writeJSON(w, http.StatusInsufficientStorage, map[string]string{"error": err.Error()})
+		return
 	}
-	return tokens
+	writeJSON(w, http.StatusOK, eventResp{EventID: nextEventID(op), NumTokens: numTokens})
 }
 
 func nextEventID(prefix string) string {
@@ -476,9 +344,14 @@ func envDurationMSOrDefault(key string, fallbackMS int) time.Duration {
 	return time.Duration(ms) * time.Millisecond
 }
 
-func max(a int, b int) int {
-	if a > b {
-		return a
+func envIntOrDefault(key string, fallback int) int {
+	val, ok := os.LookupEnv(key)
+	if !ok || strings.TrimSpace(val) == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || n <= 0 {
+		return fallback
 	}
-	return b
+	return n
 }
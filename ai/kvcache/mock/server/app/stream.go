@@ -0,0 +1,144 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// streamConfig controls the pacing of streamed chat completions.
+type streamConfig struct {
+	// firstTokenDelay is the time before the first chunk is written, which
+	// is what the gateway observes as time to first token.
+	firstTokenDelay time.Duration
+	// tokenInterval is the pause between subsequent content chunks.
+	tokenInterval time.Duration
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type llmDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type llmChunkChoice struct {
+	Index        int      `json:"index"`
+	Delta        llmDelta `json:"delta"`
+	FinishReason *string  `json:"finish_reason"`
+}
+
+type llmChunk struct {
+	ID       string           `json:"id"`
+	Object   string           `json:"object"`
+	Created  int64            `json:"created"`
+	Model    string           `json:"model"`
+	ServedBy string           `json:"served_by"`
+	Choices  []llmChunkChoice `json:"choices"`
+	Usage    map[string]int   `json:"usage,omitempty"`
+}
+
+func newStreamConfig(firstTokenDelay time.Duration, tokensPerSecond int) streamConfig {
+	cfg := streamConfig{firstTokenDelay: firstTokenDelay}
+	if tokensPerSecond > 0 {
+		cfg.tokenInterval = time.Second / time.Duration(tokensPerSecond)
+	}
+	return cfg
+}
+
+// streamChatCompletion writes content as an OpenAI-compatible SSE stream: a
+// role chunk, one chunk per word, a finish chunk, an optional usage chunk
+// and the terminating [DONE] event. With cut set the connection is aborted
+// half way through the content chunks. It reports whether the whole stream
+// was delivered.
+func streamChatCompletion(w http.ResponseWriter, r *http.Request, req llmRequest, engineID string, content string, usage map[string]int, cfg streamConfig, cut bool) bool {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if !sleepOrDone(r, cfg.firstTokenDelay) {
+		return false
+	}
+
+	chunk := llmChunk{
+		ID:       nextEventID("chatcmpl"),
+		Object:   "chat.completion.chunk",
+		Created:  time.Now().Unix(),
+		Model:    req.Model,
+		ServedBy: engineID,
+	}
+	send := func(choices []llmChunkChoice, usage map[string]int) {
+		chunk.Choices = choices
+		chunk.Usage = usage
+		data, _ := json.Marshal(chunk)
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	send([]llmChunkChoice{{Delta: llmDelta{Role: "assistant"}}}, nil)
+	words := strings.Fields(content)
+	for i, word := range words {
+		if cut && i == len(words)/2 {
+			panic(http.ErrAbortHandler)
+		}
+		if i > 0 {
+			word = " " + word
+			if !sleepOrDone(r, cfg.tokenInterval) {
+				return false
+			}
+		}
+		send([]llmChunkChoice{{Delta: llmDelta{Content: word}}}, nil)
+	}
+	stop := "stop"
+	send([]llmChunkChoice{{Delta: llmDelta{}, FinishReason: &stop}}, nil)
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		send([]llmChunkChoice{}, usage)
+	}
+
+	_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+	return true
+}
+
+// sleepOrDone waits for d and reports false if the client went away first.
+func sleepOrDone(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return r.Context().Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
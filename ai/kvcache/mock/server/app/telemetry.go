@@ -0,0 +1,177 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// loadOverride adds synthetic load on top of the real requests, so the
+// cache_strategy thresholds can be crossed without generating traffic.
+type loadOverride struct {
+	Running int `json:"running"`
+	Waiting int `json:"waiting"`
+	// KVCacheUsage, when set, replaces the computed KV usage (0 to 1).
+	KVCacheUsage *float64 `json:"kv_cache_usage,omitempty"`
+}
+
+// engineLoad is a point-in-time view of an engine's load and KV memory.
+type engineLoad struct {
+	Running      int     `json:"running"`
+	Waiting      int     `json:"waiting"`
+	Capacity     int     `json:"capacity"`
+	LoadRatio    float64 `json:"load_ratio"`
+	KVCacheUsage float64 `json:"kv_cache_usage"`
+}
+
+// load combines in-flight requests, the synthetic override and the KV
+// index into the engine's current load. Running requests occupy KV memory
+// for their prompt and completion tokens on top of the cached prefixes.
+func (s *engineStats) load(cfg engineConfig, index *kvIndex) engineLoad {
+	s.mu.Lock()
+	running, waiting, inflight, override := s.running, s.waiting, s.inflightTokens, s.override
+	s.mu.Unlock()
+
+	l := engineLoad{Running: running + override.Running, Waiting: waiting + override.Waiting, Capacity: cfg.Capacity}
+	if cfg.Capacity > 0 {
+		l.LoadRatio = min(float64(l.Running+l.Waiting)/float64(cfg.Capacity), 1)
+	} else if l.Running > 0 {
+		l.LoadRatio = 1
+	}
+
+	used, capacity := index.usage(cfg.ID)
+	if capacity > 0 {
+		l.KVCacheUsage = min(float64(used+inflight)/float64(capacity), 1)
+	}
+	if override.KVCacheUsage != nil {
+		l.KVCacheUsage = *override.KVCacheUsage
+	}
+	return l
+}
+
+// handleLoad serves the per-engine load admin endpoint. GET shows the
+// current load, POST/PUT sets a synthetic override, DELETE clears it.
+func (s *engineStats) handleLoad(cfg engineConfig, index *kvIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost, http.MethodPut:
+			var override loadOverride
+			if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+				return
+			}
+			if override.Running < 0 || override.Waiting < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "running and waiting must not be negative"})
+				return
+			}
+			if u := override.KVCacheUsage; u != nil && (*u < 0 || *u > 1) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "kv_cache_usage must be between 0 and 1"})
+				return
+			}
+			s.mu.Lock()
+			s.override = override
+			s.mu.Unlock()
+		case http.MethodDelete:
+			s.mu.Lock()
+			s.override = loadOverride{}
+			s.mu.Unlock()
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		s.mu.Lock()
+		override := s.override
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]any{"load": s.load(cfg, index), "override": override})
+	}
+}
+
+// writeMetrics renders the engine's telemetry using vLLM's metric names in
+// the Prometheus text exposition format. Per-model series are labelled with
+// model_name; engine-wide gauges repeat the value for every served model.
+func (s *engineStats) writeMetrics(w http.ResponseWriter, cfg engineConfig, index *kvIndex) {
+	l := s.load(cfg, index)
+
+	s.mu.Lock()
+	type modelCounters struct{ running, waiting, prompt, generation, success int }
+	counters := make(map[string]modelCounters, len(cfg.Models))
+	for _, model := range cfg.Models {
+		counters[model] = modelCounters{
+			running:    s.modelRunning[model],
+			waiting:    s.modelWaiting[model],
+			prompt:     s.promptTokens[model],
+			generation: s.generationTokens[model],
+			success:    s.requestSuccess[model],
+		}
+	}
+	override := s.override
+	s.mu.Unlock()
+
+	var b strings.Builder
+	series := func(kind string, name string, help string, value func(model string) float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, model := range cfg.Models {
+			fmt.Fprintf(&b, "%s{engine=%q,model_name=%q} %g\n", name, cfg.ID, model, value(model))
+		}
+	}
+	gauge := func(name string, help string, value func(model string) float64) { series("gauge", name, help, value) }
+	counter := func(name string, help string, value func(model string) float64) { series("counter", name, help, value) }
+
+	// Synthetic load is attributed to the engine's first model.
+	first := cfg.Models[0]
+	gauge("vllm:num_requests_running", "Number of requests in model execution batches.", func(model string) float64 {
+		n := counters[model].running
+		if model == first {
+			n += override.Running
+		}
+		return float64(n)
+	})
+	gauge("vllm:num_requests_waiting", "Number of requests waiting to be processed.", func(model string) float64 {
+		n := counters[model].waiting
+		if model == first {
+			n += override.Waiting
+		}
+		return float64(n)
+	})
+	gauge("vllm:kv_cache_usage_perc", "KV-cache usage. 1 means 100 percent usage.", func(string) float64 {
+		return l.KVCacheUsage
+	})
+	gauge("vllm:gpu_cache_usage_perc", "GPU KV-cache usage. 1 means 100 percent usage.", func(string) float64 {
+		return l.KVCacheUsage
+	})
+	gauge("mock:engine_load_ratio", "Running plus waiting requests divided by engine capacity.", func(string) float64 {
+		return l.LoadRatio
+	})
+	counter("vllm:prompt_tokens_total", "Number of prefill tokens processed.", func(model string) float64 {
+		return float64(counters[model].prompt)
+	})
+	counter("vllm:generation_tokens_total", "Number of generation tokens processed.", func(model string) float64 {
+		return float64(counters[model].generation)
+	})
+	counter("vllm:request_success_total", "Count of successfully processed requests.", func(model string) float64 {
+		return float64(counters[model].success)
+	})
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, b.String())
+}
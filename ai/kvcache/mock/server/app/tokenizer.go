@@ -0,0 +1,310 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	vocabSize     = 32000
+	maxModelLen   = 8192
+	maxTokenBytes = 8
+
+	tokenIMStart = 1
+	tokenIMEnd   = 2
+	// firstRegularToken is the lowest ID handed out to non-special tokens.
+	firstRegularToken = 3
+)
+
+// specialTokens are the chat template markers. They are matched verbatim in
+// text and never split by the BPE step.
+var specialTokens = map[string]int{
+	"<|im_start|>": tokenIMStart,
+	"<|im_end|>":   tokenIMEnd,
+}
+
+type tokenizeResponse struct {
+	Count       int      `json:"count"`
+	Tokens      []int    `json:"tokens"`
+	TokenStrs   []string `json:"token_strs,omitempty"`
+	MaxLen      int      `json:"max_model_len"`
+	ChunkSize   int      `json:"chunk_size"`
+	ChunkHashes []string `json:"chunk_hashes"`
+}
+
+// tokenizeRequest accepts the request shapes of vLLM's /tokenize: either a
+// completion prompt or chat messages rendered through the chat template.
+type tokenizeRequest struct {
+	Model               string       `json:"model"`
+	Prompt              any          `json:"prompt"`
+	Messages            []llmMessage `json:"messages"`
+	AddGenerationPrompt *bool        `json:"add_generation_prompt"`
+	ReturnTokenStrs     bool         `json:"return_token_strs"`
+}
+
+// text returns the string to tokenize: the chat-templated messages when
+// present, otherwise the prompt (a string or a list of strings).
+func (req tokenizeRequest) text() string {
+	if len(req.Messages) > 0 {
+		return applyChatTemplate(req.Messages, req.AddGenerationPrompt == nil || *req.AddGenerationPrompt)
+	}
+	switch v := req.Prompt.(type) {
+	case string:
+		return v
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				parts = append(parts, str)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// applyChatTemplate renders messages in ChatML form, so conversations that
+// share a system prompt or earlier turns share a token prefix.
+func applyChatTemplate(messages []llmMessage, addGenerationPrompt bool) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		b.WriteString("<|im_start|>")
+		b.WriteString(msg.Role)
+		b.WriteString("\n")
+		b.WriteString(msg.Content)
+		b.WriteString("<|im_end|>\n")
+	}
+	if addGenerationPrompt {
+		b.WriteString("<|im_start|>assistant\n")
+	}
+	return b.String()
+}
+
+// tokenize splits text into special tokens and BPE tokens and returns their
+// IDs and surface strings. The result is deterministic, so conversations
+// that start with the same messages get the same token prefix.
+func tokenize(text string) ([]int, []string) {
+	var (
+		ids  []int
+		strs []string
+	)
+	for text != "" {
+		special, at := nextSpecialToken(text)
+		plain := text
+		if at >= 0 {
+			plain = text[:at]
+		}
+		for _, piece := range pretokenize(plain) {
+			for _, tok := range bpe(piece) {
+				ids = append(ids, tokenID(tok))
+				strs = append(strs, tok)
+			}
+		}
+		if at < 0 {
+			break
+		}
+		ids = append(ids, specialTokens[special])
+		strs = append(strs, special)
+		text = text[at+len(special):]
+	}
+	return ids, strs
+}
+
+func nextSpecialToken(text string) (string, int) {
+	found, at := "", -1
+	for special := range specialTokens {
+		if i := strings.Index(text, special); i >= 0 && (at < 0 || i < at) {
+			found, at = special, i
+		}
+	}
+	return found, at
+}
+
+type runeClass int
+
+const (
+	classLetter runeClass = iota
+	classDigit
+	classSpace
+	classNewline
+	classOther
+)
+
+func classify(r rune) runeClass {
+	switch {
+	case unicode.IsLetter(r) || unicode.IsMark(r):
+		return classLetter
+	case unicode.IsDigit(r):
+		return classDigit
+	case r == '\n' || r == '\r':
+		return classNewline
+	case unicode.IsSpace(r):
+		return classSpace
+	}
+	return classOther
+}
+
+// pretokenize splits text GPT-2 style: runs of letters or punctuation keep
+// one leading space, digits are grouped by at most three, and newlines and
+// remaining whitespace form their own pieces.
+func pretokenize(text string) []string {
+	var pieces []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		start := i
+		if runes[i] == ' ' && i+1 < len(runes) {
+			if c := classify(runes[i+1]); c == classLetter || c == classOther {
+				i++
+			}
+		}
+		class := classify(runes[i])
+		i++
+		for i < len(runes) && classify(runes[i]) == class {
+			if class == classDigit && i-start >= 3 {
+				break
+			}
+			if class == classSpace && i+1 < len(runes) && classify(runes[i+1]) != classSpace {
+				break
+			}
+			i++
+		}
+		pieces = append(pieces, string(runes[start:i]))
+	}
+	return pieces
+}
+
+// bpe merges the characters of piece pairwise, always applying the pair
+// with the lowest merge rank first, until no mergeable pair is left.
+func bpe(piece string) []string {
+	parts := make([]string, 0, utf8.RuneCountInString(piece))
+	for _, r := range piece {
+		parts = append(parts, string(r))
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, uint32(math.MaxUint32)
+		for i := 0; i+1 < len(parts); i++ {
+			if len(parts[i])+len(parts[i+1]) > maxTokenBytes {
+				continue
+			}
+			if rank, ok := mergeRank(parts[i], parts[i+1]); ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return parts
+}
+
+// mergeRank stands in for a trained merge table. Roughly one pair in eight
+// is treated as absent from the vocabulary, which keeps tokens from always
+// growing to maxTokenBytes.
+func mergeRank(left string, right string) (uint32, bool) {
+	h := fnv32a(left + "\x00" + right)
+	return h, h%8 != 0
+}
+
+func tokenID(tok string) int {
+	return firstRegularToken + int(fnv32a(tok)%uint32(vocabSize-firstRegularToken))
+}
+
+func fnv32a(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// chunkHashes splits tokens into chunkSize chunks the way LMCache keys its
+// KV storage: every hash covers the previous chunk hash and the chunk's
+// tokens, so a hash identifies the whole prefix up to that chunk. A trailing
+// partial chunk is hashed as well.
+func chunkHashes(tokens []int, chunkSize int) []string {
+	if chunkSize <= 0 {
+		return nil
+	}
+	hashes := make([]string, 0, (len(tokens)+chunkSize-1)/chunkSize)
+	var prev []byte
+	buf := make([]byte, 4)
+	for start := 0; start < len(tokens); start += chunkSize {
+		end := min(start+chunkSize, len(tokens))
+		h := sha256.New()
+		h.Write(prev)
+		for _, tok := range tokens[start:end] {
+			binary.LittleEndian.PutUint32(buf, uint32(tok))
+			h.Write(buf)
+		}
+		prev = h.Sum(nil)
+		hashes = append(hashes, hex.EncodeToString(prev[:8]))
+	}
+	return hashes
+}
+
+// serveTokenize answers a vLLM-compatible /tokenize request and reports
+// whether the request was tokenized.
+func serveTokenize(w http.ResponseWriter, r *http.Request, chunkSize int) bool {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return false
+	}
+	var req tokenizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return false
+	}
+	tokens, strs := tokenize(req.text())
+	if tokens == nil {
+		tokens, strs = []int{}, []string{}
+	}
+	resp := tokenizeResponse{
+		Count:       len(tokens),
+		Tokens:      tokens,
+		MaxLen:      maxModelLen,
+		ChunkSize:   chunkSize,
+		ChunkHashes: chunkHashes(tokens, chunkSize),
+	}
+	if req.ReturnTokenStrs {
+		resp.TokenStrs = strs
+	}
+	writeJSON(w, http.StatusOK, resp)
+	return true
+}
+
+// chatUsage counts prompt and completion tokens with the same tokenizer
+// that /tokenize uses.
+func chatUsage(req llmRequest, content string) map[string]int {
+	prompt, _ := tokenize(tokenizeRequest{Prompt: req.Prompt, Messages: req.Messages}.text())
+	completion, _ := tokenize(content)
+	return map[string]int{
+		"prompt_tokens":     len(prompt),
+		"completion_tokens": len(completion),
+		"total_tokens":      len(prompt) + len(completion),
+	}
+}
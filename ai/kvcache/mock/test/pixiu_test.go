@@ -17,11 +17,15 @@
 package test
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"io"
 	"net/http"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -183,3 +187,476 @@ func TestKVCacheMockRoutingFlow(t *testing.T) {
 		t.Fatalf("expected engine-b chat_calls >= 1, got %d", got)
 	}
 }
+
+func TestKVCacheControllerState(t *testing.T) {
+	controllerURL := getEnvOrDefault("CONTROLLER_URL", defaultControllerURL)
+	if !checkServiceAvailable(controllerURL + "/health") {
+		t.Skip("mock controller is unavailable; start mock servers first")
+	}
+
+	postJSON(t, controllerURL+"/reset", map[string]any{})
+	postJSON(t, controllerURL+"/admit", map[string]any{"instance_id": "mock-llm-a", "tokens": []int{1, 2, 3, 4}})
+	postJSON(t, controllerURL+"/pin", map[string]any{"instance_id": "mock-llm-b", "location": "ram-b", "tokens": []int{1, 2, 3, 4, 5, 6}})
+
+	lookup := postJSON(t, controllerURL+"/lookup", map[string]any{"tokens": []int{1, 2, 3, 4, 5, 6, 7}})
+	layout, _ := lookup["layout_info"].(map[string]any)
+	hitA, _ := layout["mock-llm-a"].(map[string]any)
+	hitB, _ := layout["mock-llm-b"].(map[string]any)
+	if hitA == nil || hitB == nil {
+		t.Fatalf("expected hits on both engines, got %v", layout)
+	}
+	if got := toInt(t, hitA["1"], "mock-llm-a hit"); got != 4 {
+		t.Fatalf("expected mock-llm-a prefix hit 4, got %d", got)
+	}
+	if got := toInt(t, hitB["1"], "mock-llm-b hit"); got != 6 {
+		t.Fatalf("expected mock-llm-b prefix hit 6, got %d", got)
+	}
+
+	// A prefix no engine caches is a cold start reported on the preferred engine
+	miss := postJSON(t, controllerURL+"/lookup", map[string]any{"tokens": []int{9, 9}})
+	missLayout, _ := miss["layout_info"].(map[string]any)
+	coldB, _ := missLayout["mock-llm-b"].(map[string]any)
+	if miss["cold_start"] != true || len(missLayout) != 1 || coldB == nil || toInt(t, coldB["1"], "cold start hit") != 2 {
+		t.Fatalf("expected a cold start on mock-llm-b for unknown prefix, got %v", miss)
+	}
+	if hit := postJSON(t, controllerURL+"/lookup", map[string]any{"tokens": []int{1, 2}}); hit["cold_start"] != false {
+		t.Fatalf("expected a cached prefix not to be a cold start, got %v", hit)
+	}
+
+	evicted := postJSON(t, controllerURL+"/evict", map[string]any{"tokens": []int{1, 2}})
+	if got := toInt(t, evicted["num_tokens"], "num_tokens"); got != 4 {
+		t.Fatalf("expected evict to drop the unpinned 4-token entry, got %d", got)
+	}
+	compressed := postJSON(t, controllerURL+"/compress", map[string]any{"instance_id": "mock-llm-b", "method": "zstd", "tokens": []int{1}})
+	if got := toInt(t, compressed["num_tokens"], "num_tokens"); got != 6 {
+		t.Fatalf("expected compress to touch 6 tokens, got %d", got)
+	}
+
+	state := getJSON(t, controllerURL+"/state")
+	engines, _ := state["engines"].(map[string]any)
+	engineA, _ := engines["mock-llm-a"].(map[string]any)
+	engineB, _ := engines["mock-llm-b"].(map[string]any)
+	if got := toInt(t, engineA["num_tokens"], "mock-llm-a num_tokens"); got != 0 {
+		t.Fatalf("expected mock-llm-a to be empty after evict, got %d tokens", got)
+	}
+	entries, _ := engineB["entries"].([]any)
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry on mock-llm-b, got %v", engineB["entries"])
+	}
+	entry, _ := entries[0].(map[string]any)
+	if entry["pinned"] != true || entry["compressed"] != true {
+		t.Fatalf("expected pinned and compressed entry, got %v", entry)
+	}
+	if got := toInt(t, engineB["used_size"], "used_size"); got != 3 {
+		t.Fatalf("expected compressed entry to use 3 units, got %d", got)
+	}
+}
+
+func TestKVCacheMockEngineStreaming(t *testing.T) {
+	engineBURL := getEnvOrDefault("ENGINE_B_URL", defaultEngineBURL)
+	if !checkServiceAvailable(engineBURL + "/health") {
+		t.Skip("mock engine is unavailable; start mock servers first")
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"model":          "mock-model",
+		"stream":         true,
+		"stream_options": map[string]any{"include_usage": true},
+		"messages":       []map[string]any{{"role": "user", "content": "stream please"}},
+	})
+	if err != nil {
+		t.Fatalf("marshal payload failed: %v", err)
+	}
+	start := time.Now()
+	resp, err := testHTTPClient.Post(engineBURL+"/v1/chat/completions", "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("stream request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	var (
+		content   strings.Builder
+		ttft      time.Duration
+		finished  bool
+		usage     map[string]any
+		done      bool
+		chunkSeen int
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		payload := strings.TrimPrefix(line, "data: ")
+		if payload == "[DONE]" {
+			done = true
+			break
+		}
+		if chunkSeen == 0 {
+			ttft = time.Since(start)
+		}
+		chunkSeen++
+
+		var chunk map[string]any
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %v", payload, err)
+		}
+		if chunk["object"] != "chat.completion.chunk" {
+			t.Fatalf("unexpected chunk object: %v", chunk["object"])
+		}
+		if u, ok := chunk["usage"].(map[string]any); ok {
+			usage = u
+		}
+		choices, _ := chunk["choices"].([]any)
+		for _, c := range choices {
+			choice, _ := c.(map[string]any)
+			if delta, ok := choice["delta"].(map[string]any); ok {
+				if text, ok := delta["content"].(string); ok {
+					content.WriteString(text)
+				}
+			}
+			if choice["finish_reason"] == "stop" {
+				finished = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read stream failed: %v", err)
+	}
+
+	if !done || !finished {
+		t.Fatalf("stream not terminated properly: done=%v finished=%v", done, finished)
+	}
+	if got := content.String(); got != "mock response from mock-llm-b" {
+		t.Fatalf("unexpected streamed content %q", got)
+	}
+	if usage == nil || toInt(t, usage["total_tokens"], "total_tokens") == 0 {
+		t.Fatalf("expected a usage chunk, got %v", usage)
+	}
+	t.Logf("time to first token: %s", ttft)
+}
+
+func TestKVCacheMockEngineFaults(t *testing.T) {
+	engineBURL := getEnvOrDefault("ENGINE_B_URL", defaultEngineBURL)
+	if !checkServiceAvailable(engineBURL + "/health") {
+		t.Skip("mock engine is unavailable; start mock servers first")
+	}
+	defer func() {
+		req, _ := http.NewRequest(http.MethodDelete, engineBURL+"/fault", nil)
+		if resp, err := testHTTPClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	chat := func() (*http.Response, error) {
+		return testHTTPClient.Post(engineBURL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"mock-model","messages":[{"role":"user","content":"fault"}]}`))
+	}
+
+	postJSON(t, engineBURL+"/fault", map[string]any{"error_percent": 100, "error_codes": []int{429}, "retry_after_seconds": 3})
+	resp, err := chat()
+	if err != nil {
+		t.Fatalf("chat request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "3" {
+		t.Fatalf("expected 429 with Retry-After 3, got %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	postJSON(t, engineBURL+"/fault", map[string]any{"reset_percent": 100})
+	if resp, err := chat(); err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected connection reset, got status %d", resp.StatusCode)
+	}
+
+	postJSON(t, engineBURL+"/fault", map[string]any{"cut_percent": 100})
+	resp, err = chat()
+	if err != nil {
+		t.Fatalf("chat request failed: %v", err)
+	}
+	_, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil {
+		t.Fatalf("expected truncated body to fail reading")
+	}
+
+	state := postJSON(t, engineBURL+"/fault", map[string]any{"preset": "slow-first-byte"})
+	profile, _ := state["profile"].(map[string]any)
+	if got := toInt(t, profile["first_byte_delay_ms"], "first_byte_delay_ms"); got <= 0 {
+		t.Fatalf("expected slow-first-byte preset to delay headers, got %d", got)
+	}
+}
+
+func TestKVCacheMockTokenizerPrefixSharing(t *testing.T) {
+	engineAURL := getEnvOrDefault("ENGINE_A_URL", defaultEngineAURL)
+	engineBURL := getEnvOrDefault("ENGINE_B_URL", defaultEngineBURL)
+	if !checkServiceAvailable(engineAURL+"/health") || !checkServiceAvailable(engineBURL+"/health") {
+		t.Skip("mock engines are unavailable; start mock servers first")
+	}
+
+	system := map[string]any{"role": "system", "content": "You are a helpful assistant for the Pixiu gateway."}
+	tokensOf := func(url string, generationPrompt bool, messages ...map[string]any) []int {
+		out := postJSON(t, url+"/tokenize", map[string]any{
+			"model":                 "mock-model",
+			"messages":              messages,
+			"add_generation_prompt": generationPrompt,
+		})
+		raw, _ := out["tokens"].([]any)
+		if got := toInt(t, out["count"], "count"); got != len(raw) {
+			t.Fatalf("count %d does not match %d tokens", got, len(raw))
+		}
+		if hashes, _ := out["chunk_hashes"].([]any); len(hashes) == 0 {
+			t.Fatalf("expected chunk_hashes in tokenize response: %v", out)
+		}
+		tokens := make([]int, len(raw))
+		for i, v := range raw {
+			tokens[i] = toInt(t, v, "token")
+		}
+		return tokens
+	}
+	systemTurn := tokensOf(engineAURL, false, system)
+	first := tokensOf(engineAURL, true, system, map[string]any{"role": "user", "content": "How does kvcache routing work?"})
+	second := tokensOf(engineAURL, true, system, map[string]any{"role": "user", "content": "What does the hot window do?"})
+	again := tokensOf(engineBURL, true, system, map[string]any{"role": "user", "content": "How does kvcache routing work?"})
+
+	for i := range systemTurn {
+		if first[i] != systemTurn[i] || second[i] != systemTurn[i] {
+			t.Fatalf("expected shared system prompt prefix of %d tokens, diverged at %d", len(systemTurn), i)
+		}
+	}
+	if len(first) != len(again) {
+		t.Fatalf("expected both engines to tokenize identically, got %d and %d tokens", len(first), len(again))
+	}
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("engines disagree at token %d", i)
+		}
+	}
+}
+
+// metricValue returns the value of the first sample of name in a Prometheus
+// text exposition, or -1 when the metric is missing.
+func metricValue(t *testing.T, body string, name string) float64 {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(line, name+"{") {
+			continue
+		}
+		fields := strings.Fields(line)
+		v, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			t.Fatalf("parse %s failed: %v", line, err)
+		}
+		return v
+	}
+	return -1
+}
+
+func scrapeMetrics(t *testing.T, engineURL string) string {
+	t.Helper()
+	resp, err := testHTTPClient.Get(engineURL + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected metrics content type %q", resp.Header.Get("Content-Type"))
+	}
+	return string(body)
+}
+
+func TestKVCacheMockEngineMetrics(t *testing.T) {
+	engineAURL := getEnvOrDefault("ENGINE_A_URL", defaultEngineAURL)
+	if !checkServiceAvailable(engineAURL + "/health") {
+		t.Skip("mock engine is unavailable; start mock servers first")
+	}
+	defer func() {
+		req, _ := http.NewRequest(http.MethodDelete, engineAURL+"/load", nil)
+		if resp, err := testHTTPClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	scrape := func() string { return scrapeMetrics(t, engineAURL) }
+
+	before := metricValue(t, scrape(), "vllm:request_success_total")
+	postJSON(t, engineAURL+"/v1/chat/completions", map[string]any{
+		"model":    "mock-model",
+		"messages": []map[string]string{{"role": "user", "content": "metrics"}},
+	})
+	metrics := scrape()
+	if got := metricValue(t, metrics, "vllm:request_success_total"); got != before+1 {
+		t.Fatalf("expected request_success_total %v, got %v", before+1, got)
+	}
+	if metricValue(t, metrics, "vllm:prompt_tokens_total") <= 0 {
+		t.Fatalf("expected prompt tokens to be counted:\n%s", metrics)
+	}
+
+	postJSON(t, engineAURL+"/load", map[string]any{"running": 3, "waiting": 5, "kv_cache_usage": 0.9})
+	metrics = scrape()
+	if got := metricValue(t, metrics, "vllm:num_requests_running"); got != 3 {
+		t.Fatalf("expected 3 running requests, got %v", got)
+	}
+	if got := metricValue(t, metrics, "vllm:num_requests_waiting"); got != 5 {
+		t.Fatalf("expected 5 waiting requests, got %v", got)
+	}
+	if got := metricValue(t, metrics, "vllm:kv_cache_usage_perc"); got != 0.9 {
+		t.Fatalf("expected kv cache usage 0.9, got %v", got)
+	}
+
+	state := getJSON(t, engineAURL+"/stats")
+	load, _ := state["load"].(map[string]any)
+	if got := load["kv_cache_usage"]; got != 0.9 {
+		t.Fatalf("expected /stats to report the overridden kv usage, got %#v", got)
+	}
+}
+
+// strategyThreshold reads a cache_strategy threshold from pixiu/conf.yaml.
+func strategyThreshold(t *testing.T, name string) float64 {
+	t.Helper()
+	conf, err := os.ReadFile("../pixiu/conf.yaml")
+	if err != nil {
+		t.Fatalf("read pixiu conf failed: %v", err)
+	}
+	m := regexp.MustCompile(name + `:\s*([0-9.]+)`).FindSubmatch(conf)
+	if m == nil {
+		t.Fatalf("%s not found in pixiu/conf.yaml", name)
+	}
+	v, err := strconv.ParseFloat(string(m[1]), 64)
+	if err != nil {
+		t.Fatalf("parse %s failed: %v", name, err)
+	}
+	return v
+}
+
+// TestKVCacheMockLoadThresholds moves the engine Pixiu reads metrics from
+// across the memory_threshold and load_threshold of pixiu/conf.yaml and back.
+func TestKVCacheMockLoadThresholds(t *testing.T) {
+	engineAURL := getEnvOrDefault("ENGINE_A_URL", defaultEngineAURL)
+	if !checkServiceAvailable(engineAURL + "/health") {
+		t.Skip("mock engine is unavailable; start mock servers first")
+	}
+	clearLoad := func() {
+		req, _ := http.NewRequest(http.MethodDelete, engineAURL+"/load", nil)
+		resp, err := testHTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("clear load failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	defer clearLoad()
+
+	memoryThreshold := strategyThreshold(t, "memory_threshold")
+	loadThreshold := strategyThreshold(t, "load_threshold")
+	if memoryThreshold <= 0 || memoryThreshold >= 1 || loadThreshold <= 0 || loadThreshold >= 1 {
+		t.Fatalf("thresholds must lie between 0 and 1 to be crossed, got memory %v and load %v", memoryThreshold, loadThreshold)
+	}
+
+	postJSON(t, engineAURL+"/reset", map[string]any{})
+	loadOf := func() (float64, float64) {
+		metrics := scrapeMetrics(t, engineAURL)
+		return metricValue(t, metrics, "mock:engine_load_ratio"), metricValue(t, metrics, "vllm:kv_cache_usage_perc")
+	}
+
+	clearLoad()
+	if ratio, usage := loadOf(); ratio >= loadThreshold || usage >= memoryThreshold {
+		t.Fatalf("idle engine is already over the thresholds: load %v, kv usage %v", ratio, usage)
+	}
+
+	state := getJSON(t, engineAURL+"/load")
+	load, _ := state["load"].(map[string]any)
+	capacity := toInt(t, load["capacity"], "capacity")
+	postJSON(t, engineAURL+"/load", map[string]any{
+		"running":        max(capacity, 1),
+		"waiting":        capacity,
+		"kv_cache_usage": (memoryThreshold + 1) / 2,
+	})
+	if ratio, usage := loadOf(); ratio <= loadThreshold || usage <= memoryThreshold {
+		t.Fatalf("expected load above %v and kv usage above %v, got %v and %v", loadThreshold, memoryThreshold, ratio, usage)
+	}
+
+	clearLoad()
+	if ratio, usage := loadOf(); ratio >= loadThreshold || usage >= memoryThreshold {
+		t.Fatalf("expected the engine back below the thresholds, got load %v and kv usage %v", ratio, usage)
+	}
+}
+
+// strategyActions returns how many compress and evict calls the controller
+// has received since its last reset.
+func strategyActions(t *testing.T, controllerURL string) int {
+	t.Helper()
+	stats := getJSON(t, controllerURL+"/stats")
+	return toInt(t, stats["compress_calls"], "compress_calls") + toInt(t, stats["evict_calls"], "evict_calls")
+}
+
+// TestKVCacheStrategyFollowsThresholds checks that Pixiu only sends the
+// compress and evict actions of cache_strategy once the engine it reads
+// metrics from is over memory_threshold and load_threshold.
+func TestKVCacheStrategyFollowsThresholds(t *testing.T) {
+	pixiuURL := getEnvOrDefault("PIXIU_URL", defaultPixiuURL)
+	controllerURL := getEnvOrDefault("CONTROLLER_URL", defaultControllerURL)
+	engineAURL := getEnvOrDefault("ENGINE_A_URL", defaultEngineAURL)
+	engineBURL := getEnvOrDefault("ENGINE_B_URL", defaultEngineBURL)
+
+	if !checkServiceAvailable(controllerURL+"/health") ||
+		!checkServiceAvailable(engineAURL+"/health") ||
+		!checkServiceAvailable(engineBURL+"/health") ||
+		!checkPixiuAvailable(pixiuURL) {
+		t.Skip("required services are unavailable; start mock servers and pixiu first")
+	}
+	clearLoad := func() {
+		req, _ := http.NewRequest(http.MethodDelete, engineAURL+"/load", nil)
+		resp, err := testHTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("clear load failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	defer clearLoad()
+
+	postJSON(t, controllerURL+"/reset", map[string]any{})
+	postJSON(t, engineAURL+"/reset", map[string]any{})
+	postJSON(t, engineBURL+"/reset", map[string]any{})
+	clearLoad()
+
+	send := func(content string) {
+		postJSON(t, pixiuURL+"/v1/chat/completions", map[string]any{
+			"model":    "mock-model",
+			"messages": []map[string]any{{"role": "user", "content": content}},
+		})
+	}
+
+	// Below both thresholds a hot prompt may be pinned but never compressed or evicted
+	for i := 0; i < 3; i++ {
+		send("kvcache strategy below thresholds")
+		time.Sleep(300 * time.Millisecond)
+	}
+	time.Sleep(1 * time.Second)
+	below := strategyActions(t, controllerURL)
+	if below != 0 {
+		t.Fatalf("expected no compress or evict calls below the thresholds, got %d", below)
+	}
+
+	state := getJSON(t, engineAURL+"/load")
+	load, _ := state["load"].(map[string]any)
+	capacity := toInt(t, load["capacity"], "capacity")
+	postJSON(t, engineAURL+"/load", map[string]any{
+		"running":        max(capacity, 1),
+		"waiting":        capacity,
+		"kv_cache_usage": (strategyThreshold(t, "memory_threshold") + 1) / 2,
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for strategyActions(t, controllerURL) == below {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected compress or evict calls once engine A is over the thresholds, controller stats %v", getJSON(t, controllerURL+"/stats"))
+		}
+		send("kvcache strategy over thresholds")
+		time.Sleep(300 * time.Millisecond)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package admin gives the sample gRPC servers the standard grpc.health.v1
+// service and ORCA load reports, so health checks and load-aware balancing
+// have a real signal to act on. Servers started with ServeWhileServing also
+// stop listening while NOT_SERVING, which is what the TCP health checks of
+// Pixiu v1.0.1-rc1 can see. Their health service can then only be reached
+// while SERVING, so servers that are checked over grpc.health.v1 should keep
+// their own listener.
+//
+// Each instance can also serve a small HTTP endpoint, see Handler, that tests
+// use to take it out of rotation or make it look busier than it is.
+package admin
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/orca"
+)
+
+// InFlightMetric is the named metric carrying the number of calls the
+// instance was handling when a call started, itself included. Named metrics
+// only travel in per-call reports, not out-of-band ones.
+const InFlightMetric = "in_flight"
+
+// rateWindow is how often QPS and EPS are recomputed.
+const rateWindow = time.Second
+
+// Admin holds the health and load state of one server instance.
+type Admin struct {
+	health   *health.Server
+	services []string
+	recorder orca.ServerMetricsRecorder
+
+	inFlight atomic.Int64
+	calls    atomic.Int64
+	errors   atomic.Int64
+
+	mu        sync.Mutex // protects the fields below
+	changed   *sync.Cond // signalled when serving changes
+	serving   bool
+	lis       net.Listener // open while serving, if started with ServeWhileServing
+	since     time.Time
+	lastCalls int64
+	lastErrs  int64
+}
+
+// New creates the state of an instance serving the named services. The
+// instance starts out SERVING.
+func New(services ...string) *Admin {
+	a := &Admin{
+		health:   health.NewServer(),
+		services: services,
+		recorder: orca.NewServerMetricsRecorder(),
+		since:    time.Now(),
+	}
+	a.changed = sync.NewCond(&a.mu)
+	a.SetServing(true)
+	return a
+}
+
+// Register adds the health service and the out-of-band ORCA service to s.
+func (a *Admin) Register(s *grpc.Server) error {
+	healthpb.RegisterHealthServer(s, a.health)
+	return orca.Register(s, orca.ServiceOptions{ServerMetricsProvider: a})
+}
+
+// ServerOptions returns the options that attach a load report to the
+// trailers of every call and keep the call counters behind it. They are meant
+// to come before any other interceptor so failed calls are counted too.
+func (a *Admin) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		orca.CallMetricsServerOption(a),
+		grpc.ChainUnaryInterceptor(a.unaryInterceptor),
+		grpc.ChainStreamInterceptor(a.streamInterceptor),
+	}
+}
+
+// Serving reports whether the instance is SERVING.
+func (a *Admin) Serving() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.serving
+}
+
+// SetServing sets the status of the whole server and of every registered
+// service, so checks naming a service and checks that don't agree. Going
+// NOT_SERVING closes the listener opened by ServeWhileServing.
+func (a *Admin) SetServing(serving bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.serving = serving
+	a.changed.Broadcast()
+	if !serving && a.lis != nil {
+		_ = a.lis.Close()
+		a.lis = nil
+	}
+	st := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		st = healthpb.HealthCheckResponse_SERVING
+	}
+	a.health.SetServingStatus("", st)
+	for _, svc := range a.services {
+		a.health.SetServingStatus(svc, st)
+	}
+}
+
+// ServeWhileServing serves s on addr for as long as the instance is SERVING.
+// Going NOT_SERVING closes the listener, so new connections are refused while
+// established ones finish their calls; going SERVING again listens anew.
+// It returns when s is stopped or the listener fails for another reason.
+func (a *Admin) ServeWhileServing(s *grpc.Server, addr string) error {
+	for {
+		a.mu.Lock()
+		for !a.serving {
+			a.changed.Wait()
+		}
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			a.mu.Unlock()
+			return err
+		}
+		a.lis = lis
+		a.mu.Unlock()
+
+		err = s.Serve(lis)
+
+		a.mu.Lock()
+		closedByUs := a.lis != lis
+		if !closedByUs {
+			a.lis = nil
+		}
+		a.mu.Unlock()
+		if err == nil || !closedByUs {
+			return err
+		}
+	}
+}
+
+// Pin fixes the reported utilization values. Negative values are left out of
+// the reports.
+func (a *Admin) Pin(cpu, mem, app float64) {
+	a.Unpin()
+	if cpu >= 0 {
+		a.recorder.SetCPUUtilization(cpu)
+	}
+	if mem >= 0 {
+		a.recorder.SetMemoryUtilization(mem)
+	}
+	if app >= 0 {
+		a.recorder.SetApplicationUtilization(app)
+	}
+}
+
+// Unpin drops the pinned utilization values.
+func (a *Admin) Unpin() {
+	a.recorder.DeleteCPUUtilization()
+	a.recorder.DeleteMemoryUtilization()
+	a.recorder.DeleteApplicationUtilization()
+}
+
+// ServerMetrics implements orca.ServerMetricsProvider. QPS and EPS are
+// measured over the last completed window of calls.
+func (a *Admin) ServerMetrics() *orca.ServerMetrics {
+	a.mu.Lock()
+	if elapsed := time.Since(a.since); elapsed >= rateWindow {
+		calls, errs := a.calls.Load(), a.errors.Load()
+		a.recorder.SetQPS(float64(calls-a.lastCalls) / elapsed.Seconds())
+		a.recorder.SetEPS(float64(errs-a.lastErrs) / elapsed.Seconds())
+		a.since, a.lastCalls, a.lastErrs = time.Now(), calls, errs
+	}
+	a.mu.Unlock()
+	return a.recorder.ServerMetrics()
+}
+
+// begin counts a call in and returns the function counting it out.
+func (a *Admin) begin(ctx context.Context) func(error) {
+	n := a.inFlight.Add(1)
+	if r := orca.CallMetricsRecorderFromContext(ctx); r != nil {
+		r.SetNamedMetric(InFlightMetric, float64(n))
+	}
+	return func(err error) {
+		a.inFlight.Add(-1)
+		a.calls.Add(1)
+		if err != nil {
+			a.errors.Add(1)
+		}
+	}
+}
+
+func (a *Admin) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	end := a.begin(ctx)
+	resp, err := handler(ctx, req)
+	end(err)
+	return resp, err
+}
+
+func (a *Admin) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	end := a.begin(ss.Context())
+	err := handler(srv, ss)
+	end(err)
+	return err
+}
@@ -0,0 +1,261 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+import (
+	orcapb "github.com/cncf/xds/go/xds/data/orca/v3"
+	orcaservicepb "github.com/cncf/xds/go/xds/service/orca/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"google.golang.org/protobuf/proto"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/grpc/admin"
+	pb "github.com/dubbo-go-pixiu/samples/grpc/reflection/proto"
+)
+
+// loadReportKey is the trailer carrying per-call ORCA load reports.
+const loadReportKey = "endpoint-load-metrics-bin"
+
+type echoServer struct {
+	pb.UnimplementedEchoServiceServer
+}
+
+func (echoServer) Echo(_ context.Context, req *pb.EchoRequest) (*pb.EchoResponse, error) {
+	if req.Message == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty message")
+	}
+	return &pb.EchoResponse{Message: req.Message}, nil
+}
+
+// newServer starts an EchoService with the admin services and returns a
+// connection to it and its HTTP endpoint.
+func newServer(t *testing.T) (*admin.Admin, *grpc.ClientConn, *httptest.Server) {
+	t.Helper()
+	state := admin.New(pb.EchoService_ServiceDesc.ServiceName)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(state.ServerOptions()...)
+	pb.RegisterEchoServiceServer(server, echoServer{})
+	require.NoError(t, state.Register(server))
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	web := httptest.NewServer(state.Handler())
+	t.Cleanup(web.Close)
+	return state, conn, web
+}
+
+func post(t *testing.T, url string) int {
+	t.Helper()
+	resp, err := http.Post(url, "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestHealthToggle(t *testing.T) {
+	state, conn, web := newServer(t)
+	client := healthpb.NewHealthClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.Status
+	}
+	for _, svc := range []string{"", pb.EchoService_ServiceDesc.ServiceName} {
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check(svc), svc)
+	}
+
+	assert.Equal(t, http.StatusOK, post(t, web.URL+"/health"))
+	assert.False(t, state.Serving())
+	for _, svc := range []string{"", pb.EchoService_ServiceDesc.ServiceName} {
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(svc), svc)
+	}
+
+	assert.Equal(t, http.StatusOK, post(t, web.URL+"/health?status=NOT_SERVING"))
+	assert.False(t, state.Serving(), "setting the status does not flip it")
+	assert.Equal(t, http.StatusOK, post(t, web.URL+"/health?status=SERVING"))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check(""))
+
+	assert.Equal(t, http.StatusBadRequest, post(t, web.URL+"/health?status=DRAINING"))
+	assert.True(t, state.Serving())
+}
+
+func TestHealthWatch(t *testing.T) {
+	state, conn, _ := newServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{Service: pb.EchoService_ServiceDesc.ServiceName})
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	state.SetServing(false)
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+}
+
+func TestPerCallLoadReport(t *testing.T) {
+	_, conn, web := newServer(t)
+	client := pb.NewEchoServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.Equal(t, http.StatusOK, post(t, web.URL+"/load?cpu=0.75&app=0.5"))
+
+	var trailer metadata.MD
+	_, err := client.Echo(ctx, &pb.EchoRequest{Message: "hello"}, grpc.Trailer(&trailer))
+	require.NoError(t, err)
+	report := decodeReport(t, trailer)
+	assert.InDelta(t, 0.75, report.CpuUtilization, 1e-9)
+	assert.InDelta(t, 0.5, report.ApplicationUtilization, 1e-9)
+	assert.Zero(t, report.MemUtilization)
+	assert.Equal(t, 1.0, report.NamedMetrics[admin.InFlightMetric], "the call counts itself")
+
+	// Failed calls carry a report as well.
+	_, err = client.Echo(ctx, &pb.EchoRequest{}, grpc.Trailer(&trailer))
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.InDelta(t, 0.75, decodeReport(t, trailer).CpuUtilization, 1e-9)
+
+	require.Equal(t, http.StatusOK, post(t, web.URL+"/load"))
+	_, err = client.Echo(ctx, &pb.EchoRequest{Message: "hello"}, grpc.Trailer(&trailer))
+	require.NoError(t, err)
+	assert.Zero(t, decodeReport(t, trailer).CpuUtilization, "unpinned values are left out")
+
+	assert.Equal(t, http.StatusBadRequest, post(t, web.URL+"/load?cpu=high"))
+}
+
+func TestOutOfBandLoadReport(t *testing.T) {
+	state, conn, _ := newServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	state.Pin(-1, 0.25, -1)
+	stream, err := orcaservicepb.NewOpenRcaServiceClient(conn).StreamCoreMetrics(ctx, &orcaservicepb.OrcaLoadReportRequest{})
+	require.NoError(t, err)
+	report, err := stream.Recv()
+	require.NoError(t, err)
+	assert.InDelta(t, 0.25, report.MemUtilization, 1e-9)
+	assert.Zero(t, report.CpuUtilization)
+}
+
+func TestRates(t *testing.T) {
+	state, conn, _ := newServer(t)
+	client := pb.NewEchoServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 4; i++ {
+		_, err := client.Echo(ctx, &pb.EchoRequest{Message: "hello"})
+		require.NoError(t, err)
+	}
+	_, err := client.Echo(ctx, &pb.EchoRequest{})
+	require.Error(t, err)
+
+	assert.Negative(t, state.ServerMetrics().QPS, "no window has completed yet")
+	time.Sleep(time.Second)
+	sm := state.ServerMetrics()
+	assert.Greater(t, sm.QPS, 0.0)
+	assert.Greater(t, sm.EPS, 0.0)
+	assert.InDelta(t, 5.0, sm.QPS/sm.EPS, 1e-9, "failed calls count as queries too")
+}
+
+func TestServeWhileServingClosesListener(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	state := admin.New(pb.EchoService_ServiceDesc.ServiceName)
+	server := grpc.NewServer(state.ServerOptions()...)
+	pb.RegisterEchoServiceServer(server, echoServer{})
+	served := make(chan error, 1)
+	go func() { served <- state.ServeWhileServing(server, addr) }()
+
+	dials := func() bool {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}
+	require.Eventually(t, dials, 5*time.Second, 10*time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewEchoServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.Echo(ctx, &pb.EchoRequest{Message: "before"})
+	require.NoError(t, err)
+
+	state.SetServing(false)
+	assert.False(t, dials(), "a NOT_SERVING instance should refuse connections")
+	_, err = client.Echo(ctx, &pb.EchoRequest{Message: "established"})
+	assert.NoError(t, err, "established connections should keep working")
+
+	state.SetServing(true)
+	require.Eventually(t, dials, 5*time.Second, 10*time.Millisecond)
+
+	server.Stop()
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeWhileServing should return once the server stops")
+	}
+}
+
+func decodeReport(t *testing.T, trailer metadata.MD) *orcapb.OrcaLoadReport {
+	t.Helper()
+	values := trailer.Get(loadReportKey)
+	require.Len(t, values, 1, "the trailer should carry a load report")
+	report := &orcapb.OrcaLoadReport{}
+	require.NoError(t, proto.Unmarshal([]byte(values[0]), report))
+	return report
+}
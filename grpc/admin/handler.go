@@ -0,0 +1,119 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+import (
+	"google.golang.org/grpc/orca"
+)
+
+// Handler serves the HTTP endpoint of the instance:
+//
+//	GET  /health                        current serving status
+//	POST /health                        flip between SERVING and NOT_SERVING
+//	POST /health?status=NOT_SERVING     set the serving status
+//	GET  /load                          current load report
+//	POST /load?cpu=0.9&mem=0.5&app=0.7  pin utilization values
+//	POST /load                          drop the pinned values
+func (a *Admin) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			switch st := r.URL.Query().Get("status"); st {
+			case "":
+				a.SetServing(!a.Serving())
+			case "SERVING", "NOT_SERVING":
+				a.SetServing(st == "SERVING")
+			default:
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown status %q", st)})
+				return
+			}
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		writeHealth(w, a)
+	})
+	mux.HandleFunc("/load", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			q := r.URL.Query()
+			values := make([]float64, 3)
+			for i, name := range []string{"cpu", "mem", "app"} {
+				values[i] = -1
+				if v := q.Get(name); v != "" {
+					f, err := strconv.ParseFloat(v, 64)
+					if err != nil || f < 0 {
+						writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid %s %q", name, v)})
+						return
+					}
+					values[i] = f
+				}
+			}
+			a.Pin(values[0], values[1], values[2])
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, loadReport(a.ServerMetrics()))
+	})
+	return mux
+}
+
+func writeHealth(w http.ResponseWriter, a *Admin) {
+	st := "NOT_SERVING"
+	if a.Serving() {
+		st = "SERVING"
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": st})
+}
+
+// loadReport lists the metrics that are set, under their ORCA field names.
+func loadReport(sm *orca.ServerMetrics) map[string]interface{} {
+	out := map[string]interface{}{}
+	for name, v := range map[string]float64{
+		"cpu_utilization":         sm.CPUUtilization,
+		"mem_utilization":         sm.MemUtilization,
+		"application_utilization": sm.AppUtilization,
+		"rps_fractional":          sm.QPS,
+		"eps":                     sm.EPS,
+	} {
+		if v >= 0 {
+			out[name] = v
+		}
+	}
+	if len(sm.Utilization) > 0 {
+		out["utilization"] = sm.Utilization
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,241 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fault lets the sample gRPC servers misbehave on request, so the way
+// Pixiu's dgp.filter.grpc.proxy relays status codes, metadata, deadlines,
+// large messages and aborted streams can be tested end to end.
+//
+// Faults are selected per call by request metadata and applied by server
+// interceptors, so every method of a service supports them:
+//
+//	x-fault-status: NOT_FOUND           fail with this code (name or number)
+//	x-fault-message: text               message of the injected status
+//	x-fault-header: name=value          add a response header, repeatable
+//	x-fault-trailer: name=value         add a response trailer, repeatable
+//	x-fault-delay: 500ms                wait before handling the call
+//	x-fault-stall: true                 block until the deadline or cancellation
+//	x-fault-payload-bytes: 1048576      pad every response to about this size
+//	x-fault-abort-after: 3              fail a stream after sending this many messages
+//	x-fault-id: name                    record how the call ended under this name
+//	x-fault-outcome-of: name            report a recorded outcome in the x-fault-outcome trailer
+package fault
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Request metadata keys.
+const (
+	StatusKey     = "x-fault-status"
+	MessageKey    = "x-fault-message"
+	HeaderKey     = "x-fault-header"
+	TrailerKey    = "x-fault-trailer"
+	DelayKey      = "x-fault-delay"
+	StallKey      = "x-fault-stall"
+	PayloadKey    = "x-fault-payload-bytes"
+	AbortAfterKey = "x-fault-abort-after"
+	IDKey         = "x-fault-id"
+	OutcomeOfKey  = "x-fault-outcome-of"
+
+	// OutcomeKey is the response trailer answering x-fault-outcome-of.
+	OutcomeKey = "x-fault-outcome"
+)
+
+const (
+	// ErrorDomain is the domain of the ErrorInfo attached to injected errors.
+	ErrorDomain = "samples.dubbo-go-pixiu.apache.org"
+	// ErrorReason is the reason of the ErrorInfo attached to injected errors.
+	ErrorReason = "FAULT_INJECTED"
+
+	// MaxPayloadBytes caps x-fault-payload-bytes.
+	MaxPayloadBytes = 64 << 20
+	// stallLimit ends a stalled call whose client set no deadline.
+	stallLimit = 30 * time.Second
+	// retryDelay is advertised in the RetryInfo of retryable injected errors.
+	retryDelay = time.Second
+)
+
+// spec is the fault requested by the metadata of one call.
+type spec struct {
+	code       codes.Code
+	hasCode    bool
+	message    string
+	headers    metadata.MD
+	trailers   metadata.MD
+	delay      time.Duration
+	stall      bool
+	payload    int
+	abortAfter int
+	id         string
+	outcomeOf  string
+}
+
+// parseSpec reads the fault keys of md. Malformed values are reported, so a
+// typo in a test does not silently turn into a passing happy path.
+func parseSpec(md metadata.MD) (*spec, error) {
+	s := &spec{headers: metadata.MD{}, trailers: metadata.MD{}, abortAfter: -1}
+	if v := last(md, StatusKey); v != "" {
+		code, err := ParseCode(v)
+		if err != nil {
+			return nil, err
+		}
+		s.code, s.hasCode = code, true
+	}
+	s.message = last(md, MessageKey)
+	for key, target := range map[string]metadata.MD{HeaderKey: s.headers, TrailerKey: s.trailers} {
+		for _, v := range md.Get(key) {
+			name, value, ok := strings.Cut(v, "=")
+			if !ok || name == "" {
+				return nil, fmt.Errorf("%s: expected name=value, got %q", key, v)
+			}
+			target.Append(strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value))
+		}
+	}
+	if v := last(md, DelayKey); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", DelayKey, err)
+		}
+		s.delay = d
+	}
+	if v := last(md, StallKey); v != "" {
+		stall, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", StallKey, err)
+		}
+		s.stall = stall
+	}
+	if v := last(md, PayloadKey); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > MaxPayloadBytes {
+			return nil, fmt.Errorf("%s: expected 0 to %d, got %q", PayloadKey, MaxPayloadBytes, v)
+		}
+		s.payload = n
+	}
+	if v := last(md, AbortAfterKey); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s: expected a message count, got %q", AbortAfterKey, v)
+		}
+		s.abortAfter = n
+	}
+	s.id = last(md, IDKey)
+	s.outcomeOf = last(md, OutcomeOfKey)
+	return s, nil
+}
+
+func last(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// ParseCode accepts a code by number or by name, as NOT_FOUND or NotFound.
+func ParseCode(v string) (codes.Code, error) {
+	if n, err := strconv.ParseUint(v, 10, 32); err == nil {
+		if n > uint64(codes.Unauthenticated) {
+			return 0, fmt.Errorf("%s: unknown code %d", StatusKey, n)
+		}
+		return codes.Code(n), nil
+	}
+	name := strings.ToLower(strings.ReplaceAll(v, "_", ""))
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.ToLower(c.String()) == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: unknown code %q", StatusKey, v)
+}
+
+// wait applies the delay and the stall. It returns the context's status when
+// the call ended while waiting.
+func (s *spec) wait(ctx context.Context) error {
+	if s.delay > 0 {
+		select {
+		case <-time.After(s.delay):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if s.stall {
+		select {
+		case <-time.After(stallLimit):
+			return status.Errorf(codes.DeadlineExceeded, "stalled for %s without a client deadline", stallLimit)
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return nil
+}
+
+// err builds the injected status with google.rpc error details: always an
+// ErrorInfo naming the method, plus the detail clients expect for the code.
+func (s *spec) err(method string) error {
+	message := s.message
+	if message == "" {
+		message = "injected " + s.code.String()
+	}
+	st := status.New(s.code, message)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   ErrorReason,
+		Domain:   ErrorDomain,
+		Metadata: map[string]string{"method": method},
+	}}
+	switch s.code {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(retryDelay)})
+	case codes.InvalidArgument, codes.OutOfRange:
+		details = append(details, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: StatusKey, Description: "fault injected by request metadata"}},
+		})
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+type payloadKey struct{}
+
+// PayloadSize returns the response size requested by x-fault-payload-bytes,
+// or 0. Handlers pass it to Padding to grow their responses.
+func PayloadSize(ctx context.Context) int {
+	n, _ := ctx.Value(payloadKey{}).(int)
+	return n
+}
+
+// Padding returns a filler string of PayloadSize(ctx) bytes.
+func Padding(ctx context.Context) string {
+	return strings.Repeat("x", PayloadSize(ctx))
+}
@@ -0,0 +1,263 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fault_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/grpc/fault"
+	pb "github.com/dubbo-go-pixiu/samples/grpc/reflection/proto"
+)
+
+// echoServer is a minimal EchoService that pads its responses on request.
+type echoServer struct {
+	pb.UnimplementedEchoServiceServer
+}
+
+func (echoServer) Echo(ctx context.Context, req *pb.EchoRequest) (*pb.EchoResponse, error) {
+	return &pb.EchoResponse{Message: req.Message + fault.Padding(ctx)}, nil
+}
+
+func (echoServer) StreamEcho(req *pb.EchoRequest, stream pb.EchoService_StreamEchoServer) error {
+	for i := 0; i < 5; i++ {
+		if err := stream.Send(&pb.EchoResponse{Message: req.Message + fault.Padding(stream.Context())}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (echoServer) BidirectionalEcho(stream pb.EchoService_BidirectionalEchoServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&pb.EchoResponse{Message: req.Message}); err != nil {
+			return err
+		}
+	}
+}
+
+func newClient(t *testing.T) pb.EchoServiceClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(fault.UnaryServerInterceptor),
+		grpc.StreamInterceptor(fault.StreamServerInterceptor),
+	)
+	pb.RegisterEchoServiceServer(server, echoServer{})
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewEchoServiceClient(conn)
+}
+
+func withFault(ctx context.Context, kv ...string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+func TestInjectedStatus(t *testing.T) {
+	client := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.Echo(withFault(ctx, fault.StatusKey, "NOT_FOUND", fault.MessageKey, "no such echo"), &pb.EchoRequest{})
+	st := status.Convert(err)
+	assert.Equal(t, codes.NotFound, st.Code())
+	assert.Equal(t, "no such echo", st.Message())
+	require.Len(t, st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok, "expected an ErrorInfo, got %T", st.Details()[0])
+	assert.Equal(t, fault.ErrorReason, info.Reason)
+	assert.Equal(t, "/echo.EchoService/Echo", info.Metadata["method"])
+
+	_, err = client.Echo(withFault(ctx, fault.StatusKey, "14"), &pb.EchoRequest{})
+	st = status.Convert(err)
+	assert.Equal(t, codes.Unavailable, st.Code())
+	require.Len(t, st.Details(), 2)
+	retry, ok := st.Details()[1].(*errdetails.RetryInfo)
+	require.True(t, ok, "expected a RetryInfo, got %T", st.Details()[1])
+	assert.Equal(t, time.Second, retry.RetryDelay.AsDuration())
+
+	_, err = client.Echo(withFault(ctx, fault.StatusKey, "NO_SUCH_CODE"), &pb.EchoRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "a malformed fault must not pass silently")
+}
+
+func TestHeadersAndTrailers(t *testing.T) {
+	client := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, code := range []string{"", "PERMISSION_DENIED"} {
+		var header, trailer metadata.MD
+		kv := []string{fault.HeaderKey, "x-served-by=fault", fault.TrailerKey, "x-checksum=abc", fault.TrailerKey, "x-count=2"}
+		if code != "" {
+			kv = append(kv, fault.StatusKey, code)
+		}
+		_, err := client.Echo(withFault(ctx, kv...), &pb.EchoRequest{Message: "hi"}, grpc.Header(&header), grpc.Trailer(&trailer))
+		if code == "" {
+			require.NoError(t, err)
+		} else {
+			require.Equal(t, codes.PermissionDenied, status.Code(err))
+		}
+		// An error without messages comes back as a trailers-only response
+		// that carries the headers too.
+		assert.Equal(t, []string{"fault"}, append(header.Get("x-served-by"), trailer.Get("x-served-by")...), "status %q", code)
+		assert.Equal(t, []string{"abc"}, trailer.Get("x-checksum"), "status %q", code)
+		assert.Equal(t, []string{"2"}, trailer.Get("x-count"), "status %q", code)
+	}
+}
+
+func TestStallUntilDeadline(t *testing.T) {
+	client := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.Echo(withFault(ctx, fault.StallKey, "true", fault.IDKey, "stall-deadline"), &pb.EchoRequest{})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, "deadline_exceeded", waitOutcome(t, client, "stall-deadline"))
+}
+
+func TestCancelMidStream(t *testing.T) {
+	client := newClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.BidirectionalEcho(withFault(ctx, fault.IDKey, "bidi-cancel"))
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.EchoRequest{Message: "one"}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	cancel()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Equal(t, "canceled", waitOutcome(t, client, "bidi-cancel"))
+}
+
+func TestAbortMidStream(t *testing.T) {
+	client := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var trailer metadata.MD
+	stream, err := client.StreamEcho(withFault(ctx, fault.AbortAfterKey, "2", fault.TrailerKey, "x-sent=2"), &pb.EchoRequest{Message: "m"})
+	require.NoError(t, err)
+	received := 0
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+		received++
+	}
+	trailer = stream.Trailer()
+	assert.Equal(t, 2, received)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	assert.Equal(t, []string{"2"}, trailer.Get("x-sent"))
+
+	stream, err = client.StreamEcho(withFault(ctx, fault.AbortAfterKey, "0", fault.StatusKey, "DATA_LOSS"), &pb.EchoRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.DataLoss, status.Code(err))
+}
+
+func TestLargePayload(t *testing.T) {
+	client := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := client.Echo(withFault(ctx, fault.PayloadKey, "8388608"), &pb.EchoRequest{Message: "big"},
+		grpc.MaxCallRecvMsgSize(16<<20))
+	require.NoError(t, err)
+	assert.Len(t, resp.Message, 3+8<<20)
+
+	_, err = client.Echo(withFault(ctx, fault.PayloadKey, "8388608"), &pb.EchoRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "the default 4 MiB receive limit applies")
+
+	stream, err := client.StreamEcho(withFault(ctx, fault.PayloadKey, "1048576"), &pb.EchoRequest{})
+	require.NoError(t, err)
+	total := 0
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		total += len(resp.Message)
+	}
+	assert.Equal(t, 5<<20, total)
+}
+
+func TestParseCode(t *testing.T) {
+	for in, want := range map[string]codes.Code{"NOT_FOUND": codes.NotFound, "NotFound": codes.NotFound, "5": codes.NotFound, "ok": codes.OK} {
+		got, err := fault.ParseCode(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"17", "-1", "missing"} {
+		_, err := fault.ParseCode(in)
+		assert.Error(t, err, in)
+	}
+}
+
+// waitOutcome polls the outcome the server recorded for id; it is written
+// once the server side of the call has returned.
+func waitOutcome(t *testing.T, client pb.EchoServiceClient, id string) string {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		var trailer metadata.MD
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := client.Echo(withFault(ctx, fault.OutcomeOfKey, id), &pb.EchoRequest{}, grpc.Trailer(&trailer))
+		cancel()
+		require.NoError(t, err)
+		if outcome := trailer.Get(fault.OutcomeKey); len(outcome) > 0 && outcome[0] != "" {
+			return outcome[0]
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("no outcome recorded for %s", id)
+	return ""
+}
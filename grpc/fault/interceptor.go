@@ -0,0 +1,183 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fault
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxOutcomes bounds how many recorded outcomes are kept.
+	maxOutcomes = 256
+	// deadlineSlack is how close to its deadline a canceled call counts as
+	// having run into the deadline.
+	deadlineSlack = 100 * time.Millisecond
+)
+
+// outcomeLog remembers how calls carrying x-fault-id ended, so a test can
+// check what the backend saw after the gateway relayed a deadline or a
+// cancellation.
+type outcomeLog struct {
+	mu      sync.Mutex
+	byID    map[string]string
+	ordered []string
+}
+
+var outcomes = &outcomeLog{byID: map[string]string{}}
+
+func (l *outcomeLog) record(id string, outcome string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.byID[id]; !ok {
+		l.ordered = append(l.ordered, id)
+		if len(l.ordered) > maxOutcomes {
+			delete(l.byID, l.ordered[0])
+			l.ordered = l.ordered[1:]
+		}
+	}
+	l.byID[id] = outcome
+}
+
+func (l *outcomeLog) get(id string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.byID[id]
+}
+
+// outcome names how a call ended: canceled or deadline_exceeded when the
+// client gave up, otherwise the status code the handler returned.
+func outcome(ctx context.Context, err error) string {
+	switch ctx.Err() {
+	case context.Canceled:
+		// A client whose deadline fires resets the stream, which may arrive
+		// just before the server's own deadline timer.
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < deadlineSlack {
+			return "deadline_exceeded"
+		}
+		return "canceled"
+	case context.DeadlineExceeded:
+		return "deadline_exceeded"
+	}
+	return status.Code(err).String()
+}
+
+// prepare parses the fault of an incoming call and sends the headers and
+// trailers it asks for.
+func prepare(ctx context.Context, setHeader func(metadata.MD) error, setTrailer func(metadata.MD)) (*spec, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s, err := parseSpec(md)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if len(s.headers) > 0 {
+		if err := setHeader(s.headers); err != nil {
+			return nil, err
+		}
+	}
+	trailers := s.trailers.Copy()
+	if s.outcomeOf != "" {
+		trailers.Set(OutcomeKey, outcomes.get(s.outcomeOf))
+	}
+	if len(trailers) > 0 {
+		setTrailer(trailers)
+	}
+	return s, nil
+}
+
+// UnaryServerInterceptor applies the requested fault to unary calls.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	s, err := prepare(ctx,
+		func(md metadata.MD) error { return grpc.SetHeader(ctx, md) },
+		func(md metadata.MD) { _ = grpc.SetTrailer(ctx, md) })
+	if err != nil {
+		return nil, err
+	}
+	if s.id != "" {
+		defer func() { outcomes.record(s.id, outcome(ctx, err)) }()
+	}
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	if s.hasCode {
+		return nil, s.err(info.FullMethod)
+	}
+	return handler(context.WithValue(ctx, payloadKey{}, s.payload), req)
+}
+
+// StreamServerInterceptor applies the requested fault to streaming calls. With
+// x-fault-abort-after the stream fails once that many messages were sent,
+// with x-fault-status as its code or ABORTED.
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	ctx := ss.Context()
+	s, err := prepare(ctx, ss.SetHeader, ss.SetTrailer)
+	if err != nil {
+		return err
+	}
+	if s.id != "" {
+		defer func() { outcomes.record(s.id, outcome(ctx, err)) }()
+	}
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	if s.abortAfter < 0 {
+		if s.hasCode {
+			return s.err(info.FullMethod)
+		}
+		return handler(srv, &faultStream{ServerStream: ss, ctx: context.WithValue(ctx, payloadKey{}, s.payload)})
+	}
+	if !s.hasCode {
+		s.code, s.hasCode = codes.Aborted, true
+	}
+	return handler(srv, &faultStream{
+		ServerStream: ss,
+		ctx:          context.WithValue(ctx, payloadKey{}, s.payload),
+		spec:         s,
+		method:       info.FullMethod,
+	})
+}
+
+// faultStream carries the payload size to the handler and, with a spec,
+// fails the stream after spec.abortAfter sent messages.
+type faultStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	spec   *spec
+	method string
+	sent   int
+}
+
+func (f *faultStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *faultStream) SendMsg(m interface{}) error {
+	if f.spec != nil && f.sent >= f.spec.abortAfter {
+		return f.spec.err(f.method)
+	}
+	f.sent++
+	return f.ServerStream.SendMsg(m)
+}
@@ -0,0 +1,90 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package main runs two EchoService instances behind one Pixiu cluster, each
+// with its own health state, load reports and HTTP admin endpoint, so the
+// tests can take one instance out of rotation and bring it back.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+import (
+	"google.golang.org/grpc"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/grpc/admin"
+	pb "github.com/dubbo-go-pixiu/samples/grpc/reflection/proto"
+)
+
+// instance is one backend of the cluster in pixiu/conf.yaml.
+type instance struct {
+	id        string
+	port      int
+	adminPort int
+}
+
+var instances = []instance{
+	{id: "instance-1", port: 50051, adminPort: 18081},
+	{id: "instance-2", port: 50052, adminPort: 18082},
+}
+
+// echoServer answers with the ID of the instance that took the call.
+type echoServer struct {
+	pb.UnimplementedEchoServiceServer
+	serverID string
+}
+
+func (s *echoServer) Echo(_ context.Context, req *pb.EchoRequest) (*pb.EchoResponse, error) {
+	return &pb.EchoResponse{Message: req.Message, ServerId: s.serverID}, nil
+}
+
+// serve runs one instance until its gRPC server fails.
+func serve(in instance) error {
+	state := admin.New(pb.EchoService_ServiceDesc.ServiceName)
+	grpcServer := grpc.NewServer(state.ServerOptions()...)
+	pb.RegisterEchoServiceServer(grpcServer, &echoServer{serverID: in.id})
+	if err := state.Register(grpcServer); err != nil {
+		return err
+	}
+	go func() {
+		log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", in.adminPort), state.Handler()))
+	}()
+	log.Printf("%s listening on port %d, admin endpoint on port %d", in.id, in.port, in.adminPort)
+	// Pixiu only checks the TCP port, so close it while NOT_SERVING
+	return state.ServeWhileServing(grpcServer, fmt.Sprintf(":%d", in.port))
+}
+
+func main() {
+	var wg sync.WaitGroup
+	for _, in := range instances {
+		wg.Add(1)
+		go func(in instance) {
+			defer wg.Done()
+			if err := serve(in); err != nil {
+				log.Fatalf("%s: %v", in.id, err)
+			}
+		}(in)
+	}
+	wg.Wait()
+}
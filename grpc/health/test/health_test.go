@@ -0,0 +1,164 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+import (
+	orcapb "github.com/cncf/xds/go/xds/data/orca/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"google.golang.org/protobuf/proto"
+)
+
+import (
+	pb "github.com/dubbo-go-pixiu/samples/grpc/reflection/proto"
+)
+
+const (
+	pixiuAddr = "localhost:8881"
+	// loadReportKey is the trailer carrying per-call ORCA load reports.
+	loadReportKey = "endpoint-load-metrics-bin"
+	// ejectTimeout bounds how long Pixiu's health checks take to react.
+	ejectTimeout = 30 * time.Second
+)
+
+// adminURLs are the HTTP endpoints of the two instances run by server/app.
+var adminURLs = map[string]string{
+	"instance-1": "http://localhost:18081",
+	"instance-2": "http://localhost:18082",
+}
+
+func getClient(t *testing.T) pb.EchoServiceClient {
+	t.Helper()
+	conn, err := grpc.NewClient(pixiuAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "Failed to connect to Pixiu gateway")
+	t.Cleanup(func() { conn.Close() })
+	// return every instance to SERVING with no pinned load when the test ends
+	t.Cleanup(func() {
+		for _, url := range adminURLs {
+			adminPost(t, url+"/health?status=SERVING")
+			adminPost(t, url+"/load")
+		}
+	})
+	return pb.NewEchoServiceClient(conn)
+}
+
+func adminPost(t *testing.T, url string) {
+	t.Helper()
+	resp, err := http.Post(url, "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, http.StatusOK, resp.StatusCode, "%s: %v", url, body)
+}
+
+// servedBy makes n calls through Pixiu and counts the instances answering.
+// Calls must keep succeeding while an instance is ejected.
+func servedBy(client pb.EchoServiceClient, n int) (map[string]int, error) {
+	seen := map[string]int{}
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		resp, err := client.Echo(ctx, &pb.EchoRequest{Message: fmt.Sprintf("health %d", i)})
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		seen[resp.ServerId]++
+	}
+	return seen, nil
+}
+
+func TestHealthCheckEjectsAndReadmits(t *testing.T) {
+	client := getClient(t)
+
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		seen, err := servedBy(client, 10)
+		if assert.NoError(c, err) {
+			assert.Positive(c, seen["instance-1"])
+			assert.Positive(c, seen["instance-2"])
+		}
+	}, ejectTimeout, 500*time.Millisecond, "both instances should take traffic")
+
+	adminPost(t, adminURLs["instance-2"]+"/health?status=NOT_SERVING")
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		seen, err := servedBy(client, 10)
+		if assert.NoError(c, err) {
+			assert.Zero(c, seen["instance-2"])
+		}
+	}, ejectTimeout, 500*time.Millisecond, "the NOT_SERVING instance should be ejected")
+	seen, err := servedBy(client, 20)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"instance-1": 20}, seen, "the ejected instance should stay out")
+
+	adminPost(t, adminURLs["instance-2"]+"/health?status=SERVING")
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		seen, err := servedBy(client, 10)
+		if assert.NoError(c, err) {
+			assert.Positive(c, seen["instance-2"])
+		}
+	}, ejectTimeout, 500*time.Millisecond, "the instance should be re-admitted once SERVING again")
+}
+
+func TestLoadReportsThroughProxy(t *testing.T) {
+	client := getClient(t)
+
+	pinned := map[string]float64{"instance-1": 0.2, "instance-2": 0.8}
+	for id, cpu := range pinned {
+		adminPost(t, fmt.Sprintf("%s/load?cpu=%g", adminURLs[id], cpu))
+	}
+
+	reports := map[string]*orcapb.OrcaLoadReport{}
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		var trailer metadata.MD
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		resp, err := client.Echo(ctx, &pb.EchoRequest{Message: "load"}, grpc.Trailer(&trailer))
+		if !assert.NoError(c, err) {
+			return
+		}
+		values := trailer.Get(loadReportKey)
+		if !assert.Len(c, values, 1, "Pixiu should relay the load report trailer") {
+			return
+		}
+		report := &orcapb.OrcaLoadReport{}
+		if assert.NoError(c, proto.Unmarshal([]byte(values[0]), report)) {
+			reports[resp.ServerId] = report
+		}
+		assert.Len(c, reports, len(pinned), "both instances should answer")
+	}, ejectTimeout, 100*time.Millisecond)
+
+	for id, cpu := range pinned {
+		assert.InDelta(t, cpu, reports[id].CpuUtilization, 1e-9, id)
+		assert.GreaterOrEqual(t, reports[id].NamedMetrics["in_flight"], 1.0, id)
+	}
+}
@@ -17,6 +17,8 @@
 
 // Package main implements a gRPC server with Server Reflection enabled.
 // This demonstrates the gRPC Server Reflection feature for dynamic message parsing.
+// Request metadata can make any call fail, stall or grow its responses, see package fault.
+// It also serves grpc.health.v1 and ORCA load reports, see package admin.
 package main
 
 import (
@@ -26,6 +28,7 @@ import (
 	"io"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"time"
 )
@@ -36,12 +39,15 @@ import (
 )
 
 import (
+	"github.com/dubbo-go-pixiu/samples/grpc/admin"
+	"github.com/dubbo-go-pixiu/samples/grpc/fault"
 	pb "github.com/dubbo-go-pixiu/samples/grpc/reflection/proto"
 )
 
 var (
-	port     = flag.Int("port", 50051, "The server port")
-	serverID = flag.String("server_id", "", "Server identifier for load balancing verification")
+	port      = flag.Int("port", 50051, "The server port")
+	serverID  = flag.String("server_id", "", "Server identifier for load balancing verification")
+	adminPort = flag.Int("admin_port", 0, "The port of the HTTP endpoint toggling health and load, 0 to disable")
 )
 
 // echoServer implements the EchoService.
@@ -59,7 +65,7 @@ func (s *echoServer) Echo(ctx context.Context, req *pb.EchoRequest) (*pb.EchoRes
 		ServerTimestamp:   time.Now().UnixNano(),
 		ReflectionEnabled: true, // This server has reflection enabled
 		ServerId:          s.serverID,
-		Metadata:          req.Metadata,
+		Metadata:          withPadding(ctx, req.Metadata),
 	}, nil
 }
 
@@ -74,7 +80,7 @@ func (s *echoServer) StreamEcho(req *pb.EchoRequest, stream pb.EchoService_Strea
 			ServerTimestamp:   time.Now().UnixNano(),
 			ReflectionEnabled: true,
 			ServerId:          s.serverID,
-			Metadata:          req.Metadata,
+			Metadata:          withPadding(stream.Context(), req.Metadata),
 		}
 		if err := stream.Send(resp); err != nil {
 			return err
@@ -133,7 +139,7 @@ func (s *echoServer) BidirectionalEcho(stream pb.EchoService_BidirectionalEchoSe
 			ServerTimestamp:   time.Now().UnixNano(),
 			ReflectionEnabled: true,
 			ServerId:          s.serverID,
-			Metadata:          req.Metadata,
+			Metadata:          withPadding(stream.Context(), req.Metadata),
 		}
 		if err := stream.Send(resp); err != nil {
 			return err
@@ -141,6 +147,20 @@ func (s *echoServer) BidirectionalEcho(stream pb.EchoService_BidirectionalEchoSe
 	}
 }
 
+// withPadding adds the filler requested by x-fault-payload-bytes to the
+// echoed metadata under the "padding" key.
+func withPadding(ctx context.Context, md map[string]string) map[string]string {
+	if fault.PayloadSize(ctx) == 0 {
+		return md
+	}
+	out := make(map[string]string, len(md)+1)
+	for k, v := range md {
+		out[k] = v
+	}
+	out["padding"] = fault.Padding(ctx)
+	return out
+}
+
 func getServerID() string {
 	if *serverID != "" {
 		return *serverID
@@ -161,8 +181,14 @@ func main() {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	// Create gRPC server with default options
-	grpcServer := grpc.NewServer()
+	// Create gRPC server that reports its load and whose calls can be made
+	// to fail through request metadata
+	state := admin.New(pb.EchoService_ServiceDesc.ServiceName)
+	opts := append(state.ServerOptions(),
+		grpc.ChainUnaryInterceptor(fault.UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(fault.StreamServerInterceptor),
+	)
+	grpcServer := grpc.NewServer(opts...)
 
 	// Register the EchoService
 	pb.RegisterEchoServiceServer(grpcServer, &echoServer{
@@ -173,6 +199,17 @@ func main() {
 	// This allows Pixiu to dynamically discover and parse service methods
 	reflection.Register(grpcServer)
 
+	// Health checks and out-of-band load reports
+	if err := state.Register(grpcServer); err != nil {
+		log.Fatalf("failed to register admin services: %v", err)
+	}
+	if *adminPort != 0 {
+		go func() {
+			log.Printf("Admin endpoint listening on port %d", *adminPort)
+			log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", *adminPort), state.Handler()))
+		}()
+	}
+
 	log.Printf("gRPC server with reflection enabled listening on port %d", *port)
 	log.Printf("Server ID: %s", getServerID())
 
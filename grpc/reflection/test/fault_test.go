@@ -0,0 +1,210 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/grpc/fault"
+	pb "github.com/dubbo-go-pixiu/samples/grpc/reflection/proto"
+)
+
+// faultID makes the outcome names unique across runs against the same server.
+func faultID(name string) string {
+	return fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+}
+
+// TestFaultStatusDetails verifies that the reflection-based proxy relays the
+// code, message and google.rpc.Status details of a failed call unchanged.
+func TestFaultStatusDetails(t *testing.T) {
+	client, cleanup := getClient(t)
+	defer cleanup()
+
+	for _, code := range []codes.Code{codes.NotFound, codes.ResourceExhausted, codes.OutOfRange, codes.Unauthenticated} {
+		t.Run(code.String(), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ctx = metadata.AppendToOutgoingContext(ctx, fault.StatusKey, fmt.Sprint(uint32(code)), fault.MessageKey, "injected by test")
+
+			_, err := client.Echo(ctx, &pb.EchoRequest{Message: "fail"})
+			st := status.Convert(err)
+			assert.Equal(t, code, st.Code())
+			assert.Equal(t, "injected by test", st.Message())
+			require.NotEmpty(t, st.Details(), "status details were dropped")
+			info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+			require.True(t, ok, "expected an ErrorInfo, got %T", st.Details()[0])
+			assert.Equal(t, "/echo.EchoService/Echo", info.Metadata["method"])
+		})
+	}
+}
+
+// TestFaultHeadersAndTrailers verifies that custom headers and trailers of
+// unary and streaming calls reach the client.
+func TestFaultHeadersAndTrailers(t *testing.T) {
+	client, cleanup := getClient(t)
+	defer cleanup()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, fault.HeaderKey, "x-echo=header", fault.TrailerKey, "x-echo-trailer=done")
+
+	var header, trailer metadata.MD
+	_, err := client.Echo(ctx, &pb.EchoRequest{Message: "meta"}, grpc.Header(&header), grpc.Trailer(&trailer))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"header"}, header.Get("x-echo"))
+	assert.Equal(t, []string{"done"}, trailer.Get("x-echo-trailer"))
+
+	stream, err := client.StreamEcho(ctx, &pb.EchoRequest{Message: "meta"})
+	require.NoError(t, err)
+	header, err = stream.Header()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"header"}, header.Get("x-echo"))
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else {
+			require.NoError(t, err)
+		}
+	}
+	assert.Equal(t, []string{"done"}, stream.Trailer().Get("x-echo-trailer"))
+}
+
+// TestFaultDeadline verifies that the client deadline reaches the backend.
+func TestFaultDeadline(t *testing.T) {
+	client, cleanup := getClient(t)
+	defer cleanup()
+	id := faultID("deadline")
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, fault.StallKey, "true", fault.IDKey, id)
+
+	start := time.Now()
+	_, err := client.Echo(ctx, &pb.EchoRequest{Message: "stall"})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Less(t, time.Since(start), 3*time.Second, "the gateway kept the call open past the deadline")
+	assert.Equal(t, "deadline_exceeded", waitOutcome(t, client, id))
+}
+
+// TestFaultCancellation verifies that canceling a bidirectional stream at the
+// client cancels the backend call.
+func TestFaultCancellation(t *testing.T) {
+	client, cleanup := getClient(t)
+	defer cleanup()
+	id := faultID("cancel")
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = metadata.AppendToOutgoingContext(ctx, fault.IDKey, id)
+
+	stream, err := client.BidirectionalEcho(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.EchoRequest{Message: "ping"}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	cancel()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Equal(t, "canceled", waitOutcome(t, client, id))
+}
+
+// TestFaultLargeMessages verifies that large responses, which the proxy has
+// to decode through reflection, pass the gateway intact.
+func TestFaultLargeMessages(t *testing.T) {
+	client, cleanup := getClient(t)
+	defer cleanup()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	const unarySize = 3 << 20
+	resp, err := client.Echo(metadata.AppendToOutgoingContext(ctx, fault.PayloadKey, fmt.Sprint(unarySize)), &pb.EchoRequest{Message: "big"})
+	require.NoError(t, err)
+	assert.Equal(t, "big", resp.Message)
+	assert.Len(t, resp.Metadata["padding"], unarySize)
+
+	const streamSize = 1 << 20
+	stream, err := client.StreamEcho(metadata.AppendToOutgoingContext(ctx, fault.PayloadKey, fmt.Sprint(streamSize)), &pb.EchoRequest{Message: "big"})
+	require.NoError(t, err)
+	count := 0
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.Len(t, resp.Metadata["padding"], streamSize)
+		count++
+	}
+	assert.Equal(t, 5, count)
+}
+
+// TestFaultAbortMidStream verifies that a stream failing after some messages
+// delivers those messages and then the status.
+func TestFaultAbortMidStream(t *testing.T) {
+	client, cleanup := getClient(t)
+	defer cleanup()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, fault.AbortAfterKey, "2")
+
+	stream, err := client.StreamEcho(ctx, &pb.EchoRequest{Message: "abort"})
+	require.NoError(t, err)
+	received := 0
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+		received++
+	}
+	assert.Equal(t, 2, received)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
+
+// waitOutcome polls the outcome the server recorded for id.
+func waitOutcome(t *testing.T, client pb.EchoServiceClient, id string) string {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		var trailer metadata.MD
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err := client.Echo(metadata.AppendToOutgoingContext(ctx, fault.OutcomeOfKey, id), &pb.EchoRequest{}, grpc.Trailer(&trailer))
+		cancel()
+		require.NoError(t, err)
+		if outcome := trailer.Get(fault.OutcomeKey); len(outcome) > 0 && outcome[0] != "" {
+			return outcome[0]
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("the backend recorded no outcome for %s", id)
+	return ""
+}
@@ -19,6 +19,8 @@
 // to perform unary, client streaming, server streaming and full duplex RPCs.
 //
 // It implements the route guide service whose definition can be found in routeguide/route_guide.proto.
+// Request metadata can make any call fail, stall or grow its responses, see package fault.
+// It also serves grpc.health.v1 and ORCA load reports, see package admin.
 package main
 
 import (
@@ -30,6 +32,7 @@ import (
 	"log"
 	"math"
 	"net"
+	"net/http"
 	"os"
 	"sync"
 	"time"
@@ -42,12 +45,15 @@ import (
 )
 
 import (
+	"github.com/dubbo-go-pixiu/samples/grpc/admin"
+	"github.com/dubbo-go-pixiu/samples/grpc/fault"
 	pb "github.com/dubbo-go-pixiu/samples/grpc/simple/routeguide"
 )
 
 var (
 	jsonDBFile = flag.String("json_db_file", "grpc/simple/server/route_guide_db.json", "A json file containing a list of features")
 	port       = flag.Int("port", 50051, "The server port")
+	adminPort  = flag.Int("admin_port", 0, "The port of the HTTP endpoint toggling health and load, 0 to disable")
 )
 
 type routeGuideServer struct {
@@ -59,21 +65,21 @@ type routeGuideServer struct {
 }
 
 // GetFeature returns the feature at the given point.
-func (s *routeGuideServer) GetFeature(_ context.Context, point *pb.Point) (*pb.Feature, error) {
+func (s *routeGuideServer) GetFeature(ctx context.Context, point *pb.Point) (*pb.Feature, error) {
 	for _, feature := range s.savedFeatures {
 		if proto.Equal(feature.Location, point) {
-			return feature, nil
+			return padded(ctx, feature), nil
 		}
 	}
 	// No feature was found, return an unnamed feature
-	return &pb.Feature{Location: point}, nil
+	return padded(ctx, &pb.Feature{Location: point}), nil
 }
 
 // ListFeatures lists all features contained within the given bounding Rectangle.
 func (s *routeGuideServer) ListFeatures(rect *pb.Rectangle, stream pb.RouteGuide_ListFeaturesServer) error {
 	for _, feature := range s.savedFeatures {
 		if inRange(feature.Location, rect) {
-			if err := stream.Send(feature); err != nil {
+			if err := stream.Send(padded(stream.Context(), feature)); err != nil {
 				return err
 			}
 		}
@@ -158,6 +164,15 @@ func (s *routeGuideServer) loadFeatures(filePath string) {
 	}
 }
 
+// padded appends the filler requested by x-fault-payload-bytes to the
+// feature's name, leaving the saved feature untouched.
+func padded(ctx context.Context, feature *pb.Feature) *pb.Feature {
+	if fault.PayloadSize(ctx) == 0 {
+		return feature
+	}
+	return &pb.Feature{Name: feature.Name + fault.Padding(ctx), Location: feature.Location}
+}
+
 func toRadians(num float64) float64 {
 	return num * math.Pi / float64(180)
 }
@@ -214,9 +229,23 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	var opts []grpc.ServerOption
+	state := admin.New(pb.RouteGuide_ServiceDesc.ServiceName)
+	opts := append(state.ServerOptions(),
+		grpc.ChainUnaryInterceptor(fault.UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(fault.StreamServerInterceptor),
+	)
 
 	grpcServer := grpc.NewServer(opts...)
 	pb.RegisterRouteGuideServer(grpcServer, newServer())
-	grpcServer.Serve(lis)
+	if err := state.Register(grpcServer); err != nil {
+		log.Fatalf("failed to register admin services: %v", err)
+	}
+	if *adminPort != 0 {
+		go func() {
+			log.Fatal(http.ListenAndServe(fmt.Sprintf("localhost:%d", *adminPort), state.Handler()))
+		}()
+	}
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
 }
@@ -0,0 +1,222 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/samples/grpc/fault"
+	pb "github.com/dubbo-go-pixiu/samples/grpc/simple/routeguide"
+)
+
+// knownPoint is the location of a feature in route_guide_db.json.
+var knownPoint = &pb.Point{Latitude: 409146138, Longitude: -746188906}
+
+// everywhere covers all features of route_guide_db.json.
+var everywhere = &pb.Rectangle{
+	Lo: &pb.Point{Latitude: -900000000, Longitude: -1800000000},
+	Hi: &pb.Point{Latitude: 900000000, Longitude: 1800000000},
+}
+
+func newFaultClient(t *testing.T) pb.RouteGuideClient {
+	t.Helper()
+	conn, err := grpc.NewClient(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "fail to dial")
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewRouteGuideClient(conn)
+}
+
+// faultID makes the outcome names unique across runs against the same server.
+func faultID(name string) string {
+	return fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+}
+
+// TestFaultStatusDetails verifies that Pixiu relays the code, message and
+// google.rpc.Status details of a failed call unchanged.
+func TestFaultStatusDetails(t *testing.T) {
+	client := newFaultClient(t)
+	for _, code := range []codes.Code{codes.NotFound, codes.InvalidArgument, codes.Unavailable, codes.PermissionDenied, codes.Internal} {
+		t.Run(code.String(), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ctx = metadata.AppendToOutgoingContext(ctx, fault.StatusKey, fmt.Sprint(uint32(code)), fault.MessageKey, "injected by test")
+
+			_, err := client.GetFeature(ctx, knownPoint)
+			st := status.Convert(err)
+			assert.Equal(t, code, st.Code())
+			assert.Equal(t, "injected by test", st.Message())
+			require.NotEmpty(t, st.Details(), "status details were dropped")
+			info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+			require.True(t, ok, "expected an ErrorInfo, got %T", st.Details()[0])
+			assert.Equal(t, fault.ErrorDomain, info.Domain)
+			assert.Equal(t, "/routeguide.RouteGuide/GetFeature", info.Metadata["method"])
+		})
+	}
+}
+
+// TestFaultHeadersAndTrailers verifies that custom response headers and
+// trailers reach the client on success and on error.
+func TestFaultHeadersAndTrailers(t *testing.T) {
+	client := newFaultClient(t)
+	for _, code := range []string{"", "FAILED_PRECONDITION"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		kv := []string{fault.HeaderKey, "x-route=guide", fault.TrailerKey, "x-checksum=42"}
+		if code != "" {
+			kv = append(kv, fault.StatusKey, code)
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, kv...)
+
+		var header, trailer metadata.MD
+		_, err := client.GetFeature(ctx, knownPoint, grpc.Header(&header), grpc.Trailer(&trailer))
+		cancel()
+		if code == "" {
+			require.NoError(t, err)
+		} else {
+			require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		}
+		assert.Equal(t, []string{"guide"}, append(header.Get("x-route"), trailer.Get("x-route")...), "status %q", code)
+		assert.Equal(t, []string{"42"}, trailer.Get("x-checksum"), "status %q", code)
+	}
+}
+
+// TestFaultDeadline verifies that the client deadline reaches the backend:
+// the stalled server call must end with the deadline, not by the gateway
+// resetting the stream.
+func TestFaultDeadline(t *testing.T) {
+	client := newFaultClient(t)
+	id := faultID("deadline")
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, fault.StallKey, "true", fault.IDKey, id)
+
+	start := time.Now()
+	_, err := client.GetFeature(ctx, knownPoint)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Less(t, time.Since(start), 3*time.Second, "the gateway kept the call open past the deadline")
+	assert.Equal(t, "deadline_exceeded", waitOutcome(t, client, id))
+}
+
+// TestFaultCancellation verifies that canceling a stream at the client
+// cancels the backend call.
+func TestFaultCancellation(t *testing.T) {
+	client := newFaultClient(t)
+	id := faultID("cancel")
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = metadata.AppendToOutgoingContext(ctx, fault.IDKey, id)
+
+	stream, err := client.RouteChat(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.RouteNote{Location: &pb.Point{Latitude: 1, Longitude: int32(time.Now().UnixNano() % 1e6)}, Message: "ping"}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	cancel()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Equal(t, "canceled", waitOutcome(t, client, id))
+}
+
+// TestFaultLargeMessages verifies that large unary and streamed responses pass
+// the gateway intact.
+func TestFaultLargeMessages(t *testing.T) {
+	client := newFaultClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	const unarySize = 3 << 20
+	feature, err := client.GetFeature(metadata.AppendToOutgoingContext(ctx, fault.PayloadKey, fmt.Sprint(unarySize)), knownPoint)
+	require.NoError(t, err)
+	assert.Len(t, feature.Name, len("Berkshire Valley Management Area Trail, Jefferson, NJ, USA")+unarySize)
+
+	const streamSize = 1 << 20
+	stream, err := client.ListFeatures(metadata.AppendToOutgoingContext(ctx, fault.PayloadKey, fmt.Sprint(streamSize)), everywhere)
+	require.NoError(t, err)
+	count := 0
+	for {
+		feature, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, len(feature.Name), streamSize)
+		count++
+	}
+	assert.Greater(t, count, 10, "expected the whole database streamed")
+}
+
+// TestFaultAbortMidStream verifies that a stream failing after some messages
+// delivers those messages and then the status and trailers.
+func TestFaultAbortMidStream(t *testing.T) {
+	client := newFaultClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, fault.AbortAfterKey, "3", fault.StatusKey, "UNAVAILABLE", fault.TrailerKey, "x-sent=3")
+
+	stream, err := client.ListFeatures(ctx, everywhere)
+	require.NoError(t, err)
+	received := 0
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+		received++
+	}
+	assert.Equal(t, 3, received)
+	st := status.Convert(err)
+	assert.Equal(t, codes.Unavailable, st.Code())
+	assert.Len(t, st.Details(), 2, "expected ErrorInfo and RetryInfo")
+	assert.Equal(t, []string{"3"}, stream.Trailer().Get("x-sent"))
+}
+
+// waitOutcome polls the outcome the server recorded for id.
+func waitOutcome(t *testing.T, client pb.RouteGuideClient, id string) string {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		var trailer metadata.MD
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err := client.GetFeature(metadata.AppendToOutgoingContext(ctx, fault.OutcomeOfKey, id), knownPoint, grpc.Trailer(&trailer))
+		cancel()
+		require.NoError(t, err)
+		if outcome := trailer.Get(fault.OutcomeKey); len(outcome) > 0 && outcome[0] != "" {
+			return outcome[0]
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("the backend recorded no outcome for %s", id)
+	return ""
+}
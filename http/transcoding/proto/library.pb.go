@@ -0,0 +1,1093 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: library.proto
+
+package proto
+
+import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	wrapperspb "google.golang.org/protobuf/types/known/wrapperspb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Genre of a book.
+type Genre int32
+
+const (
+	Genre_GENRE_UNSPECIFIED Genre = 0
+	Genre_FICTION           Genre = 1
+	Genre_SCIENCE           Genre = 2
+	Genre_HISTORY           Genre = 3
+)
+
+// Enum value maps for Genre.
+var (
+	Genre_name = map[int32]string{
+		0: "GENRE_UNSPECIFIED",
+		1: "FICTION",
+		2: "SCIENCE",
+		3: "HISTORY",
+	}
+	Genre_value = map[string]int32{
+		"GENRE_UNSPECIFIED": 0,
+		"FICTION":           1,
+		"SCIENCE":           2,
+		"HISTORY":           3,
+	}
+)
+
+func (x Genre) Enum() *Genre {
+	p := new(Genre)
+	*p = x
+	return p
+}
+
+func (x Genre) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Genre) Descriptor() protoreflect.EnumDescriptor {
+	return file_library_proto_enumTypes[0].Descriptor()
+}
+
+func (Genre) Type() protoreflect.EnumType {
+	return &file_library_proto_enumTypes[0]
+}
+
+func (x Genre) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Genre.Descriptor instead.
+func (Genre) EnumDescriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{0}
+}
+
+// Author of a book.
+type Author struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Author identifier, rendered as a JSON string.
+	Id int64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Name shown to readers.
+	DisplayName   string `protobuf:"bytes,2,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Author) Reset() {
+	*x = Author{}
+	mi := &file_library_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Author) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Author) ProtoMessage() {}
+
+func (x *Author) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Author.ProtoReflect.Descriptor instead.
+func (*Author) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Author) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Author) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+// Book is the resource of the API.
+type Book struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Resource name: shelves/{shelf}/books/{book}.
+	Name    string    `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Title   string    `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Authors []*Author `protobuf:"bytes,3,rep,name=authors,proto3" json:"authors,omitempty"`
+	Genre   Genre     `protobuf:"varint,4,opt,name=genre,proto3,enum=library.v1.Genre" json:"genre,omitempty"`
+	// Rendered as a JSON string, like every 64-bit integer.
+	PageCount   int64                  `protobuf:"varint,5,opt,name=page_count,json=pageCount,proto3" json:"page_count,omitempty"`
+	Isbn        uint64                 `protobuf:"varint,6,opt,name=isbn,proto3" json:"isbn,omitempty"`
+	Tags        []string               `protobuf:"bytes,7,rep,name=tags,proto3" json:"tags,omitempty"`
+	PublishTime *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=publish_time,json=publishTime,proto3" json:"publish_time,omitempty"`
+	// Typical time to read the book.
+	ReadingTime *durationpb.Duration `protobuf:"bytes,9,opt,name=reading_time,json=readingTime,proto3" json:"reading_time,omitempty"`
+	// Average rating, absent while the book is unrated.
+	Rating *wrapperspb.Int32Value `protobuf:"bytes,10,opt,name=rating,proto3" json:"rating,omitempty"`
+	// Free-form attributes.
+	Attributes *structpb.Struct `protobuf:"bytes,11,opt,name=attributes,proto3" json:"attributes,omitempty"`
+	// Where the book is: lent out or on a shelf.
+	//
+	// Types that are valid to be assigned to Availability:
+	//
+	//	*Book_Loan_
+	//	*Book_ShelfLocation
+	Availability  isBook_Availability `protobuf_oneof:"availability"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Book) Reset() {
+	*x = Book{}
+	mi := &file_library_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Book) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Book) ProtoMessage() {}
+
+func (x *Book) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Book.ProtoReflect.Descriptor instead.
+func (*Book) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Book) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Book) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Book) GetAuthors() []*Author {
+	if x != nil {
+		return x.Authors
+	}
+	return nil
+}
+
+func (x *Book) GetGenre() Genre {
+	if x != nil {
+		return x.Genre
+	}
+	return Genre_GENRE_UNSPECIFIED
+}
+
+func (x *Book) GetPageCount() int64 {
+	if x != nil {
+		return x.PageCount
+	}
+	return 0
+}
+
+func (x *Book) GetIsbn() uint64 {
+	if x != nil {
+		return x.Isbn
+	}
+	return 0
+}
+
+func (x *Book) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Book) GetPublishTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PublishTime
+	}
+	return nil
+}
+
+func (x *Book) GetReadingTime() *durationpb.Duration {
+	if x != nil {
+		return x.ReadingTime
+	}
+	return nil
+}
+
+func (x *Book) GetRating() *wrapperspb.Int32Value {
+	if x != nil {
+		return x.Rating
+	}
+	return nil
+}
+
+func (x *Book) GetAttributes() *structpb.Struct {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *Book) GetAvailability() isBook_Availability {
+	if x != nil {
+		return x.Availability
+	}
+	return nil
+}
+
+func (x *Book) GetLoan() *Book_Loan {
+	if x != nil {
+		if x, ok := x.Availability.(*Book_Loan_); ok {
+			return x.Loan
+		}
+	}
+	return nil
+}
+
+func (x *Book) GetShelfLocation() string {
+	if x != nil {
+		if x, ok := x.Availability.(*Book_ShelfLocation); ok {
+			return x.ShelfLocation
+		}
+	}
+	return ""
+}
+
+type isBook_Availability interface {
+	isBook_Availability()
+}
+
+type Book_Loan_ struct {
+	Loan *Book_Loan `protobuf:"bytes,12,opt,name=loan,proto3,oneof"`
+}
+
+type Book_ShelfLocation struct {
+	ShelfLocation string `protobuf:"bytes,13,opt,name=shelf_location,json=shelfLocation,proto3,oneof"`
+}
+
+func (*Book_Loan_) isBook_Availability() {}
+
+func (*Book_ShelfLocation) isBook_Availability() {}
+
+type GetBookRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBookRequest) Reset() {
+	*x = GetBookRequest{}
+	mi := &file_library_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBookRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBookRequest) ProtoMessage() {}
+
+func (x *GetBookRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBookRequest.ProtoReflect.Descriptor instead.
+func (*GetBookRequest) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetBookRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ListBooksRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Parent   string                 `protobuf:"bytes,1,opt,name=parent,proto3" json:"parent,omitempty"`
+	PageSize int32                  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// Only books of this genre, if set.
+	Genre Genre `protobuf:"varint,3,opt,name=genre,proto3,enum=library.v1.Genre" json:"genre,omitempty"`
+	// Only books carrying all of these tags.
+	Tags []string `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	// Only books published after this time, if set.
+	PublishedAfter *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=published_after,json=publishedAfter,proto3" json:"published_after,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ListBooksRequest) Reset() {
+	*x = ListBooksRequest{}
+	mi := &file_library_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBooksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBooksRequest) ProtoMessage() {}
+
+func (x *ListBooksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBooksRequest.ProtoReflect.Descriptor instead.
+func (*ListBooksRequest) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListBooksRequest) GetParent() string {
+	if x != nil {
+		return x.Parent
+	}
+	return ""
+}
+
+func (x *ListBooksRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListBooksRequest) GetGenre() Genre {
+	if x != nil {
+		return x.Genre
+	}
+	return Genre_GENRE_UNSPECIFIED
+}
+
+func (x *ListBooksRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ListBooksRequest) GetPublishedAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PublishedAfter
+	}
+	return nil
+}
+
+type ListBooksResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Books []*Book                `protobuf:"bytes,1,rep,name=books,proto3" json:"books,omitempty"`
+	// Number of matching books, before page_size applies.
+	TotalSize     int64 `protobuf:"varint,2,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBooksResponse) Reset() {
+	*x = ListBooksResponse{}
+	mi := &file_library_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBooksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBooksResponse) ProtoMessage() {}
+
+func (x *ListBooksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBooksResponse.ProtoReflect.Descriptor instead.
+func (*ListBooksResponse) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListBooksResponse) GetBooks() []*Book {
+	if x != nil {
+		return x.Books
+	}
+	return nil
+}
+
+func (x *ListBooksResponse) GetTotalSize() int64 {
+	if x != nil {
+		return x.TotalSize
+	}
+	return 0
+}
+
+type CreateBookRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Parent string                 `protobuf:"bytes,1,opt,name=parent,proto3" json:"parent,omitempty"`
+	// Last segment of the new book's name.
+	BookId        string `protobuf:"bytes,2,opt,name=book_id,json=bookId,proto3" json:"book_id,omitempty"`
+	Book          *Book  `protobuf:"bytes,3,opt,name=book,proto3" json:"book,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateBookRequest) Reset() {
+	*x = CreateBookRequest{}
+	mi := &file_library_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateBookRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateBookRequest) ProtoMessage() {}
+
+func (x *CreateBookRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateBookRequest.ProtoReflect.Descriptor instead.
+func (*CreateBookRequest) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateBookRequest) GetParent() string {
+	if x != nil {
+		return x.Parent
+	}
+	return ""
+}
+
+func (x *CreateBookRequest) GetBookId() string {
+	if x != nil {
+		return x.BookId
+	}
+	return ""
+}
+
+func (x *CreateBookRequest) GetBook() *Book {
+	if x != nil {
+		return x.Book
+	}
+	return nil
+}
+
+type UpdateBookRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Book  *Book                  `protobuf:"bytes,1,opt,name=book,proto3" json:"book,omitempty"`
+	// Fields of book to update, in proto field names.
+	UpdateMask    *fieldmaskpb.FieldMask `protobuf:"bytes,2,opt,name=update_mask,json=updateMask,proto3" json:"update_mask,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateBookRequest) Reset() {
+	*x = UpdateBookRequest{}
+	mi := &file_library_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateBookRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateBookRequest) ProtoMessage() {}
+
+func (x *UpdateBookRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateBookRequest.ProtoReflect.Descriptor instead.
+func (*UpdateBookRequest) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UpdateBookRequest) GetBook() *Book {
+	if x != nil {
+		return x.Book
+	}
+	return nil
+}
+
+func (x *UpdateBookRequest) GetUpdateMask() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.UpdateMask
+	}
+	return nil
+}
+
+type DeleteBookRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteBookRequest) Reset() {
+	*x = DeleteBookRequest{}
+	mi := &file_library_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteBookRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteBookRequest) ProtoMessage() {}
+
+func (x *DeleteBookRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteBookRequest.ProtoReflect.Descriptor instead.
+func (*DeleteBookRequest) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeleteBookRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type SearchBooksRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Query:
+	//
+	//	*SearchBooksRequest_Title
+	//	*SearchBooksRequest_AuthorId
+	Query isSearchBooksRequest_Query `protobuf_oneof:"query"`
+	// At most this many books, all of them if zero.
+	Limit         int32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchBooksRequest) Reset() {
+	*x = SearchBooksRequest{}
+	mi := &file_library_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchBooksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchBooksRequest) ProtoMessage() {}
+
+func (x *SearchBooksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchBooksRequest.ProtoReflect.Descriptor instead.
+func (*SearchBooksRequest) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SearchBooksRequest) GetQuery() isSearchBooksRequest_Query {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+func (x *SearchBooksRequest) GetTitle() string {
+	if x != nil {
+		if x, ok := x.Query.(*SearchBooksRequest_Title); ok {
+			return x.Title
+		}
+	}
+	return ""
+}
+
+func (x *SearchBooksRequest) GetAuthorId() int64 {
+	if x != nil {
+		if x, ok := x.Query.(*SearchBooksRequest_AuthorId); ok {
+			return x.AuthorId
+		}
+	}
+	return 0
+}
+
+func (x *SearchBooksRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type isSearchBooksRequest_Query interface {
+	isSearchBooksRequest_Query()
+}
+
+type SearchBooksRequest_Title struct {
+	// Case-insensitive substring of the title.
+	Title string `protobuf:"bytes,1,opt,name=title,proto3,oneof"`
+}
+
+type SearchBooksRequest_AuthorId struct {
+	AuthorId int64 `protobuf:"varint,2,opt,name=author_id,json=authorId,proto3,oneof"`
+}
+
+func (*SearchBooksRequest_Title) isSearchBooksRequest_Query() {}
+
+func (*SearchBooksRequest_AuthorId) isSearchBooksRequest_Query() {}
+
+type SearchBooksResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Books         []*Book                `protobuf:"bytes,1,rep,name=books,proto3" json:"books,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchBooksResponse) Reset() {
+	*x = SearchBooksResponse{}
+	mi := &file_library_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchBooksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchBooksResponse) ProtoMessage() {}
+
+func (x *SearchBooksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchBooksResponse.ProtoReflect.Descriptor instead.
+func (*SearchBooksResponse) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SearchBooksResponse) GetBooks() []*Book {
+	if x != nil {
+		return x.Books
+	}
+	return nil
+}
+
+type StreamBooksRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Parent string                 `protobuf:"bytes,1,opt,name=parent,proto3" json:"parent,omitempty"`
+	// Only books of this genre, if set.
+	Genre         Genre `protobuf:"varint,2,opt,name=genre,proto3,enum=library.v1.Genre" json:"genre,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamBooksRequest) Reset() {
+	*x = StreamBooksRequest{}
+	mi := &file_library_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamBooksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamBooksRequest) ProtoMessage() {}
+
+func (x *StreamBooksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamBooksRequest.ProtoReflect.Descriptor instead.
+func (*StreamBooksRequest) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *StreamBooksRequest) GetParent() string {
+	if x != nil {
+		return x.Parent
+	}
+	return ""
+}
+
+func (x *StreamBooksRequest) GetGenre() Genre {
+	if x != nil {
+		return x.Genre
+	}
+	return Genre_GENRE_UNSPECIFIED
+}
+
+// Loan records who has the book.
+type Book_Loan struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Borrower      string                 `protobuf:"bytes,1,opt,name=borrower,proto3" json:"borrower,omitempty"`
+	DueTime       *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=due_time,json=dueTime,proto3" json:"due_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Book_Loan) Reset() {
+	*x = Book_Loan{}
+	mi := &file_library_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Book_Loan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Book_Loan) ProtoMessage() {}
+
+func (x *Book_Loan) ProtoReflect() protoreflect.Message {
+	mi := &file_library_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Book_Loan.ProtoReflect.Descriptor instead.
+func (*Book_Loan) Descriptor() ([]byte, []int) {
+	return file_library_proto_rawDescGZIP(), []int{1, 0}
+}
+
+func (x *Book_Loan) GetBorrower() string {
+	if x != nil {
+		return x.Borrower
+	}
+	return ""
+}
+
+func (x *Book_Loan) GetDueTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DueTime
+	}
+	return nil
+}
+
+var File_library_proto protoreflect.FileDescriptor
+
+const file_library_proto_rawDesc = "" +
+	"\n" +
+	"\rlibrary.proto\x12\n" +
+	"library.v1\x1a\x1cgoogle/api/annotations.proto\x1a\x1egoogle/protobuf/duration.proto\x1a\x1bgoogle/protobuf/empty.proto\x1a google/protobuf/field_mask.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1egoogle/protobuf/wrappers.proto\";\n" +
+	"\x06Author\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12!\n" +
+	"\fdisplay_name\x18\x02 \x01(\tR\vdisplayName\"\xfa\x04\n" +
+	"\x04Book\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12,\n" +
+	"\aauthors\x18\x03 \x03(\v2\x12.library.v1.AuthorR\aauthors\x12'\n" +
+	"\x05genre\x18\x04 \x01(\x0e2\x11.library.v1.GenreR\x05genre\x12\x1d\n" +
+	"\n" +
+	"page_count\x18\x05 \x01(\x03R\tpageCount\x12\x12\n" +
+	"\x04isbn\x18\x06 \x01(\x04R\x04isbn\x12\x12\n" +
+	"\x04tags\x18\a \x03(\tR\x04tags\x12=\n" +
+	"\fpublish_time\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\vpublishTime\x12<\n" +
+	"\freading_time\x18\t \x01(\v2\x19.google.protobuf.DurationR\vreadingTime\x123\n" +
+	"\x06rating\x18\n" +
+	" \x01(\v2\x1b.google.protobuf.Int32ValueR\x06rating\x127\n" +
+	"\n" +
+	"attributes\x18\v \x01(\v2\x17.google.protobuf.StructR\n" +
+	"attributes\x12+\n" +
+	"\x04loan\x18\f \x01(\v2\x15.library.v1.Book.LoanH\x00R\x04loan\x12'\n" +
+	"\x0eshelf_location\x18\r \x01(\tH\x00R\rshelfLocation\x1aY\n" +
+	"\x04Loan\x12\x1a\n" +
+	"\bborrower\x18\x01 \x01(\tR\bborrower\x125\n" +
+	"\bdue_time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\adueTimeB\x0e\n" +
+	"\favailability\"$\n" +
+	"\x0eGetBookRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\xc9\x01\n" +
+	"\x10ListBooksRequest\x12\x16\n" +
+	"\x06parent\x18\x01 \x01(\tR\x06parent\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12'\n" +
+	"\x05genre\x18\x03 \x01(\x0e2\x11.library.v1.GenreR\x05genre\x12\x12\n" +
+	"\x04tags\x18\x04 \x03(\tR\x04tags\x12C\n" +
+	"\x0fpublished_after\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x0epublishedAfter\"Z\n" +
+	"\x11ListBooksResponse\x12&\n" +
+	"\x05books\x18\x01 \x03(\v2\x10.library.v1.BookR\x05books\x12\x1d\n" +
+	"\n" +
+	"total_size\x18\x02 \x01(\x03R\ttotalSize\"j\n" +
+	"\x11CreateBookRequest\x12\x16\n" +
+	"\x06parent\x18\x01 \x01(\tR\x06parent\x12\x17\n" +
+	"\abook_id\x18\x02 \x01(\tR\x06bookId\x12$\n" +
+	"\x04book\x18\x03 \x01(\v2\x10.library.v1.BookR\x04book\"v\n" +
+	"\x11UpdateBookRequest\x12$\n" +
+	"\x04book\x18\x01 \x01(\v2\x10.library.v1.BookR\x04book\x12;\n" +
+	"\vupdate_mask\x18\x02 \x01(\v2\x1a.google.protobuf.FieldMaskR\n" +
+	"updateMask\"'\n" +
+	"\x11DeleteBookRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"j\n" +
+	"\x12SearchBooksRequest\x12\x16\n" +
+	"\x05title\x18\x01 \x01(\tH\x00R\x05title\x12\x1d\n" +
+	"\tauthor_id\x18\x02 \x01(\x03H\x00R\bauthorId\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limitB\a\n" +
+	"\x05query\"=\n" +
+	"\x13SearchBooksResponse\x12&\n" +
+	"\x05books\x18\x01 \x03(\v2\x10.library.v1.BookR\x05books\"U\n" +
+	"\x12StreamBooksRequest\x12\x16\n" +
+	"\x06parent\x18\x01 \x01(\tR\x06parent\x12'\n" +
+	"\x05genre\x18\x02 \x01(\x0e2\x11.library.v1.GenreR\x05genre*E\n" +
+	"\x05Genre\x12\x15\n" +
+	"\x11GENRE_UNSPECIFIED\x10\x00\x12\v\n" +
+	"\aFICTION\x10\x01\x12\v\n" +
+	"\aSCIENCE\x10\x02\x12\v\n" +
+	"\aHISTORY\x10\x032\x96\x06\n" +
+	"\x0eLibraryService\x12]\n" +
+	"\aGetBook\x12\x1a.library.v1.GetBookRequest\x1a\x10.library.v1.Book\"$\x82\xd3\xe4\x93\x02\x1e\x12\x1c/v1/{name=shelves/*/books/*}\x12n\n" +
+	"\tListBooks\x12\x1c.library.v1.ListBooksRequest\x1a\x1d.library.v1.ListBooksResponse\"$\x82\xd3\xe4\x93\x02\x1e\x12\x1c/v1/{parent=shelves/*}/books\x12i\n" +
+	"\n" +
+	"CreateBook\x12\x1d.library.v1.CreateBookRequest\x1a\x10.library.v1.Book\"*\x82\xd3\xe4\x93\x02$:\x04book\"\x1c/v1/{parent=shelves/*}/books\x12n\n" +
+	"\n" +
+	"UpdateBook\x12\x1d.library.v1.UpdateBookRequest\x1a\x10.library.v1.Book\"/\x82\xd3\xe4\x93\x02):\x04book2!/v1/{book.name=shelves/*/books/*}\x12i\n" +
+	"\n" +
+	"DeleteBook\x12\x1d.library.v1.DeleteBookRequest\x1a\x16.google.protobuf.Empty\"$\x82\xd3\xe4\x93\x02\x1e*\x1c/v1/{name=shelves/*/books/*}\x12\x7f\n" +
+	"\vSearchBooks\x12\x1e.library.v1.SearchBooksRequest\x1a\x1f.library.v1.SearchBooksResponse\"/\x82\xd3\xe4\x93\x02):\x01*Z\x12\x12\x10/v1/books:search\"\x10/v1/books:search\x12n\n" +
+	"\vStreamBooks\x12\x1e.library.v1.StreamBooksRequest\x1a\x10.library.v1.Book\"+\x82\xd3\xe4\x93\x02%\x12#/v1/{parent=shelves/*}/books:stream0\x01B:Z8github.com/dubbo-go-pixiu/samples/http/transcoding/protob\x06proto3"
+
+var (
+	file_library_proto_rawDescOnce sync.Once
+	file_library_proto_rawDescData []byte
+)
+
+func file_library_proto_rawDescGZIP() []byte {
+	file_library_proto_rawDescOnce.Do(func() {
+		file_library_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_library_proto_rawDesc), len(file_library_proto_rawDesc)))
+	})
+	return file_library_proto_rawDescData
+}
+
+var file_library_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_library_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_library_proto_goTypes = []any{
+	(Genre)(0),                    // 0: library.v1.Genre
+	(*Author)(nil),                // 1: library.v1.Author
+	(*Book)(nil),                  // 2: library.v1.Book
+	(*GetBookRequest)(nil),        // 3: library.v1.GetBookRequest
+	(*ListBooksRequest)(nil),      // 4: library.v1.ListBooksRequest
+	(*ListBooksResponse)(nil),     // 5: library.v1.ListBooksResponse
+	(*CreateBookRequest)(nil),     // 6: library.v1.CreateBookRequest
+	(*UpdateBookRequest)(nil),     // 7: library.v1.UpdateBookRequest
+	(*DeleteBookRequest)(nil),     // 8: library.v1.DeleteBookRequest
+	(*SearchBooksRequest)(nil),    // 9: library.v1.SearchBooksRequest
+	(*SearchBooksResponse)(nil),   // 10: library.v1.SearchBooksResponse
+	(*StreamBooksRequest)(nil),    // 11: library.v1.StreamBooksRequest
+	(*Book_Loan)(nil),             // 12: library.v1.Book.Loan
+	(*timestamppb.Timestamp)(nil), // 13: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),   // 14: google.protobuf.Duration
+	(*wrapperspb.Int32Value)(nil), // 15: google.protobuf.Int32Value
+	(*structpb.Struct)(nil),       // 16: google.protobuf.Struct
+	(*fieldmaskpb.FieldMask)(nil), // 17: google.protobuf.FieldMask
+	(*emptypb.Empty)(nil),         // 18: google.protobuf.Empty
+}
+var file_library_proto_depIdxs = []int32{
+	1,  // 0: library.v1.Book.authors:type_name -> library.v1.Author
+	0,  // 1: library.v1.Book.genre:type_name -> library.v1.Genre
+	13, // 2: library.v1.Book.publish_time:type_name -> google.protobuf.Timestamp
+	14, // 3: library.v1.Book.reading_time:type_name -> google.protobuf.Duration
+	15, // 4: library.v1.Book.rating:type_name -> google.protobuf.Int32Value
+	16, // 5: library.v1.Book.attributes:type_name -> google.protobuf.Struct
+	12, // 6: library.v1.Book.loan:type_name -> library.v1.Book.Loan
+	0,  // 7: library.v1.ListBooksRequest.genre:type_name -> library.v1.Genre
+	13, // 8: library.v1.ListBooksRequest.published_after:type_name -> google.protobuf.Timestamp
+	2,  // 9: library.v1.ListBooksResponse.books:type_name -> library.v1.Book
+	2,  // 10: library.v1.CreateBookRequest.book:type_name -> library.v1.Book
+	2,  // 11: library.v1.UpdateBookRequest.book:type_name -> library.v1.Book
+	17, // 12: library.v1.UpdateBookRequest.update_mask:type_name -> google.protobuf.FieldMask
+	2,  // 13: library.v1.SearchBooksResponse.books:type_name -> library.v1.Book
+	0,  // 14: library.v1.StreamBooksRequest.genre:type_name -> library.v1.Genre
+	13, // 15: library.v1.Book.Loan.due_time:type_name -> google.protobuf.Timestamp
+	3,  // 16: library.v1.LibraryService.GetBook:input_type -> library.v1.GetBookRequest
+	4,  // 17: library.v1.LibraryService.ListBooks:input_type -> library.v1.ListBooksRequest
+	6,  // 18: library.v1.LibraryService.CreateBook:input_type -> library.v1.CreateBookRequest
+	7,  // 19: library.v1.LibraryService.UpdateBook:input_type -> library.v1.UpdateBookRequest
+	8,  // 20: library.v1.LibraryService.DeleteBook:input_type -> library.v1.DeleteBookRequest
+	9,  // 21: library.v1.LibraryService.SearchBooks:input_type -> library.v1.SearchBooksRequest
+	11, // 22: library.v1.LibraryService.StreamBooks:input_type -> library.v1.StreamBooksRequest
+	2,  // 23: library.v1.LibraryService.GetBook:output_type -> library.v1.Book
+	5,  // 24: library.v1.LibraryService.ListBooks:output_type -> library.v1.ListBooksResponse
+	2,  // 25: library.v1.LibraryService.CreateBook:output_type -> library.v1.Book
+	2,  // 26: library.v1.LibraryService.UpdateBook:output_type -> library.v1.Book
+	18, // 27: library.v1.LibraryService.DeleteBook:output_type -> google.protobuf.Empty
+	10, // 28: library.v1.LibraryService.SearchBooks:output_type -> library.v1.SearchBooksResponse
+	2,  // 29: library.v1.LibraryService.StreamBooks:output_type -> library.v1.Book
+	23, // [23:30] is the sub-list for method output_type
+	16, // [16:23] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
+}
+
+func init() { file_library_proto_init() }
+func file_library_proto_init() {
+	if File_library_proto != nil {
+		return
+	}
+	file_library_proto_msgTypes[1].OneofWrappers = []any{
+		(*Book_Loan_)(nil),
+		(*Book_ShelfLocation)(nil),
+	}
+	file_library_proto_msgTypes[8].OneofWrappers = []any{
+		(*SearchBooksRequest_Title)(nil),
+		(*SearchBooksRequest_AuthorId)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_library_proto_rawDesc), len(file_library_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_library_proto_goTypes,
+		DependencyIndexes: file_library_proto_depIdxs,
+		EnumInfos:         file_library_proto_enumTypes,
+		MessageInfos:      file_library_proto_msgTypes,
+	}.Build()
+	File_library_proto = out.File
+	file_library_proto_goTypes = nil
+	file_library_proto_depIdxs = nil
+}
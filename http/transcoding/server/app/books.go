@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"time"
+)
+
+import (
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+import (
+	pb "github.com/dubbo-go-pixiu/samples/http/transcoding/proto"
+)
+
+// shelves lists the parents books can be created in.
+var shelves = []string{"shelves/1", "shelves/2"}
+
+// seedBooks returns the books the library starts with. Their values are fixed
+// so tests can compare whole JSON documents.
+func seedBooks() []*pb.Book {
+	return []*pb.Book{
+		{
+			Name:  "shelves/1/books/1",
+			Title: "The Left Hand of Darkness",
+			// An ID past 2^53 that JSON numbers could not carry exactly.
+			Authors:     []*pb.Author{{Id: 9007199254740993, DisplayName: "Ursula K. Le Guin"}},
+			Genre:       pb.Genre_FICTION,
+			PageCount:   304,
+			Isbn:        9780441478125,
+			Tags:        []string{"classic", "award-winner"},
+			PublishTime: timestamppb.New(time.Date(1969, time.March, 1, 0, 0, 0, 0, time.UTC)),
+			ReadingTime: durationpb.New(6 * time.Hour),
+			Rating:      wrapperspb.Int32(5),
+			Attributes: mustStruct(map[string]interface{}{
+				"series":    "Hainish Cycle",
+				"hardcover": true,
+				"awards":    []interface{}{"Hugo", "Nebula"},
+			}),
+			Availability: &pb.Book_ShelfLocation{ShelfLocation: "A-12"},
+		},
+		{
+			Name:        "shelves/1/books/2",
+			Title:       "A Brief History of Time",
+			Authors:     []*pb.Author{{Id: 2, DisplayName: "Stephen Hawking"}},
+			Genre:       pb.Genre_SCIENCE,
+			PageCount:   256,
+			Isbn:        9780553380163,
+			Tags:        []string{"physics", "classic"},
+			PublishTime: timestamppb.New(time.Date(1988, time.April, 1, 0, 0, 0, 0, time.UTC)),
+			ReadingTime: durationpb.New(90 * time.Minute),
+			Availability: &pb.Book_Loan_{Loan: &pb.Book_Loan{
+				Borrower: "ada",
+				DueTime:  timestamppb.New(time.Date(2030, time.January, 15, 12, 0, 0, 0, time.UTC)),
+			}},
+		},
+		{
+			Name:         "shelves/2/books/3",
+			Title:        "SPQR",
+			Authors:      []*pb.Author{{Id: 3, DisplayName: "Mary Beard"}},
+			Genre:        pb.Genre_HISTORY,
+			PageCount:    608,
+			Isbn:         9781631492228,
+			Tags:         []string{"rome"},
+			PublishTime:  timestamppb.New(time.Date(2015, time.November, 9, 0, 0, 0, 0, time.UTC)),
+			ReadingTime:  durationpb.New(10 * time.Hour),
+			Rating:       wrapperspb.Int32(4),
+			Availability: &pb.Book_ShelfLocation{ShelfLocation: "C-3"},
+		},
+		{
+			Name:         "shelves/2/books/4",
+			Title:        "Cosmos",
+			Authors:      []*pb.Author{{Id: 4, DisplayName: "Carl Sagan"}},
+			Genre:        pb.Genre_SCIENCE,
+			PageCount:    396,
+			Isbn:         9780345539434,
+			Tags:         []string{"physics", "astronomy"},
+			PublishTime:  timestamppb.New(time.Date(1980, time.October, 1, 0, 0, 0, 0, time.UTC)),
+			ReadingTime:  durationpb.New(8 * time.Hour),
+			Rating:       wrapperspb.Int32(5),
+			Availability: &pb.Book_ShelfLocation{ShelfLocation: "B-7"},
+		},
+	}
+}
+
+func mustStruct(m map[string]interface{}) *structpb.Struct {
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
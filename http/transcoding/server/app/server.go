@@ -0,0 +1,294 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package main implements the LibraryService, whose RPCs carry google.api.http
+// annotations. Reflection is enabled so a gateway can load the descriptors,
+// and the annotations in them, without a local copy of the proto files.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+import (
+	pb "github.com/dubbo-go-pixiu/samples/http/transcoding/proto"
+)
+
+var port = flag.Int("port", 50002, "The server port")
+
+type libraryServer struct {
+	pb.UnimplementedLibraryServiceServer
+
+	mu    sync.Mutex // protects books
+	books map[string]*pb.Book
+}
+
+func newServer() *libraryServer {
+	s := &libraryServer{books: make(map[string]*pb.Book)}
+	for _, b := range seedBooks() {
+		s.books[b.Name] = b
+	}
+	return s
+}
+
+// GetBook returns a book by its resource name.
+func (s *libraryServer) GetBook(_ context.Context, req *pb.GetBookRequest) (*pb.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(b).(*pb.Book), nil
+}
+
+// ListBooks returns the books of a shelf matching the filters, by name.
+func (s *libraryServer) ListBooks(_ context.Context, req *pb.ListBooksRequest) (*pb.ListBooksResponse, error) {
+	if err := checkShelf(req.Parent); err != nil {
+		return nil, err
+	}
+	if req.PageSize < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must not be negative, got %d", req.PageSize)
+	}
+	books := s.match(func(b *pb.Book) bool {
+		if !inShelf(b, req.Parent) || (req.Genre != pb.Genre_GENRE_UNSPECIFIED && b.Genre != req.Genre) {
+			return false
+		}
+		if req.PublishedAfter != nil && !b.PublishTime.AsTime().After(req.PublishedAfter.AsTime()) {
+			return false
+		}
+		for _, tag := range req.Tags {
+			if !hasTag(b, tag) {
+				return false
+			}
+		}
+		return true
+	})
+	resp := &pb.ListBooksResponse{TotalSize: int64(len(books))}
+	if req.PageSize > 0 && int(req.PageSize) < len(books) {
+		books = books[:req.PageSize]
+	}
+	resp.Books = books
+	return resp, nil
+}
+
+// CreateBook adds a book to a shelf under parent/books/book_id.
+func (s *libraryServer) CreateBook(_ context.Context, req *pb.CreateBookRequest) (*pb.Book, error) {
+	if err := checkShelf(req.Parent); err != nil {
+		return nil, err
+	}
+	if req.BookId == "" || strings.Contains(req.BookId, "/") {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid book_id %q", req.BookId)
+	}
+	if req.Book.GetTitle() == "" {
+		return nil, status.Error(codes.InvalidArgument, "book.title is required")
+	}
+	b := proto.Clone(req.Book).(*pb.Book)
+	b.Name = req.Parent + "/books/" + req.BookId
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.books[b.Name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "book %s already exists", b.Name)
+	}
+	s.books[b.Name] = b
+	log.Printf("[CreateBook] %s", b.Name)
+	return proto.Clone(b).(*pb.Book), nil
+}
+
+// UpdateBook replaces the fields of a book named in update_mask, or all of
+// them but the name if the mask is empty.
+func (s *libraryServer) UpdateBook(_ context.Context, req *pb.UpdateBookRequest) (*pb.Book, error) {
+	if req.Book == nil {
+		return nil, status.Error(codes.InvalidArgument, "book is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.lookup(req.Book.Name)
+	if err != nil {
+		return nil, err
+	}
+	updated := proto.Clone(b).(*pb.Book)
+	src, dst := req.Book.ProtoReflect(), updated.ProtoReflect()
+	fields := dst.Descriptor().Fields()
+	paths := req.UpdateMask.GetPaths()
+	if len(paths) == 0 {
+		for i := 0; i < fields.Len(); i++ {
+			paths = append(paths, string(fields.Get(i).Name()))
+		}
+	}
+	for _, path := range paths {
+		fd := fields.ByName(protoreflect.Name(path))
+		if fd == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown field %q in update_mask", path)
+		}
+		if fd.Name() == "name" {
+			continue
+		}
+		// Setting one member of a oneof clears the others, so clearing an
+		// unset member must not undo a set one.
+		if src.Has(fd) {
+			dst.Set(fd, src.Get(fd))
+		} else if oneof := fd.ContainingOneof(); oneof == nil || src.WhichOneof(oneof) == nil {
+			dst.Clear(fd)
+		}
+	}
+	s.books[updated.Name] = updated
+	log.Printf("[UpdateBook] %s %v", updated.Name, paths)
+	return proto.Clone(updated).(*pb.Book), nil
+}
+
+// DeleteBook removes a book.
+func (s *libraryServer) DeleteBook(_ context.Context, req *pb.DeleteBookRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.lookup(req.Name); err != nil {
+		return nil, err
+	}
+	delete(s.books, req.Name)
+	log.Printf("[DeleteBook] %s", req.Name)
+	return &emptypb.Empty{}, nil
+}
+
+// SearchBooks finds books by title or by author across all shelves.
+func (s *libraryServer) SearchBooks(_ context.Context, req *pb.SearchBooksRequest) (*pb.SearchBooksResponse, error) {
+	var match func(b *pb.Book) bool
+	switch q := req.Query.(type) {
+	case *pb.SearchBooksRequest_Title:
+		title := strings.ToLower(q.Title)
+		match = func(b *pb.Book) bool { return strings.Contains(strings.ToLower(b.Title), title) }
+	case *pb.SearchBooksRequest_AuthorId:
+		match = func(b *pb.Book) bool {
+			for _, a := range b.Authors {
+				if a.Id == q.AuthorId {
+					return true
+				}
+			}
+			return false
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "one of title or author_id is required")
+	}
+	books := s.match(match)
+	if req.Limit > 0 && int(req.Limit) < len(books) {
+		books = books[:req.Limit]
+	}
+	return &pb.SearchBooksResponse{Books: books}, nil
+}
+
+// StreamBooks sends the books of a shelf one message at a time.
+func (s *libraryServer) StreamBooks(req *pb.StreamBooksRequest, stream pb.LibraryService_StreamBooksServer) error {
+	if err := checkShelf(req.Parent); err != nil {
+		return err
+	}
+	books := s.match(func(b *pb.Book) bool {
+		return inShelf(b, req.Parent) && (req.Genre == pb.Genre_GENRE_UNSPECIFIED || b.Genre == req.Genre)
+	})
+	for _, b := range books {
+		if err := stream.Send(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup returns the stored book with the given name. s.mu must be held.
+func (s *libraryServer) lookup(name string) (*pb.Book, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 4 || parts[0] != "shelves" || parts[2] != "books" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid book name %q", name)
+	}
+	b, ok := s.books[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "book %s not found", name)
+	}
+	return b, nil
+}
+
+// match returns copies of the books accepted by keep, by name.
+func (s *libraryServer) match(keep func(b *pb.Book) bool) []*pb.Book {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*pb.Book
+	for _, b := range s.books {
+		if keep(b) {
+			out = append(out, proto.Clone(b).(*pb.Book))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func checkShelf(parent string) error {
+	for _, shelf := range shelves {
+		if parent == shelf {
+			return nil
+		}
+	}
+	return status.Errorf(codes.NotFound, "shelf %q not found", parent)
+}
+
+func inShelf(b *pb.Book, parent string) bool {
+	return strings.HasPrefix(b.Name, parent+"/books/")
+}
+
+func hasTag(b *pb.Book, tag string) bool {
+	for _, t := range b.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	flag.Parse()
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterLibraryServiceServer(grpcServer, newServer())
+
+	// An annotation-aware gateway reads the google.api.http rules from the
+	// descriptors served here.
+	reflection.Register(grpcServer)
+
+	log.Printf("library server listening on port %d", *port)
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
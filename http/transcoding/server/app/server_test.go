@@ -0,0 +1,347 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+import (
+	pb "github.com/dubbo-go-pixiu/samples/http/transcoding/proto"
+)
+
+// The seeded books as proto3 JSON: lowerCamelCase names, 64-bit integers as
+// strings, enums by name, well-known types in their JSON forms and unset
+// fields left out.
+const (
+	leftHandOfDarkness = `{
+		"name": "shelves/1/books/1",
+		"title": "The Left Hand of Darkness",
+		"authors": [{"id": "9007199254740993", "displayName": "Ursula K. Le Guin"}],
+		"genre": "FICTION",
+		"pageCount": "304",
+		"isbn": "9780441478125",
+		"tags": ["classic", "award-winner"],
+		"publishTime": "1969-03-01T00:00:00Z",
+		"readingTime": "21600s",
+		"rating": 5,
+		"attributes": {"series": "Hainish Cycle", "hardcover": true, "awards": ["Hugo", "Nebula"]},
+		"shelfLocation": "A-12"
+	}`
+	briefHistoryOfTime = `{
+		"name": "shelves/1/books/2",
+		"title": "A Brief History of Time",
+		"authors": [{"id": "2", "displayName": "Stephen Hawking"}],
+		"genre": "SCIENCE",
+		"pageCount": "256",
+		"isbn": "9780553380163",
+		"tags": ["physics", "classic"],
+		"publishTime": "1988-04-01T00:00:00Z",
+		"readingTime": "5400s",
+		"loan": {"borrower": "ada", "dueTime": "2030-01-15T12:00:00Z"}
+	}`
+	cosmos = `{
+		"name": "shelves/2/books/4",
+		"title": "Cosmos",
+		"authors": [{"id": "4", "displayName": "Carl Sagan"}],
+		"genre": "SCIENCE",
+		"pageCount": "396",
+		"isbn": "9780345539434",
+		"tags": ["physics", "astronomy"],
+		"publishTime": "1980-10-01T00:00:00Z",
+		"readingTime": "28800s",
+		"rating": 5,
+		"shelfLocation": "B-7"
+	}`
+)
+
+// startLibrary serves a fresh library on a local port and returns a client
+// for it, so every message crosses the gRPC wire as it would behind a
+// gateway.
+func startLibrary(t *testing.T) (pb.LibraryServiceClient, context.Context) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	pb.RegisterLibraryServiceServer(server, newServer())
+	go func() { _ = server.Serve(lis) }()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(func() {
+		cancel()
+		conn.Close()
+		server.Stop()
+	})
+	return pb.NewLibraryServiceClient(conn), ctx
+}
+
+// request decodes a JSON request the way a gateway does with a body or
+// query: proto and JSON names are accepted, 64-bit integers as numbers or
+// strings.
+func request[M proto.Message](t *testing.T, m M, data string) M {
+	t.Helper()
+	require.NoError(t, protojson.Unmarshal([]byte(data), m), data)
+	return m
+}
+
+// requireJSON asserts that m renders as exactly the expected proto3 JSON.
+func requireJSON(t *testing.T, expected string, m proto.Message, err error) {
+	t.Helper()
+	require.NoError(t, err)
+	got, err := protojson.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, string(got))
+}
+
+// requireStatus asserts an error whose google.rpc.Status body carries code.
+func requireStatus(t *testing.T, code codes.Code, err error) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	require.True(t, ok, "expected a gRPC status, got %v", err)
+	assert.Equal(t, code, st.Code())
+	body, err := protojson.Marshal(st.Proto())
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"message"`)
+}
+
+func TestHTTPRules(t *testing.T) {
+	type binding struct{ verb, path, body string }
+	rules := map[string][]binding{
+		"GetBook":     {{"GET", "/v1/{name=shelves/*/books/*}", ""}},
+		"ListBooks":   {{"GET", "/v1/{parent=shelves/*}/books", ""}},
+		"CreateBook":  {{"POST", "/v1/{parent=shelves/*}/books", "book"}},
+		"UpdateBook":  {{"PATCH", "/v1/{book.name=shelves/*/books/*}", "book"}},
+		"DeleteBook":  {{"DELETE", "/v1/{name=shelves/*/books/*}", ""}},
+		"SearchBooks": {{"POST", "/v1/books:search", "*"}, {"GET", "/v1/books:search", ""}},
+		"StreamBooks": {{"GET", "/v1/{parent=shelves/*}/books:stream", ""}},
+	}
+	bindingOf := func(rule *annotations.HttpRule) binding {
+		switch p := rule.Pattern.(type) {
+		case *annotations.HttpRule_Get:
+			return binding{"GET", p.Get, rule.Body}
+		case *annotations.HttpRule_Post:
+			return binding{"POST", p.Post, rule.Body}
+		case *annotations.HttpRule_Patch:
+			return binding{"PATCH", p.Patch, rule.Body}
+		case *annotations.HttpRule_Delete:
+			return binding{"DELETE", p.Delete, rule.Body}
+		}
+		return binding{}
+	}
+
+	methods := pb.File_library_proto.Services().ByName("LibraryService").Methods()
+	require.Equal(t, len(rules), methods.Len())
+	for i := 0; i < methods.Len(); i++ {
+		method := methods.Get(i)
+		rule, _ := proto.GetExtension(method.Options(), annotations.E_Http).(*annotations.HttpRule)
+		require.NotNil(t, rule, "%s has no google.api.http rule", method.Name())
+		got := []binding{bindingOf(rule)}
+		for _, extra := range rule.AdditionalBindings {
+			got = append(got, bindingOf(extra))
+		}
+		assert.Equal(t, rules[string(method.Name())], got, method.Name())
+	}
+}
+
+func TestGetBook(t *testing.T) {
+	client, ctx := startLibrary(t)
+	book, err := client.GetBook(ctx, &pb.GetBookRequest{Name: "shelves/1/books/1"})
+	requireJSON(t, leftHandOfDarkness, book, err)
+	// The other member of the oneof, and an unset wrapper left out.
+	book, err = client.GetBook(ctx, &pb.GetBookRequest{Name: "shelves/1/books/2"})
+	requireJSON(t, briefHistoryOfTime, book, err)
+}
+
+func TestGetBookNotFound(t *testing.T) {
+	client, ctx := startLibrary(t)
+	_, err := client.GetBook(ctx, &pb.GetBookRequest{Name: "shelves/1/books/404"})
+	requireStatus(t, codes.NotFound, err)
+	_, err = client.ListBooks(ctx, &pb.ListBooksRequest{Parent: "shelves/9"})
+	requireStatus(t, codes.NotFound, err)
+}
+
+func TestListBooksQueryBinding(t *testing.T) {
+	client, ctx := startLibrary(t)
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "enum by name",
+			query:    `{"parent": "shelves/1", "genre": "SCIENCE"}`,
+			expected: `{"books": [` + briefHistoryOfTime + `], "totalSize": "1"}`,
+		},
+		{
+			name:     "repeated field",
+			query:    `{"parent": "shelves/2", "tags": ["physics", "astronomy"]}`,
+			expected: `{"books": [` + cosmos + `], "totalSize": "1"}`,
+		},
+		{
+			name:     "timestamp",
+			query:    `{"parent": "shelves/1", "published_after": "1980-01-01T00:00:00Z"}`,
+			expected: `{"books": [` + briefHistoryOfTime + `], "totalSize": "1"}`,
+		},
+		{
+			name:     "json name",
+			query:    `{"parent": "shelves/1", "pageSize": 1}`,
+			expected: `{"books": [` + leftHandOfDarkness + `], "totalSize": "2"}`,
+		},
+		{
+			name:     "proto name",
+			query:    `{"parent": "shelves/1", "page_size": 1, "genre": "HISTORY"}`,
+			expected: `{}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.ListBooks(ctx, request(t, &pb.ListBooksRequest{}, tt.query))
+			requireJSON(t, tt.expected, resp, err)
+		})
+	}
+}
+
+func TestCreateUpdateDeleteBook(t *testing.T) {
+	client, ctx := startLibrary(t)
+	const name = "shelves/2/books/dune"
+
+	// Request bodies accept proto and JSON names, and 64-bit integers as
+	// numbers or strings; responses always use the canonical form.
+	created := `{
+		"name": "shelves/2/books/dune",
+		"title": "Dune",
+		"authors": [{"id": "10", "displayName": "Frank Herbert"}],
+		"genre": "FICTION",
+		"pageCount": "412",
+		"tags": ["desert"],
+		"publishTime": "1965-08-01T00:00:00Z",
+		"readingTime": "3600.500s",
+		"rating": 4,
+		"attributes": {"series": "Dune Chronicles", "volume": 1},
+		"shelfLocation": "D-1"
+	}`
+	book := request(t, &pb.Book{}, `{
+		"title": "Dune",
+		"authors": [{"id": 10, "display_name": "Frank Herbert"}],
+		"genre": "FICTION",
+		"page_count": 412,
+		"tags": ["desert"],
+		"publishTime": "1965-08-01T00:00:00Z",
+		"readingTime": "3600.5s",
+		"rating": 4,
+		"attributes": {"series": "Dune Chronicles", "volume": 1},
+		"shelfLocation": "D-1"
+	}`)
+	resp, err := client.CreateBook(ctx, &pb.CreateBookRequest{Parent: "shelves/2", BookId: "dune", Book: book})
+	requireJSON(t, created, resp, err)
+	resp, err = client.GetBook(ctx, &pb.GetBookRequest{Name: name})
+	requireJSON(t, created, resp, err)
+	_, err = client.CreateBook(ctx, &pb.CreateBookRequest{Parent: "shelves/2", BookId: "dune", Book: &pb.Book{Title: "Dune"}})
+	requireStatus(t, codes.AlreadyExists, err)
+
+	// Only the masked fields change; setting loan clears shelfLocation.
+	updated := `{
+		"name": "shelves/2/books/dune",
+		"title": "Dune Messiah",
+		"authors": [{"id": "10", "displayName": "Frank Herbert"}],
+		"genre": "FICTION",
+		"pageCount": "412",
+		"tags": ["desert"],
+		"publishTime": "1965-08-01T00:00:00Z",
+		"readingTime": "3600.500s",
+		"attributes": {"series": "Dune Chronicles", "volume": 1},
+		"loan": {"borrower": "paul", "dueTime": "2031-02-03T04:05:06.789Z"}
+	}`
+	resp, err = client.UpdateBook(ctx, request(t, &pb.UpdateBookRequest{}, `{
+		"book": {
+			"name": "shelves/2/books/dune",
+			"title": "Dune Messiah",
+			"pageCount": "1",
+			"loan": {"borrower": "paul", "dueTime": "2031-02-03T04:05:06.789Z"}
+		},
+		"updateMask": "title,loan,rating"
+	}`))
+	requireJSON(t, updated, resp, err)
+	_, err = client.UpdateBook(ctx, request(t, &pb.UpdateBookRequest{}, `{
+		"book": {"name": "shelves/2/books/dune", "title": "x"},
+		"updateMask": "publisher"
+	}`))
+	requireStatus(t, codes.InvalidArgument, err)
+
+	empty, err := client.DeleteBook(ctx, &pb.DeleteBookRequest{Name: name})
+	requireJSON(t, `{}`, empty, err)
+	_, err = client.GetBook(ctx, &pb.GetBookRequest{Name: name})
+	requireStatus(t, codes.NotFound, err)
+}
+
+func TestSearchBooks(t *testing.T) {
+	client, ctx := startLibrary(t)
+	// An int64 oneof member past 2^53, as a string and as a number.
+	for _, query := range []string{`{"authorId": "9007199254740993"}`, `{"author_id": 9007199254740993}`} {
+		resp, err := client.SearchBooks(ctx, request(t, &pb.SearchBooksRequest{}, query))
+		requireJSON(t, `{"books": [`+leftHandOfDarkness+`]}`, resp, err)
+	}
+	resp, err := client.SearchBooks(ctx, request(t, &pb.SearchBooksRequest{}, `{"title": "COS", "limit": 1}`))
+	requireJSON(t, `{"books": [`+cosmos+`]}`, resp, err)
+	resp, err = client.SearchBooks(ctx, request(t, &pb.SearchBooksRequest{}, `{"title": "no such book"}`))
+	requireJSON(t, `{}`, resp, err)
+	_, err = client.SearchBooks(ctx, &pb.SearchBooksRequest{})
+	requireStatus(t, codes.InvalidArgument, err)
+}
+
+func TestStreamBooks(t *testing.T) {
+	client, ctx := startLibrary(t)
+	stream, err := client.StreamBooks(ctx, &pb.StreamBooksRequest{Parent: "shelves/1"})
+	require.NoError(t, err)
+
+	// One message per NDJSON line, in the order the server sent them.
+	var lines []string
+	for {
+		book, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		line, err := protojson.Marshal(book)
+		require.NoError(t, err)
+		lines = append(lines, string(line))
+	}
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, leftHandOfDarkness, lines[0])
+	assert.JSONEq(t, briefHistoryOfTime, lines[1])
+}
@@ -40,6 +40,8 @@ const (
 	authBaseURL    = "http://localhost:9000"
 	clientID       = "sample-client"
 	redirectURI    = "http://localhost:8081/callback"
+	testUsername   = "demo"
+	testPassword   = "demo"
 )
 
 // JSON-RPC request/response types
@@ -129,6 +131,10 @@ func getAuthorizationCode(t *testing.T, codeChallenge string) string {
 	params.Set("code_challenge", codeChallenge)
 	params.Set("code_challenge_method", "S256")
 	params.Set("resource", pixiuBaseURL+mcpPath)
+	// Log in as the authorization server's test user and approve on the consent page
+	params.Set("username", testUsername)
+	params.Set("password", testPassword)
+	params.Set("action", "approve")
 
 	client := &http.Client{
 		Timeout: 5 * time.Second,
@@ -137,7 +143,7 @@ func getAuthorizationCode(t *testing.T, codeChallenge string) string {
 		},
 	}
 
-	resp, err := client.Get(authBaseURL + "/oauth/authorize?" + params.Encode())
+	resp, err := client.PostForm(authBaseURL+"/oauth/authorize", params)
 	if err != nil {
 		t.Fatalf("authorization request failed: %v", err)
 	}
@@ -203,6 +209,14 @@ func exchangeCodeForToken(t *testing.T, code, codeVerifier string) string {
 }
 
 func sendJSONRPC(t *testing.T, method string, params any, token string) (int, []byte) {
+	t.Helper()
+	status, _, body := postMCP(t, method, params, token)
+	return status, body
+}
+
+// postMCP sends a JSON-RPC request to the protected MCP endpoint and also
+// returns the response headers, which carry the WWW-Authenticate challenge.
+func postMCP(t *testing.T, method string, params any, token string) (int, http.Header, []byte) {
 	t.Helper()
 	reqObj := JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params}
 	reqBody, err := json.Marshal(reqObj)
@@ -231,7 +245,7 @@ func sendJSONRPC(t *testing.T, method string, params any, token string) (int, []
 	if err != nil {
 		t.Fatalf("failed to read response: %v", err)
 	}
-	return resp.StatusCode, body
+	return resp.StatusCode, resp.Header, body
 }
 
 // TestUnauthorizedAccess verifies requests without tokens are rejected
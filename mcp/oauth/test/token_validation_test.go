@@ -0,0 +1,268 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Negative-path tests for the MCP authorization filter. The tokens come from
+// the authorization server's /test/mint endpoint, so it must run with -test_mint.
+package test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mintRequest mirrors the body accepted by the authorization server's /test/mint.
+type mintRequest struct {
+	Claims         map[string]any `json:"claims,omitempty"`
+	ExpiresIn      int64          `json:"expires_in,omitempty"`
+	Kid            string         `json:"kid,omitempty"`
+	UnpublishedKey bool           `json:"unpublished_key,omitempty"`
+}
+
+// mintToken asks the authorization server for a token with arbitrary claims.
+// The test fails when the server was started without -test_mint.
+func mintToken(t *testing.T, mint mintRequest) string {
+	t.Helper()
+	reqBody, err := json.Marshal(mint)
+	if err != nil {
+		t.Fatalf("failed to marshal mint request: %v", err)
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(authBaseURL+"/test/mint", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		t.Fatalf("mint request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		t.Fatal("the authorization server must run with -test_mint")
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("expected 200 from mint endpoint, got %d: %s", resp.StatusCode, string(body))
+	}
+	var minted struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&minted); err != nil {
+		t.Fatalf("failed to decode mint response: %v", err)
+	}
+	return minted.AccessToken
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// bearerChallenge parses the parameters of a Bearer WWW-Authenticate header.
+func bearerChallenge(t *testing.T, header http.Header) map[string]string {
+	t.Helper()
+	value := header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(strings.ToLower(value), "bearer") {
+		t.Fatalf("expected a Bearer challenge, got WWW-Authenticate %q", value)
+	}
+	params := map[string]string{}
+	for _, match := range challengeParam.FindAllStringSubmatch(value, -1) {
+		params[match[1]] = match[2]
+	}
+	return params
+}
+
+// tamperClaims rewrites the token payload and keeps the original signature.
+func tamperClaims(t *testing.T, token string, edit func(map[string]any)) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("failed to decode token payload: %v", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("failed to parse token payload: %v", err)
+	}
+	edit(claims)
+	payload, _ = json.Marshal(claims)
+	return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+}
+
+// tamperSignature flips one bit in the middle of the signature.
+func tamperSignature(t *testing.T, token string) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("failed to decode token signature: %v", err)
+	}
+	sig[len(sig)/2] ^= 0x01
+	return parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// TestMintedTokenAccepted makes sure the rejections below are caused by the
+// altered property and not by the minted tokens themselves.
+func TestMintedTokenAccepted(t *testing.T) {
+	token := mintToken(t, mintRequest{})
+	status, body := sendJSONRPC(t, "tools/list", nil, token)
+	if status != http.StatusOK {
+		t.Fatalf("minted token rejected: %d, %s", status, string(body))
+	}
+}
+
+// TestRejectedTokens verifies that every invalid token gets a 401 with a
+// Bearer challenge instead of reaching the MCP server.
+func TestRejectedTokens(t *testing.T) {
+	testCases := []struct {
+		name  string
+		token func(t *testing.T) string
+	}{
+		{
+			name: "Expired",
+			token: func(t *testing.T) string {
+				// Well beyond any clock skew the filter may allow.
+				return mintToken(t, mintRequest{ExpiresIn: -600})
+			},
+		},
+		{
+			name: "Unknown kid",
+			token: func(t *testing.T) string {
+				return mintToken(t, mintRequest{Kid: "unknown-kid"})
+			},
+		},
+		{
+			name: "Key missing from JWKS",
+			token: func(t *testing.T) string {
+				return mintToken(t, mintRequest{UnpublishedKey: true})
+			},
+		},
+		{
+			name: "Wrong audience",
+			token: func(t *testing.T) string {
+				return mintToken(t, mintRequest{Claims: map[string]any{"aud": pixiuBaseURL + "/other"}})
+			},
+		},
+		{
+			name: "Missing audience",
+			token: func(t *testing.T) string {
+				return mintToken(t, mintRequest{Claims: map[string]any{"aud": nil}})
+			},
+		},
+		{
+			name: "Wrong issuer",
+			token: func(t *testing.T) string {
+				return mintToken(t, mintRequest{Claims: map[string]any{"iss": "http://localhost:9999"}})
+			},
+		},
+		{
+			name: "Tampered claims",
+			token: func(t *testing.T) string {
+				token := mintToken(t, mintRequest{Claims: map[string]any{"scope": "read"}})
+				return tamperClaims(t, token, func(claims map[string]any) {
+					claims["scope"] = "read write"
+				})
+			},
+		},
+		{
+			name: "Tampered signature",
+			token: func(t *testing.T) string {
+				return tamperSignature(t, mintToken(t, mintRequest{}))
+			},
+		},
+		{
+			name: "Malformed",
+			token: func(t *testing.T) string {
+				return "not-a-jwt"
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, header, body := postMCP(t, "tools/list", nil, tc.token(t))
+			if status != http.StatusUnauthorized {
+				t.Fatalf("expected 401, got %d: %s", status, string(body))
+			}
+			challenge := bearerChallenge(t, header)
+			if errCode, ok := challenge["error"]; ok && errCode != "invalid_token" {
+				t.Errorf("expected error=\"invalid_token\", got %q", errCode)
+			}
+		})
+	}
+}
+
+// TestResourceMetadataDiscovery follows the 401 challenge to the protected
+// resource metadata (RFC 9728) and on to the authorization server and JWKS.
+func TestResourceMetadataDiscovery(t *testing.T) {
+	status, header, body := postMCP(t, "tools/list", nil, "")
+	if status != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d: %s", status, string(body))
+	}
+	metadataURL := bearerChallenge(t, header)["resource_metadata"]
+	if metadataURL != pixiuBaseURL+"/.well-known/oauth-protected-resource/mcp" {
+		t.Fatalf("unexpected resource_metadata %q", metadataURL)
+	}
+
+	var resourceMeta struct {
+		Resource             string   `json:"resource"`
+		AuthorizationServers []string `json:"authorization_servers"`
+	}
+	getJSON(t, metadataURL, &resourceMeta)
+	if resourceMeta.Resource != pixiuBaseURL+mcpPath {
+		t.Errorf("expected resource %s, got %q", pixiuBaseURL+mcpPath, resourceMeta.Resource)
+	}
+	if len(resourceMeta.AuthorizationServers) == 0 || resourceMeta.AuthorizationServers[0] != authBaseURL {
+		t.Fatalf("expected authorization server %s, got %v", authBaseURL, resourceMeta.AuthorizationServers)
+	}
+
+	var serverMeta struct {
+		Issuer  string `json:"issuer"`
+		JwksURI string `json:"jwks_uri"`
+	}
+	getJSON(t, resourceMeta.AuthorizationServers[0]+"/.well-known/oauth-authorization-server", &serverMeta)
+	if serverMeta.Issuer != authBaseURL || serverMeta.JwksURI == "" {
+		t.Fatalf("unexpected authorization server metadata: %+v", serverMeta)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+		} `json:"keys"`
+	}
+	getJSON(t, serverMeta.JwksURI, &jwks)
+	if len(jwks.Keys) == 0 || jwks.Keys[0].Kid == "" {
+		t.Fatalf("JWKS publishes no keys: %+v", jwks)
+	}
+}
+
+func getJSON(t *testing.T, url string, v any) {
+	t.Helper()
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("GET %s: expected 200, got %d: %s", url, resp.StatusCode, string(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("GET %s: failed to decode response: %v", url, err)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net/http"
+	"sort"
+)
+
+// apiDocs is the API reference the MCP server publishes as a resource.
+const apiDocs = `# Mock Backend API
+
+All bodies are JSON. Errors use {"error": {"status", "code", "message", "details"}}.
+
+## Users
+
+| Method | Path | Notes |
+| --- | --- | --- |
+| GET | /api/users?page&limit | all users by ID, limit 1-100 |
+| GET | /api/users/search?q&page&limit | name or email contains q |
+| POST | /api/users | name and email required, 409 on a duplicate email |
+| GET | /api/users/{id}?include_profile | returns an ETag |
+| PUT | /api/users/{id} | replace, honors If-Match |
+| PATCH | /api/users/{id} | change some fields, honors If-Match |
+| DELETE | /api/users/{id} | deletes the posts too, honors If-Match |
+
+## Posts
+
+| Method | Path | Notes |
+| --- | --- | --- |
+| GET | /api/users/{id}/posts?status&page&limit | status published (default), draft, archived or all |
+| POST | /api/users/{id}/posts | title and content required, status defaults to draft |
+| GET | /api/posts/{id} | returns an ETag |
+| PUT | /api/posts/{id} | replace, honors If-Match |
+| PATCH | /api/posts/{id} | change some fields, honors If-Match |
+| DELETE | /api/posts/{id} | honors If-Match |
+
+## Testing
+
+| Method | Path | Notes |
+| --- | --- | --- |
+| ANY | /api/errors/{status} | responds with a 4xx/5xx status |
+| POST | /api/reset | restores the seeded data |
+`
+
+// Docs handler serves the API reference as markdown
+func docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Write([]byte(apiDocs))
+}
+
+// Error codes handler lists every error code the backend returns
+func errorCodesHandler(w http.ResponseWriter, r *http.Request) {
+	codes := map[string]int{"method_not_allowed": http.StatusMethodNotAllowed}
+	for status, code := range statusCodes {
+		codes[code] = status
+	}
+	type errorCode struct {
+		Code   string `json:"code"`
+		Status int    `json:"status"`
+	}
+	out := make([]errorCode, 0, len(codes))
+	for code, status := range codes {
+		out = append(out, errorCode{Code: code, Status: status})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Status < out[j].Status })
+	writeJSON(w, http.StatusOK, out)
+}
@@ -19,11 +19,12 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/mail"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -31,26 +32,6 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// User represents a user structure
-type User struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Age      int    `json:"age,omitempty"`
-	Profile  string `json:"profile,omitempty"`
-	CreateAt string `json:"created_at"`
-}
-
-// Post represents a post structure
-type Post struct {
-	ID       int    `json:"id"`
-	UserID   int    `json:"user_id"`
-	Title    string `json:"title"`
-	Content  string `json:"content"`
-	Status   string `json:"status"`
-	CreateAt string `json:"created_at"`
-}
-
 // SearchResult represents search result structure
 type SearchResult struct {
 	Users      []User `json:"users"`
@@ -60,34 +41,56 @@ type SearchResult struct {
 	TotalPages int    `json:"total_pages"`
 }
 
-// CreateUserRequest represents create user request structure
+// CreateUserRequest represents create user request structure, also used to
+// replace a user with PUT
 type CreateUserRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Age   int    `json:"age,omitempty"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Age     int    `json:"age,omitempty"`
+	Profile string `json:"profile,omitempty"`
 }
 
-// Mock data
-var users = []User{
-	{ID: 1, Name: "Alice Johnson", Email: "alice@example.com", Age: 28, Profile: "Software Engineer at TechCorp", CreateAt: "2023-01-15T10:30:00Z"},
-	{ID: 2, Name: "Bob Smith", Email: "bob@example.com", Age: 32, Profile: "Product Manager at StartupXYZ", CreateAt: "2023-02-20T14:45:00Z"},
-	{ID: 3, Name: "Charlie Brown", Email: "charlie@example.com", Age: 25, Profile: "Designer at CreativeStudio", CreateAt: "2023-03-10T09:15:00Z"},
-	{ID: 4, Name: "Diana Prince", Email: "diana@example.com", Age: 30, Profile: "Data Scientist at DataCorp", CreateAt: "2023-04-05T16:20:00Z"},
-	{ID: 5, Name: "Eve Wilson", Email: "eve@example.com", Age: 27, Profile: "DevOps Engineer at CloudTech", CreateAt: "2023-05-12T11:10:00Z"},
+// PatchUserRequest holds the fields a PATCH changes; absent fields are kept
+type PatchUserRequest struct {
+	Name    *string `json:"name"`
+	Email   *string `json:"email"`
+	Age     *int    `json:"age"`
+	Profile *string `json:"profile"`
 }
 
-var posts = []Post{
-	{ID: 1, UserID: 1, Title: "Getting Started with Go", Content: "Go is a great language for backend development...", Status: "published", CreateAt: "2023-06-01T10:00:00Z"},
-	{ID: 2, UserID: 1, Title: "Microservices Architecture", Content: "Building scalable microservices...", Status: "published", CreateAt: "2023-06-15T14:30:00Z"},
-	{ID: 3, UserID: 2, Title: "Product Management 101", Content: "Essential skills for product managers...", Status: "published", CreateAt: "2023-06-20T09:45:00Z"},
-	{ID: 4, UserID: 3, Title: "UI/UX Design Trends", Content: "Latest trends in user interface design...", Status: "draft", CreateAt: "2023-06-25T16:15:00Z"},
-	{ID: 5, UserID: 4, Title: "Data Analysis with Python", Content: "Analyzing data using pandas and numpy...", Status: "published", CreateAt: "2023-07-01T12:00:00Z"},
+// CreatePostRequest represents create post request structure, also used to
+// replace a post with PUT
+type CreatePostRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Status  string `json:"status,omitempty"`
 }
 
-var nextUserID = 6
+// PatchPostRequest holds the fields a PATCH changes; absent fields are kept
+type PatchPostRequest struct {
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+	Status  *string `json:"status"`
+}
+
+var (
+	addr      = flag.String("addr", ":8081", "listen address")
+	userCount = flag.Int("users", 2000, "number of seeded users, including the five sample users")
+)
+
+var db *store
 
 func main() {
+	flag.Parse()
+	db = newStore(*userCount)
+
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, errNotFound("no route for %s %s", r.Method, r.URL.Path))
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, &apiError{Status: http.StatusMethodNotAllowed, Code: "method_not_allowed", Message: r.Method + " is not allowed on " + r.URL.Path})
+	})
 
 	// Add CORS middleware
 	r.Use(corsMiddleware)
@@ -97,10 +100,29 @@ func main() {
 	api := r.PathPrefix("/api").Subrouter()
 
 	// User related routes
-	api.HandleFunc("/users/{id:[0-9]+}", getUserHandler).Methods("GET")
-	api.HandleFunc("/users/search", searchUsersHandler).Methods("GET")
+	api.HandleFunc("/users", listUsersHandler).Methods("GET")
 	api.HandleFunc("/users", createUserHandler).Methods("POST")
+	api.HandleFunc("/users/search", searchUsersHandler).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}", getUserHandler).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}", replaceUserHandler).Methods("PUT")
+	api.HandleFunc("/users/{id:[0-9]+}", patchUserHandler).Methods("PATCH")
+	api.HandleFunc("/users/{id:[0-9]+}", deleteUserHandler).Methods("DELETE")
 	api.HandleFunc("/users/{id:[0-9]+}/posts", getUserPostsHandler).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}/posts", createPostHandler).Methods("POST")
+
+	// Post related routes
+	api.HandleFunc("/posts/{id:[0-9]+}", getPostHandler).Methods("GET")
+	api.HandleFunc("/posts/{id:[0-9]+}", replacePostHandler).Methods("PUT")
+	api.HandleFunc("/posts/{id:[0-9]+}", patchPostHandler).Methods("PATCH")
+	api.HandleFunc("/posts/{id:[0-9]+}", deletePostHandler).Methods("DELETE")
+
+	// Documentation, published as MCP resources
+	api.HandleFunc("/docs", docsHandler).Methods("GET")
+	api.HandleFunc("/docs/errors", errorCodesHandler).Methods("GET")
+
+	// Error simulation and test support
+	api.HandleFunc("/errors/{status:[0-9]+}", errorHandler)
+	api.HandleFunc("/reset", resetHandler).Methods("POST")
 
 	// Health check
 	api.HandleFunc("/health", healthHandler).Methods("GET")
@@ -108,24 +130,39 @@ func main() {
 	// Root path
 	r.HandleFunc("/", rootHandler).Methods("GET")
 
-	fmt.Println("🚀 Mock Backend Server starting on :8081")
+	users, posts := db.counts()
+	fmt.Printf("🚀 Mock Backend Server starting on %s with %d users and %d posts\n", *addr, users, posts)
 	fmt.Println("📚 Available endpoints:")
-	fmt.Println("  GET  /api/users/{id}        - Get user by ID")
-	fmt.Println("  GET  /api/users/search      - Search users")
-	fmt.Println("  POST /api/users             - Create user")
-	fmt.Println("  GET  /api/users/{id}/posts  - Get user posts")
-	fmt.Println("  GET  /api/health            - Health check")
-	fmt.Println("  GET  /                      - Root endpoint")
-
-	log.Fatal(http.ListenAndServe(":8081", r))
+	fmt.Println("  GET    /api/users                - List users")
+	fmt.Println("  POST   /api/users                - Create user")
+	fmt.Println("  GET    /api/users/search         - Search users")
+	fmt.Println("  GET    /api/users/{id}           - Get user by ID")
+	fmt.Println("  PUT    /api/users/{id}           - Replace user (If-Match)")
+	fmt.Println("  PATCH  /api/users/{id}           - Update user (If-Match)")
+	fmt.Println("  DELETE /api/users/{id}           - Delete user and posts (If-Match)")
+	fmt.Println("  GET    /api/users/{id}/posts     - Get user posts")
+	fmt.Println("  POST   /api/users/{id}/posts     - Create post")
+	fmt.Println("  GET    /api/posts/{id}           - Get post by ID")
+	fmt.Println("  PUT    /api/posts/{id}           - Replace post (If-Match)")
+	fmt.Println("  PATCH  /api/posts/{id}           - Update post (If-Match)")
+	fmt.Println("  DELETE /api/posts/{id}           - Delete post (If-Match)")
+	fmt.Println("  GET    /api/docs                 - API reference (markdown)")
+	fmt.Println("  GET    /api/docs/errors          - Error codes")
+	fmt.Println("  ANY    /api/errors/{status}      - Respond with an error status")
+	fmt.Println("  POST   /api/reset                - Restore the seeded data")
+	fmt.Println("  GET    /api/health               - Health check")
+	fmt.Println("  GET    /                         - Root endpoint")
+
+	log.Fatal(http.ListenAndServe(*addr, r))
 }
 
 // CORS middleware
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Location")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -145,140 +182,264 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// Get user handler
-func getUserHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userID, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
+// pathID parses the numeric route variable name.
+func pathID(r *http.Request, name string) (int, *apiError) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil || id < 1 {
+		return 0, errBadRequest("invalid %s %q", name, mux.Vars(r)[name])
 	}
+	return id, nil
+}
 
-	// Find user
-	var user *User
-	for _, u := range users {
-		if u.ID == userID {
-			user = &u
-			break
+// pagination reads page (default 1) and limit (default 10, at most 100).
+func pagination(r *http.Request) (int, int, *apiError) {
+	page, limit := 1, 10
+	if v := r.URL.Query().Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, 0, errBadRequest("page must be a positive integer, got %q", v)
+		}
+		page = n
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			return 0, 0, errBadRequest("limit must be between 1 and 100, got %q", v)
 		}
+		limit = n
 	}
+	return page, limit, nil
+}
 
-	if user == nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
+// paginate returns the items of page and the number of pages.
+func paginate[T any](items []T, page int, limit int) ([]T, int) {
+	total := len(items)
+	totalPages := (total + limit - 1) / limit
+	start := (page - 1) * limit
+	if start >= total {
+		return []T{}, totalPages
 	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+	return items[start:end], totalPages
+}
 
-	// Check if include profile details
-	includeProfile := r.URL.Query().Get("include_profile")
-	if includeProfile != "true" {
-		user.Profile = ""
+// decodeBody reads a JSON request body into v.
+func decodeBody(r *http.Request, v any) *apiError {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return errBadRequest("invalid JSON body: %s", err)
 	}
+	return nil
+}
 
-	fmt.Println("user :", user)
+// notModified answers a GET whose If-None-Match has the current ETag.
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+	if r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+func validateUser(name string, email string, age int) *apiError {
+	var v validation
+	if name == "" {
+		v.add("name", "is required")
+	}
+	if email == "" {
+		v.add("email", "is required")
+	} else if _, err := mail.ParseAddress(email); err != nil {
+		v.add("email", "is not a valid email address")
+	}
+	if age < 0 || age > 150 {
+		v.add("age", "must be between 0 and 150")
+	}
+	return v.err()
 }
 
-// Search users handler
-func searchUsersHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		http.Error(w, "Query parameter 'q' is required", http.StatusBadRequest)
-		return
+func validatePost(title string, content string, status string) *apiError {
+	var v validation
+	if title == "" {
+		v.add("title", "is required")
 	}
+	if content == "" {
+		v.add("content", "is required")
+	}
+	if status != "published" && status != "draft" && status != "archived" {
+		v.add("status", "must be one of published, draft, archived")
+	}
+	return v.err()
+}
 
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
+// List users handler
+func listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	page, limit, apiErr := pagination(r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
 	}
+	users := db.searchUsers("")
+	pageUsers, totalPages := paginate(users, page, limit)
+	writeJSON(w, http.StatusOK, SearchResult{Users: pageUsers, Page: page, Limit: limit, Total: len(users), TotalPages: totalPages})
+}
 
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	if limit < 1 || limit > 100 {
-		limit = 10
+// Get user handler
+func getUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, apiErr := pathID(r, "id")
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
 	}
 
-	// Search users
-	var filteredUsers []User
-	query = strings.ToLower(query)
-	for _, user := range users {
-		if strings.Contains(strings.ToLower(user.Name), query) ||
-			strings.Contains(strings.ToLower(user.Email), query) {
-			filteredUsers = append(filteredUsers, user)
-		}
+	user, ok := db.getUser(userID)
+	if !ok {
+		writeError(w, errNotFound("user %d not found", userID))
+		return
+	}
+	if notModified(w, r, user.ETag()) {
+		return
 	}
 
-	// Pagination
-	total := len(filteredUsers)
-	totalPages := (total + limit - 1) / limit
-	start := (page - 1) * limit
-	end := start + limit
+	// Check if include profile details
+	if r.URL.Query().Get("include_profile") != "true" {
+		user.Profile = ""
+	}
+	writeJSON(w, http.StatusOK, user)
+}
 
-	if start >= total {
-		filteredUsers = []User{}
-	} else {
-		if end > total {
-			end = total
-		}
-		filteredUsers = filteredUsers[start:end]
+// Search users handler
+func searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, errBadRequest("query parameter 'q' is required"))
+		return
+	}
+	page, limit, apiErr := pagination(r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
 	}
 
-	result := SearchResult{
-		Users:      filteredUsers,
+	users := db.searchUsers(query)
+	pageUsers, totalPages := paginate(users, page, limit)
+	writeJSON(w, http.StatusOK, SearchResult{
+		Users:      pageUsers,
 		Page:       page,
 		Limit:      limit,
-		Total:      total,
+		Total:      len(users),
 		TotalPages: totalPages,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	})
 }
 
 // Create user handler
 func createUserHandler(w http.ResponseWriter, r *http.Request) {
 	var req CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if apiErr := decodeBody(r, &req); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	if apiErr := validateUser(req.Name, req.Email, req.Age); apiErr != nil {
+		writeError(w, apiErr)
 		return
 	}
 
-	if req.Name == "" || req.Email == "" {
-		http.Error(w, "Name and email are required", http.StatusBadRequest)
+	user, apiErr := db.createUser(req.Name, req.Email, req.Age, req.Profile)
+	if apiErr != nil {
+		writeError(w, apiErr)
 		return
 	}
+	w.Header().Set("ETag", user.ETag())
+	w.Header().Set("Location", fmt.Sprintf("/api/users/%d", user.ID))
+	writeJSON(w, http.StatusCreated, user)
+}
 
-	// Check if email already exists
-	for _, user := range users {
-		if user.Email == req.Email {
-			http.Error(w, "Email already exists", http.StatusConflict)
-			return
-		}
+// Replace user handler
+func replaceUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, apiErr := pathID(r, "id")
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	var req CreateUserRequest
+	if apiErr := decodeBody(r, &req); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	if apiErr := validateUser(req.Name, req.Email, req.Age); apiErr != nil {
+		writeError(w, apiErr)
+		return
 	}
 
-	// Create new user
-	newUser := User{
-		ID:       nextUserID,
-		Name:     req.Name,
-		Email:    req.Email,
-		Age:      req.Age,
-		CreateAt: time.Now().Format(time.RFC3339),
+	user, apiErr := db.updateUser(userID, r.Header.Get("If-Match"), func(u *User) *apiError {
+		u.Name, u.Email, u.Age, u.Profile = req.Name, req.Email, req.Age, req.Profile
+		return nil
+	})
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
 	}
-	nextUserID++
+	w.Header().Set("ETag", user.ETag())
+	writeJSON(w, http.StatusOK, user)
+}
 
-	users = append(users, newUser)
+// Patch user handler
+func patchUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, apiErr := pathID(r, "id")
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	var req PatchUserRequest
+	if apiErr := decodeBody(r, &req); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	user, apiErr := db.updateUser(userID, r.Header.Get("If-Match"), func(u *User) *apiError {
+		if req.Name != nil {
+			u.Name = *req.Name
+		}
+		if req.Email != nil {
+			u.Email = *req.Email
+		}
+		if req.Age != nil {
+			u.Age = *req.Age
+		}
+		if req.Profile != nil {
+			u.Profile = *req.Profile
+		}
+		return validateUser(u.Name, u.Email, u.Age)
+	})
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	w.Header().Set("ETag", user.ETag())
+	writeJSON(w, http.StatusOK, user)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(newUser)
+// Delete user handler
+func deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, apiErr := pathID(r, "id")
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	if apiErr := db.deleteUser(userID, r.Header.Get("If-Match")); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // Get user posts handler
 func getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userID, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	userID, apiErr := pathID(r, "id")
+	if apiErr != nil {
+		writeError(w, apiErr)
 		return
 	}
 
@@ -286,47 +447,209 @@ func getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
 	if status == "" {
 		status = "published"
 	}
+	if status != "all" && status != "published" && status != "draft" && status != "archived" {
+		writeError(w, errBadRequest("status must be one of published, draft, archived, all, got %q", status))
+		return
+	}
+	page, limit, apiErr := pagination(r)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
 
 	// Find user posts
-	var userPosts []Post
-	for _, post := range posts {
-		if post.UserID == userID && (status == "all" || post.Status == status) {
-			userPosts = append(userPosts, post)
-		}
+	userPosts, apiErr := db.userPosts(userID, status)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
 	}
+	pagePosts, totalPages := paginate(userPosts, page, limit)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"user_id":     userID,
+		"status":      status,
+		"posts":       pagePosts,
+		"count":       len(pagePosts),
+		"page":        page,
+		"limit":       limit,
+		"total":       len(userPosts),
+		"total_pages": totalPages,
+	})
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"user_id": userID,
-		"status":  status,
-		"posts":   userPosts,
-		"count":   len(userPosts),
+// Create post handler
+func createPostHandler(w http.ResponseWriter, r *http.Request) {
+	userID, apiErr := pathID(r, "id")
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	var req CreatePostRequest
+	if apiErr := decodeBody(r, &req); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	if req.Status == "" {
+		req.Status = "draft"
+	}
+	if apiErr := validatePost(req.Title, req.Content, req.Status); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	post, apiErr := db.createPost(userID, req.Title, req.Content, req.Status)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	w.Header().Set("ETag", post.ETag())
+	w.Header().Set("Location", fmt.Sprintf("/api/posts/%d", post.ID))
+	writeJSON(w, http.StatusCreated, post)
+}
+
+// Get post handler
+func getPostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, apiErr := pathID(r, "id")
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	post, ok := db.getPost(postID)
+	if !ok {
+		writeError(w, errNotFound("post %d not found", postID))
+		return
+	}
+	if notModified(w, r, post.ETag()) {
+		return
+	}
+	writeJSON(w, http.StatusOK, post)
+}
+
+// Replace post handler
+func replacePostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, apiErr := pathID(r, "id")
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	var req CreatePostRequest
+	if apiErr := decodeBody(r, &req); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	if req.Status == "" {
+		req.Status = "draft"
+	}
+	if apiErr := validatePost(req.Title, req.Content, req.Status); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	post, apiErr := db.updatePost(postID, r.Header.Get("If-Match"), func(p *Post) *apiError {
+		p.Title, p.Content, p.Status = req.Title, req.Content, req.Status
+		return nil
+	})
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	w.Header().Set("ETag", post.ETag())
+	writeJSON(w, http.StatusOK, post)
+}
+
+// Patch post handler
+func patchPostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, apiErr := pathID(r, "id")
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	var req PatchPostRequest
+	if apiErr := decodeBody(r, &req); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	post, apiErr := db.updatePost(postID, r.Header.Get("If-Match"), func(p *Post) *apiError {
+		if req.Title != nil {
+			p.Title = *req.Title
+		}
+		if req.Content != nil {
+			p.Content = *req.Content
+		}
+		if req.Status != nil {
+			p.Status = *req.Status
+		}
+		return validatePost(p.Title, p.Content, p.Status)
 	})
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	w.Header().Set("ETag", post.ETag())
+	writeJSON(w, http.StatusOK, post)
+}
+
+// Delete post handler
+func deletePostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, apiErr := pathID(r, "id")
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	if apiErr := db.deletePost(postID, r.Header.Get("If-Match")); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Error handler responds with the requested 4xx/5xx status in the common
+// error format, to test how clients map backend errors.
+func errorHandler(w http.ResponseWriter, r *http.Request) {
+	status, _ := strconv.Atoi(mux.Vars(r)["status"])
+	code, ok := statusCodes[status]
+	if !ok {
+		writeError(w, errBadRequest("status %d cannot be simulated", status))
+		return
+	}
+	writeError(w, &apiError{Status: status, Code: code, Message: fmt.Sprintf("simulated %d %s", status, http.StatusText(status))})
+}
+
+// Reset handler restores the seeded data
+func resetHandler(w http.ResponseWriter, r *http.Request) {
+	db.reset(*userCount)
+	users, posts := db.counts()
+	writeJSON(w, http.StatusOK, map[string]any{"users": users, "posts": posts})
 }
 
 // Health check handler
 func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	users, posts := db.counts()
+	writeJSON(w, http.StatusOK, map[string]any{
 		"status":    "healthy",
 		"timestamp": time.Now().Format(time.RFC3339),
 		"version":   "1.0.0",
-		"users":     len(users),
-		"posts":     len(posts),
+		"users":     users,
+		"posts":     posts,
 	})
 }
 
 // Root path handler
 func rootHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	writeJSON(w, http.StatusOK, map[string]any{
 		"message": "Mock Backend Server",
 		"version": "1.0.0",
 		"endpoints": map[string]string{
-			"users":       "/api/users/{id}",
+			"users":       "/api/users",
+			"user":        "/api/users/{id}",
 			"search":      "/api/users/search?q={query}",
 			"create_user": "/api/users",
 			"user_posts":  "/api/users/{id}/posts",
+			"post":        "/api/posts/{id}",
+			"docs":        "/api/docs",
+			"errors":      "/api/errors/{status}",
+			"reset":       "/api/reset",
 			"health":      "/api/health",
 		},
 	})
@@ -0,0 +1,356 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User represents a user structure
+type User struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Age      int    `json:"age,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+	CreateAt string `json:"created_at"`
+	UpdateAt string `json:"updated_at,omitempty"`
+
+	version int
+}
+
+// Post represents a post structure
+type Post struct {
+	ID       int    `json:"id"`
+	UserID   int    `json:"user_id"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Status   string `json:"status"`
+	CreateAt string `json:"created_at"`
+	UpdateAt string `json:"updated_at,omitempty"`
+
+	version int
+}
+
+// etag is the strong entity tag of a stored record, changing with every
+// update.
+func etag(kind string, id int, version int) string {
+	return fmt.Sprintf(`"%s-%d-v%d"`, kind, id, version)
+}
+
+func (u User) ETag() string { return etag("user", u.ID, u.version) }
+
+func (p Post) ETag() string { return etag("post", p.ID, p.version) }
+
+var postStatuses = []string{"published", "draft", "archived"}
+
+// store is the in-memory user and post database of the mock backend.
+type store struct {
+	mu sync.Mutex
+
+	users      map[int]*User
+	posts      map[int]*Post
+	nextUserID int
+	nextPostID int
+}
+
+// Fixed sample data; the tests and the tool descriptions rely on these.
+var sampleUsers = []User{
+	{ID: 1, Name: "Alice Johnson", Email: "alice@example.com", Age: 28, Profile: "Software Engineer at TechCorp", CreateAt: "2023-01-15T10:30:00Z"},
+	{ID: 2, Name: "Bob Smith", Email: "bob@example.com", Age: 32, Profile: "Product Manager at StartupXYZ", CreateAt: "2023-02-20T14:45:00Z"},
+	{ID: 3, Name: "Charlie Brown", Email: "charlie@example.com", Age: 25, Profile: "Designer at CreativeStudio", CreateAt: "2023-03-10T09:15:00Z"},
+	{ID: 4, Name: "Diana Prince", Email: "diana@example.com", Age: 30, Profile: "Data Scientist at DataCorp", CreateAt: "2023-04-05T16:20:00Z"},
+	{ID: 5, Name: "Eve Wilson", Email: "eve@example.com", Age: 27, Profile: "DevOps Engineer at CloudTech", CreateAt: "2023-05-12T11:10:00Z"},
+}
+
+var samplePosts = []Post{
+	{ID: 1, UserID: 1, Title: "Getting Started with Go", Content: "Go is a great language for backend development...", Status: "published", CreateAt: "2023-06-01T10:00:00Z"},
+	{ID: 2, UserID: 1, Title: "Microservices Architecture", Content: "Building scalable microservices...", Status: "published", CreateAt: "2023-06-15T14:30:00Z"},
+	{ID: 3, UserID: 2, Title: "Product Management 101", Content: "Essential skills for product managers...", Status: "published", CreateAt: "2023-06-20T09:45:00Z"},
+	{ID: 4, UserID: 3, Title: "UI/UX Design Trends", Content: "Latest trends in user interface design...", Status: "draft", CreateAt: "2023-06-25T16:15:00Z"},
+	{ID: 5, UserID: 4, Title: "Data Analysis with Python", Content: "Analyzing data using pandas and numpy...", Status: "published", CreateAt: "2023-07-01T12:00:00Z"},
+}
+
+var (
+	firstNames = []string{"Alice", "Bob", "Charlie", "Diana", "Eve", "Frank", "Grace", "Henry", "Ivy", "Jack", "Karen", "Leo", "Mia", "Noah", "Olivia", "Paul"}
+	lastNames  = []string{"Johnson", "Smith", "Brown", "Prince", "Wilson", "Garcia", "Miller", "Davis", "Lopez", "Clark", "Lewis", "Walker", "Young"}
+	jobs       = []string{"Software Engineer", "Product Manager", "Designer", "Data Scientist", "DevOps Engineer", "QA Engineer", "Technical Writer"}
+	companies  = []string{"TechCorp", "StartupXYZ", "CreativeStudio", "DataCorp", "CloudTech"}
+	topics     = []string{"Go", "Kubernetes", "Service Mesh", "API Gateways", "Observability", "Databases", "Testing", "MCP"}
+)
+
+// newStore returns a store with the sample data followed by generated users,
+// each with up to three posts, so pagination can be exercised. The data is
+// the same on every start.
+func newStore(userCount int) *store {
+	s := &store{users: map[int]*User{}, posts: map[int]*Post{}}
+	for i := range sampleUsers {
+		u := sampleUsers[i]
+		s.users[u.ID] = &u
+	}
+	for i := range samplePosts {
+		p := samplePosts[i]
+		s.posts[p.ID] = &p
+	}
+	s.nextUserID = len(sampleUsers) + 1
+	s.nextPostID = len(samplePosts) + 1
+
+	base := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	for id := s.nextUserID; id <= userCount; id++ {
+		first, last := firstNames[id%len(firstNames)], lastNames[(id/len(firstNames))%len(lastNames)]
+		s.users[id] = &User{
+			ID:       id,
+			Name:     first + " " + last,
+			Email:    fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(last), id),
+			Age:      18 + id%50,
+			Profile:  fmt.Sprintf("%s at %s", jobs[id%len(jobs)], companies[id%len(companies)]),
+			CreateAt: base.Add(time.Duration(id) * time.Hour).Format(time.RFC3339),
+		}
+		for n := 0; n < id%4; n++ {
+			topic := topics[(id+n)%len(topics)]
+			s.posts[s.nextPostID] = &Post{
+				ID:       s.nextPostID,
+				UserID:   id,
+				Title:    fmt.Sprintf("Notes on %s #%d", topic, n+1),
+				Content:  fmt.Sprintf("%s shares what they learned about %s...", first, topic),
+				Status:   postStatuses[(id+n)%len(postStatuses)],
+				CreateAt: base.Add(time.Duration(id)*time.Hour + time.Duration(n+1)*time.Minute).Format(time.RFC3339),
+			}
+			s.nextPostID++
+		}
+		s.nextUserID = id + 1
+	}
+	return s
+}
+
+// reset restores the seeded data.
+func (s *store) reset(userCount int) {
+	seeded := newStore(userCount)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users, s.posts = seeded.users, seeded.posts
+	s.nextUserID, s.nextPostID = seeded.nextUserID, seeded.nextPostID
+}
+
+func (s *store) counts() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.users), len(s.posts)
+}
+
+func (s *store) getUser(id int) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return User{}, false
+	}
+	return *u, true
+}
+
+// searchUsers returns the users whose name or email contains query, ordered
+// by ID. An empty query matches everyone.
+func (s *store) searchUsers(query string) []User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query = strings.ToLower(query)
+	var out []User
+	for _, u := range s.users {
+		if strings.Contains(strings.ToLower(u.Name), query) || strings.Contains(strings.ToLower(u.Email), query) {
+			out = append(out, *u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (s *store) emailTaken(email string, except int) bool {
+	for _, u := range s.users {
+		if u.ID != except && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *store) createUser(name string, email string, age int, profile string) (User, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.emailTaken(email, 0) {
+		return User{}, errConflict("email %s already exists", email)
+	}
+	u := &User{
+		ID:       s.nextUserID,
+		Name:     name,
+		Email:    email,
+		Age:      age,
+		Profile:  profile,
+		CreateAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	s.nextUserID++
+	s.users[u.ID] = u
+	return *u, nil
+}
+
+// updateUser applies change to a copy of user id and stores it if ifMatch,
+// when set, matches the current ETag.
+func (s *store) updateUser(id int, ifMatch string, change func(*User) *apiError) (User, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.users[id]
+	if !ok {
+		return User{}, errNotFound("user %d not found", id)
+	}
+	if err := checkIfMatch(ifMatch, current.ETag()); err != nil {
+		return User{}, err
+	}
+	updated := *current
+	if err := change(&updated); err != nil {
+		return User{}, err
+	}
+	if s.emailTaken(updated.Email, id) {
+		return User{}, errConflict("email %s already exists", updated.Email)
+	}
+	updated.version++
+	updated.UpdateAt = time.Now().UTC().Format(time.RFC3339)
+	s.users[id] = &updated
+	return updated, nil
+}
+
+// deleteUser removes a user together with their posts.
+func (s *store) deleteUser(id int, ifMatch string) *apiError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.users[id]
+	if !ok {
+		return errNotFound("user %d not found", id)
+	}
+	if err := checkIfMatch(ifMatch, current.ETag()); err != nil {
+		return err
+	}
+	delete(s.users, id)
+	for postID, p := range s.posts {
+		if p.UserID == id {
+			delete(s.posts, postID)
+		}
+	}
+	return nil
+}
+
+// userPosts returns the posts of a user with the given status ("all" for
+// every status), newest first.
+func (s *store) userPosts(userID int, status string) ([]Post, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok {
+		return nil, errNotFound("user %d not found", userID)
+	}
+	out := []Post{}
+	for _, p := range s.posts {
+		if p.UserID == userID && (status == "all" || p.Status == status) {
+			out = append(out, *p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (s *store) getPost(id int) (Post, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.posts[id]
+	if !ok {
+		return Post{}, false
+	}
+	return *p, true
+}
+
+func (s *store) createPost(userID int, title string, content string, status string) (Post, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok {
+		return Post{}, errNotFound("user %d not found", userID)
+	}
+	p := &Post{
+		ID:       s.nextPostID,
+		UserID:   userID,
+		Title:    title,
+		Content:  content,
+		Status:   status,
+		CreateAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	s.nextPostID++
+	s.posts[p.ID] = p
+	return *p, nil
+}
+
+func (s *store) updatePost(id int, ifMatch string, change func(*Post) *apiError) (Post, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.posts[id]
+	if !ok {
+		return Post{}, errNotFound("post %d not found", id)
+	}
+	if err := checkIfMatch(ifMatch, current.ETag()); err != nil {
+		return Post{}, err
+	}
+	updated := *current
+	if err := change(&updated); err != nil {
+		return Post{}, err
+	}
+	updated.version++
+	updated.UpdateAt = time.Now().UTC().Format(time.RFC3339)
+	s.posts[id] = &updated
+	return updated, nil
+}
+
+func (s *store) deletePost(id int, ifMatch string) *apiError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.posts[id]
+	if !ok {
+		return errNotFound("post %d not found", id)
+	}
+	if err := checkIfMatch(ifMatch, current.ETag()); err != nil {
+		return err
+	}
+	delete(s.posts, id)
+	return nil
+}
+
+// checkIfMatch implements If-Match for a single current ETag. An empty
+// header makes the request unconditional.
+func checkIfMatch(header string, current string) *apiError {
+	if header == "" || header == "*" {
+		return nil
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimSpace(tag) == current {
+			return nil
+		}
+	}
+	return &apiError{
+		Status:  412,
+		Code:    "precondition_failed",
+		Message: fmt.Sprintf("If-Match %s does not match the current ETag %s", header, current),
+	}
+}
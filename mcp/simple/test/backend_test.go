@@ -0,0 +1,237 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Tests for the mock backend itself, run directly against it without Pixiu.
+// They pin down the pagination, ETag and error behavior that the MCP tool
+// tests rely on.
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// backendError is the error body every failed backend request returns.
+type backendError struct {
+	Error struct {
+		Status  int    `json:"status"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Details []struct {
+			Field string `json:"field"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// backendRequest sends a request to the backend and decodes a JSON response
+// into out when out is not nil.
+func backendRequest(t *testing.T, method string, path string, headers map[string]string, body any, out any) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, backendURL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, path, data, err)
+		}
+	}
+	return resp
+}
+
+// createTestUser creates a user with a unique email and returns its ID.
+func createTestUser(t *testing.T) int {
+	t.Helper()
+	var user struct {
+		ID int `json:"id"`
+	}
+	email := fmt.Sprintf("backend%d@example.com", time.Now().UnixNano())
+	resp := backendRequest(t, http.MethodPost, "/api/users", nil, map[string]any{"name": "Backend Test", "email": email}, &user)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("ETag") == "" {
+		t.Fatalf("create user returned %d with ETag %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	return user.ID
+}
+
+// SearchPage is the paginated user list of /api/users and /api/users/search.
+type SearchPage struct {
+	Users      []map[string]any `json:"users"`
+	Page       int              `json:"page"`
+	Total      int              `json:"total"`
+	TotalPages int              `json:"total_pages"`
+}
+
+func TestBackendPagination(t *testing.T) {
+	if !checkServiceAvailable(backendURL) {
+		t.Skip("Backend service is not available")
+	}
+
+	seen := map[float64]bool{}
+	var first SearchPage
+	backendRequest(t, http.MethodGet, "/api/users?limit=100", nil, nil, &first)
+	if first.Total < 1000 || first.TotalPages != (first.Total+99)/100 || len(first.Users) != 100 {
+		t.Fatalf("expected thousands of seeded users in pages of 100, got total %d, %d pages, %d users", first.Total, first.TotalPages, len(first.Users))
+	}
+	for page := 1; page <= 3; page++ {
+		var result SearchPage
+		backendRequest(t, http.MethodGet, fmt.Sprintf("/api/users/search?q=example.com&page=%d&limit=50", page), nil, nil, &result)
+		for _, u := range result.Users {
+			id := u["id"].(float64)
+			if seen[id] {
+				t.Fatalf("user %v returned on two pages", id)
+			}
+			seen[id] = true
+		}
+	}
+	if len(seen) != 150 {
+		t.Fatalf("expected 150 distinct users over 3 pages, got %d", len(seen))
+	}
+
+	var past SearchPage
+	backendRequest(t, http.MethodGet, fmt.Sprintf("/api/users?page=%d&limit=100", first.TotalPages+1), nil, nil, &past)
+	if len(past.Users) != 0 {
+		t.Fatalf("expected an empty page past the end, got %d users", len(past.Users))
+	}
+
+	for _, path := range []string{"/api/users?limit=101", "/api/users?page=0", "/api/users/search?q=a&page=x", "/api/users/search"} {
+		var e backendError
+		if resp := backendRequest(t, http.MethodGet, path, nil, nil, &e); resp.StatusCode != http.StatusBadRequest || e.Error.Code != "bad_request" {
+			t.Fatalf("%s: expected 400 bad_request, got %d %+v", path, resp.StatusCode, e)
+		}
+	}
+}
+
+func TestBackendETags(t *testing.T) {
+	if !checkServiceAvailable(backendURL) {
+		t.Skip("Backend service is not available")
+	}
+	id := createTestUser(t)
+	path := fmt.Sprintf("/api/users/%d", id)
+
+	resp := backendRequest(t, http.MethodGet, path, nil, nil, nil)
+	etag := resp.Header.Get("ETag")
+	if resp := backendRequest(t, http.MethodGet, path, map[string]string{"If-None-Match": etag}, nil, nil); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304 for a current If-None-Match, got %d", resp.StatusCode)
+	}
+
+	resp = backendRequest(t, http.MethodPatch, path, map[string]string{"If-Match": etag}, map[string]any{"age": 41}, nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+		t.Fatalf("conditional update returned %d with ETag %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	// The old ETag is stale now.
+	var e backendError
+	resp = backendRequest(t, http.MethodPatch, path, map[string]string{"If-Match": etag}, map[string]any{"age": 42}, &e)
+	if resp.StatusCode != http.StatusPreconditionFailed || e.Error.Code != "precondition_failed" {
+		t.Fatalf("expected 412 for a stale If-Match, got %d %+v", resp.StatusCode, e)
+	}
+	resp = backendRequest(t, http.MethodDelete, path, map[string]string{"If-Match": etag}, nil, nil)
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected a stale delete to be refused, got %d", resp.StatusCode)
+	}
+	if resp := backendRequest(t, http.MethodDelete, path, nil, nil, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete returned %d", resp.StatusCode)
+	}
+	if resp := backendRequest(t, http.MethodGet, path, nil, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("deleted user still returned %d", resp.StatusCode)
+	}
+}
+
+func TestBackendPosts(t *testing.T) {
+	if !checkServiceAvailable(backendURL) {
+		t.Skip("Backend service is not available")
+	}
+	id := createTestUser(t)
+
+	var post struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+	}
+	resp := backendRequest(t, http.MethodPost, fmt.Sprintf("/api/users/%d/posts", id), nil, map[string]any{"title": "Hello", "content": "World"}, &post)
+	if resp.StatusCode != http.StatusCreated || post.Status != "draft" || resp.Header.Get("Location") != fmt.Sprintf("/api/posts/%d", post.ID) {
+		t.Fatalf("create post returned %d %+v, Location %q", resp.StatusCode, post, resp.Header.Get("Location"))
+	}
+	resp = backendRequest(t, http.MethodPut, fmt.Sprintf("/api/posts/%d", post.ID), nil, map[string]any{"title": "Hello", "content": "World", "status": "published"}, &post)
+	if resp.StatusCode != http.StatusOK || post.Status != "published" {
+		t.Fatalf("replace post returned %d %+v", resp.StatusCode, post)
+	}
+
+	// Deleting the user deletes their posts.
+	backendRequest(t, http.MethodDelete, fmt.Sprintf("/api/users/%d", id), nil, nil, nil)
+	if resp := backendRequest(t, http.MethodGet, fmt.Sprintf("/api/posts/%d", post.ID), nil, nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("post of a deleted user returned %d", resp.StatusCode)
+	}
+}
+
+func TestBackendErrorFormat(t *testing.T) {
+	if !checkServiceAvailable(backendURL) {
+		t.Skip("Backend service is not available")
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		body   any
+		status int
+		code   string
+	}{
+		{http.MethodGet, "/api/users/999999", nil, http.StatusNotFound, "not_found"},
+		{http.MethodGet, "/api/users/1/posts?status=deleted", nil, http.StatusBadRequest, "bad_request"},
+		{http.MethodPost, "/api/users", map[string]any{"name": "Alice", "email": "alice@example.com"}, http.StatusConflict, "conflict"},
+		{http.MethodPost, "/api/users", map[string]any{"email": "not-an-email", "age": 200}, http.StatusUnprocessableEntity, "validation_failed"},
+		{http.MethodPost, "/api/health", nil, http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.MethodGet, "/api/errors/429", nil, http.StatusTooManyRequests, "rate_limited"},
+		{http.MethodGet, "/api/errors/500", nil, http.StatusInternalServerError, "internal"},
+		{http.MethodGet, "/api/errors/503", nil, http.StatusServiceUnavailable, "unavailable"},
+	}
+	for _, c := range cases {
+		var e backendError
+		resp := backendRequest(t, c.method, c.path, nil, c.body, &e)
+		if resp.StatusCode != c.status || e.Error.Status != c.status || e.Error.Code != c.code || e.Error.Message == "" {
+			t.Fatalf("%s %s: expected %d %s, got %d %+v", c.method, c.path, c.status, c.code, resp.StatusCode, e)
+		}
+		if c.code == "validation_failed" && len(e.Error.Details) != 3 {
+			t.Fatalf("expected name, email and age to be reported, got %+v", e.Error.Details)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	sessionHeader         = "Mcp-Session-Id"
+	protocolVersionHeader = "Mcp-Protocol-Version"
+	clientProtocolVersion = "2025-06-18"
+)
+
+// errStreamNotSupported is returned by listen when the server offers no
+// server-initiated SSE stream (HTTP 405 on GET).
+var errStreamNotSupported = errors.New("server does not offer an SSE stream")
+
+// rpcMessage is any JSON-RPC 2.0 message: request, notification or response.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string { return fmt.Sprintf("JSON-RPC error %d: %s", e.Code, e.Message) }
+
+// httpStatusError is a non-2xx HTTP answer to an MCP request.
+type httpStatusError struct {
+	Status int
+	Body   string
+}
+
+func (e *httpStatusError) Error() string { return fmt.Sprintf("HTTP %d: %s", e.Status, e.Body) }
+
+// mcpClient is a minimal Streamable HTTP MCP client. It keeps the session ID
+// the server assigns on initialize, accepts responses as plain JSON or as an
+// SSE stream, and records the notifications that arrive on either.
+type mcpClient struct {
+	url  string
+	http *http.Client
+
+	mu            sync.Mutex
+	nextID        int
+	sessionID     string
+	notifications []rpcMessage
+}
+
+func newMCPClient(url string) *mcpClient {
+	return &mcpClient{url: url, http: &http.Client{}}
+}
+
+// initialize runs the initialize handshake and returns the server result.
+func (c *mcpClient) initialize(ctx context.Context) (map[string]any, error) {
+	params := map[string]any{
+		"protocolVersion": clientProtocolVersion,
+		"capabilities":    map[string]any{"roots": map[string]any{"listChanged": true}},
+		"clientInfo":      map[string]any{"name": "pixiu-samples-test-client", "version": "1.0.0"},
+	}
+	var result map[string]any
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return nil, err
+	}
+	if err := c.notify(ctx, "notifications/initialized", nil); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *mcpClient) session() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
+}
+
+// received returns the notifications received so far.
+func (c *mcpClient) received() []rpcMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]rpcMessage(nil), c.notifications...)
+}
+
+// call sends a request and decodes its result into result.
+func (c *mcpClient) call(ctx context.Context, method string, params any, result any) error {
+	c.mu.Lock()
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	c.mu.Unlock()
+
+	msg, err := c.post(ctx, rpcMessage{JSONRPC: "2.0", ID: id, Method: method, Params: mustJSON(params)})
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		return fmt.Errorf("%s: no response", method)
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(msg.Result, result)
+}
+
+// notify sends a notification, which the server acknowledges with 202.
+func (c *mcpClient) notify(ctx context.Context, method string, params any) error {
+	_, err := c.post(ctx, rpcMessage{JSONRPC: "2.0", Method: method, Params: mustJSON(params)})
+	return err
+}
+
+// post sends msg and, for a request, waits for the response with its ID.
+func (c *mcpClient) post(ctx context.Context, msg rpcMessage) (*rpcMessage, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	c.setSessionHeaders(req, msg.Method != "initialize")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(resp.Body)
+		return nil, &httpStatusError{Status: resp.StatusCode, Body: string(data)}
+	}
+	if msg.Method == "initialize" {
+		c.mu.Lock()
+		c.sessionID = resp.Header.Get(sessionHeader)
+		c.mu.Unlock()
+	}
+	if msg.ID == nil || resp.StatusCode == http.StatusAccepted {
+		return nil, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var reply rpcMessage
+		if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+			return nil, fmt.Errorf("%s: decode response: %w", msg.Method, err)
+		}
+		return &reply, nil
+	case "text/event-stream":
+		var reply *rpcMessage
+		err := readSSE(resp.Body, func(m rpcMessage) bool {
+			if m.Method == "" && bytes.Equal(m.ID, msg.ID) {
+				reply = &m
+				return true
+			}
+			c.record(m)
+			return false
+		})
+		if err != nil {
+			return nil, err
+		}
+		if reply == nil {
+			return nil, fmt.Errorf("%s: SSE stream ended without a response", msg.Method)
+		}
+		return reply, nil
+	default:
+		return nil, fmt.Errorf("%s: unexpected content type %q", msg.Method, resp.Header.Get("Content-Type"))
+	}
+}
+
+func (c *mcpClient) setSessionHeaders(req *http.Request, withVersion bool) {
+	if session := c.session(); session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+	if withVersion {
+		req.Header.Set(protocolVersionHeader, clientProtocolVersion)
+	}
+}
+
+func (c *mcpClient) record(m rpcMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notifications = append(c.notifications, m)
+}
+
+// listen opens the server-initiated SSE stream of the session. Messages are
+// recorded and sent to the returned channel, which is closed when the stream
+// ends or ctx is done.
+func (c *mcpClient) listen(ctx context.Context) (<-chan rpcMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	c.setSessionHeaders(req, true)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		return nil, errStreamNotSupported
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); resp.StatusCode != http.StatusOK || mediaType != "text/event-stream" {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &httpStatusError{Status: resp.StatusCode, Body: string(data)}
+	}
+
+	messages := make(chan rpcMessage, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(messages)
+		_ = readSSE(resp.Body, func(m rpcMessage) bool {
+			c.record(m)
+			select {
+			case messages <- m:
+			case <-ctx.Done():
+				return true
+			}
+			return false
+		})
+	}()
+	return messages, nil
+}
+
+// close terminates the session with DELETE.
+func (c *mcpClient) close(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.url, nil)
+	if err != nil {
+		return err
+	}
+	c.setSessionHeaders(req, true)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(resp.Body)
+		return &httpStatusError{Status: resp.StatusCode, Body: string(data)}
+	}
+	return nil
+}
+
+// readSSE parses an SSE stream and hands every JSON-RPC message in a data
+// event to handle until handle returns true or the stream ends.
+func readSSE(r io.Reader, handle func(rpcMessage) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var data []string
+	dispatch := func() (bool, error) {
+		if len(data) == 0 {
+			return false, nil
+		}
+		payload := strings.Join(data, "\n")
+		data = data[:0]
+		var m rpcMessage
+		if err := json.Unmarshal([]byte(payload), &m); err != nil {
+			return false, fmt.Errorf("SSE data is not a JSON-RPC message: %q", payload)
+		}
+		return handle(m), nil
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if done, err := dispatch(); done || err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// event, id, retry and comment lines carry nothing the tests need.
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	_, err := dispatch()
+	return err
+}
+
+func mustJSON(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// fakeMCPServer is an in-process Streamable HTTP server with one session. It
+// answers tools/list over SSE with a log notification first, and pushes a
+// tools list_changed notification on the GET stream.
+func fakeMCPServer() *httptest.Server {
+	var mu sync.Mutex
+	session := ""
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		current := session
+		mu.Unlock()
+		if r.Header.Get(sessionHeader) != current {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, ": keep-alive\n\nevent: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/tools/list_changed\"}\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case http.MethodDelete:
+			mu.Lock()
+			session = "terminated"
+			mu.Unlock()
+		case http.MethodPost:
+			var msg rpcMessage
+			if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			switch msg.Method {
+			case "initialize":
+				mu.Lock()
+				session = "session-1"
+				mu.Unlock()
+				w.Header().Set(sessionHeader, "session-1")
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"%s","capabilities":{"tools":{"listChanged":true}}}}`, msg.ID, clientProtocolVersion)
+			case "notifications/initialized":
+				w.WriteHeader(http.StatusAccepted)
+			case "tools/list":
+				if r.Header.Get(protocolVersionHeader) != clientProtocolVersion {
+					http.Error(w, "missing protocol version", http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\",\n")
+				fmt.Fprint(w, "data: \"params\":{\"level\":\"info\",\"data\":\"listing\"}}\n\n")
+				fmt.Fprintf(w, "id: 2\ndata: {\"jsonrpc\":\"2.0\",\"id\":%s,\"result\":{\"tools\":[{\"name\":\"echo\"}]}}\n\n", msg.ID)
+			default:
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"method not found"}}`, msg.ID)
+			}
+		}
+	}))
+}
+
+// TestMCPClientStreamableHTTP checks the test client itself against the fake
+// server, so the Pixiu tests fail only on Pixiu behavior.
+func TestMCPClientStreamableHTTP(t *testing.T) {
+	server := fakeMCPServer()
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := newMCPClient(server.URL)
+	if _, err := client.initialize(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if client.session() != "session-1" {
+		t.Fatalf("session ID not kept: %q", client.session())
+	}
+
+	var tools struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	if err := client.call(ctx, "tools/list", nil, &tools); err != nil {
+		t.Fatal(err)
+	}
+	if len(tools.Tools) != 1 || tools.Tools[0].Name != "echo" {
+		t.Fatalf("unexpected SSE response %+v", tools)
+	}
+	if got := client.received(); len(got) != 1 || got[0].Method != "notifications/message" {
+		t.Fatalf("expected the multi-line log notification before the response, got %+v", got)
+	}
+
+	var rpcErr *rpcError
+	if err := client.call(ctx, "unknown/method", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Fatalf("expected a JSON-RPC error, got %v", err)
+	}
+
+	listenCtx, stop := context.WithCancel(ctx)
+	messages, err := client.listen(listenCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := <-messages; m.Method != "notifications/tools/list_changed" {
+		t.Fatalf("unexpected stream message %+v", m)
+	}
+	stop()
+
+	if err := client.close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var statusErr *httpStatusError
+	if err := client.call(ctx, "tools/list", nil, nil); !errors.As(err, &statusErr) || statusErr.Status != http.StatusNotFound {
+		t.Fatalf("expected 404 after the session was closed, got %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Tests for the sessions, resources and prompts of the MCP server,
+// using the Streamable HTTP client of mcp_client_test.go.
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSession initializes a session with Pixiu and closes it at the end of
+// the test.
+func newSession(t *testing.T) (*mcpClient, map[string]any, context.Context) {
+	t.Helper()
+	if !checkServiceAvailable(pixiuURL) || !checkServiceAvailable(backendURL) {
+		t.Skip("Services are not available")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	client := newMCPClient(pixiuURL + mcpEndpoint)
+	result, err := client.initialize(ctx)
+	if err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	t.Cleanup(func() { _ = client.close(context.Background()) })
+	return client, result, ctx
+}
+
+// resourceContents is the result of resources/read.
+type resourceContents struct {
+	Contents []struct {
+		URI      string `json:"uri"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	} `json:"contents"`
+}
+
+// readResource reads uri and returns its single text content.
+func readResource(t *testing.T, client *mcpClient, ctx context.Context, uri string) (string, string) {
+	t.Helper()
+	var result resourceContents
+	if err := client.call(ctx, "resources/read", map[string]any{"uri": uri}, &result); err != nil {
+		t.Fatalf("resources/read %s: %v", uri, err)
+	}
+	if len(result.Contents) != 1 || result.Contents[0].URI != uri {
+		t.Fatalf("resources/read %s: unexpected contents %+v", uri, result.Contents)
+	}
+	return result.Contents[0].Text, result.Contents[0].MimeType
+}
+
+// TestMCPCapabilities tests that initialize announces everything conf.yaml
+// publishes
+func TestMCPCapabilities(t *testing.T) {
+	_, result, _ := newSession(t)
+
+	capabilities, _ := result["capabilities"].(map[string]any)
+	for _, capability := range []string{"tools", "resources", "prompts"} {
+		if _, ok := capabilities[capability]; !ok {
+			t.Fatalf("initialize does not announce %s: %v", capability, capabilities)
+		}
+	}
+}
+
+// postSession sends tools/list with the given Mcp-Session-Id, or none when
+// it is empty, and returns the session ID of the response.
+func postSession(t *testing.T, ctx context.Context, session string) string {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pixiuURL+mcpEndpoint,
+		strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set(protocolVersionHeader, clientProtocolVersion)
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("tools/list with session %q returned %d", session, resp.StatusCode)
+	}
+	return resp.Header.Get(sessionHeader)
+}
+
+// TestMCPSession tests the Mcp-Session-Id handling of Pixiu. A session ID
+// assigned by initialize must be accepted and kept for the whole session and
+// differ between sessions; a server that assigns none must keep serving
+// requests without one.
+func TestMCPSession(t *testing.T) {
+	client, _, ctx := newSession(t)
+	other, _, _ := newSession(t)
+	session := client.session()
+
+	for i := 0; i < 2; i++ {
+		if got := postSession(t, ctx, session); got != "" && got != session {
+			t.Fatalf("request %d of session %q was given session %q", i+1, session, got)
+		}
+	}
+	if err := client.call(ctx, "tools/list", nil, nil); err != nil {
+		t.Fatalf("tools/list in session %q: %v", session, err)
+	}
+
+	if session == "" {
+		if other.session() != "" {
+			t.Fatalf("first initialize assigned no session ID, second assigned %q", other.session())
+		}
+		return
+	}
+	if other.session() == "" || other.session() == session {
+		t.Fatalf("expected two sessions to get distinct IDs, got %q and %q", session, other.session())
+	}
+}
+
+// TestResources tests resources/list and resources/read
+func TestResources(t *testing.T) {
+	client, _, ctx := newSession(t)
+
+	var list struct {
+		Resources []struct {
+			URI      string `json:"uri"`
+			Name     string `json:"name"`
+			MimeType string `json:"mimeType"`
+		} `json:"resources"`
+	}
+	if err := client.call(ctx, "resources/list", nil, &list); err != nil {
+		t.Fatalf("resources/list: %v", err)
+	}
+	found := map[string]string{}
+	for _, r := range list.Resources {
+		found[r.URI] = r.MimeType
+	}
+	if found["docs://mock-server/api"] != "text/markdown" || found["docs://mock-server/errors"] != "application/json" {
+		t.Fatalf("expected the api_docs and error_codes resources, got %+v", list.Resources)
+	}
+
+	text, mimeType := readResource(t, client, ctx, "docs://mock-server/api")
+	if mimeType != "text/markdown" || !strings.HasPrefix(text, "# Mock Backend API") {
+		t.Fatalf("unexpected API docs (%s): %.80q", mimeType, text)
+	}
+	if text, _ := readResource(t, client, ctx, "docs://mock-server/errors"); !strings.Contains(text, `"precondition_failed"`) {
+		t.Fatalf("error codes lack precondition_failed: %s", text)
+	}
+
+	var rpcErr *rpcError
+	if err := client.call(ctx, "resources/read", map[string]any{"uri": "docs://mock-server/missing"}, nil); !errors.As(err, &rpcErr) {
+		t.Fatalf("expected a JSON-RPC error for an unknown resource, got %v", err)
+	}
+}
+
+// TestResourceTemplates tests resources/templates/list and reading a
+// templated URI
+func TestResourceTemplates(t *testing.T) {
+	client, _, ctx := newSession(t)
+
+	var list struct {
+		ResourceTemplates []struct {
+			URITemplate string `json:"uriTemplate"`
+			Name        string `json:"name"`
+		} `json:"resourceTemplates"`
+	}
+	if err := client.call(ctx, "resources/templates/list", nil, &list); err != nil {
+		t.Fatalf("resources/templates/list: %v", err)
+	}
+	templates := map[string]bool{}
+	for _, tmpl := range list.ResourceTemplates {
+		templates[tmpl.URITemplate] = true
+	}
+	if !templates["users://{id}"] || !templates["posts://{id}"] {
+		t.Fatalf("expected the user and post templates, got %+v", list.ResourceTemplates)
+	}
+
+	if text, _ := readResource(t, client, ctx, "users://1"); !strings.Contains(text, "Alice Johnson") {
+		t.Fatalf("users://1 is not Alice: %s", text)
+	}
+	if text, _ := readResource(t, client, ctx, "posts://3"); !strings.Contains(text, "Product Management 101") {
+		t.Fatalf("posts://3 is not the sample post: %s", text)
+	}
+}
+
+// TestPrompts tests prompts/list and prompts/get with arguments
+func TestPrompts(t *testing.T) {
+	client, _, ctx := newSession(t)
+
+	var list struct {
+		Prompts []struct {
+			Name      string `json:"name"`
+			Arguments []struct {
+				Name     string `json:"name"`
+				Required bool   `json:"required"`
+			} `json:"arguments"`
+		} `json:"prompts"`
+	}
+	if err := client.call(ctx, "prompts/list", nil, &list); err != nil {
+		t.Fatalf("prompts/list: %v", err)
+	}
+	required := map[string]bool{}
+	for _, p := range list.Prompts {
+		for _, a := range p.Arguments {
+			required[p.Name+"."+a.Name] = a.Required
+		}
+	}
+	if !required["summarize_user.user_id"] || required["summarize_user.status"] || !required["explain_error.error"] {
+		t.Fatalf("unexpected prompt arguments %+v", list.Prompts)
+	}
+
+	var prompt struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	args := map[string]any{"name": "summarize_user", "arguments": map[string]string{"user_id": "42", "status": "all"}}
+	if err := client.call(ctx, "prompts/get", args, &prompt); err != nil {
+		t.Fatalf("prompts/get: %v", err)
+	}
+	if len(prompt.Messages) != 1 || prompt.Messages[0].Role != "user" {
+		t.Fatalf("unexpected prompt %+v", prompt)
+	}
+	if text := prompt.Messages[0].Content.Text; !strings.Contains(text, "user 42 (status all)") || strings.Contains(text, "{{") {
+		t.Fatalf("arguments not filled in: %q", text)
+	}
+
+	var rpcErr *rpcError
+	if err := client.call(ctx, "prompts/get", map[string]any{"name": "summarize_user"}, nil); !errors.As(err, &rpcErr) {
+		t.Fatalf("expected a JSON-RPC error without the required user_id, got %v", err)
+	}
+}
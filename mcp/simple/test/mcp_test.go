@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -63,6 +64,16 @@ type ToolCallParams struct {
 // sendJSONRPCRequest posts a JSON-RPC request to /mcp and returns the decoded
 // response. It fails the test on transport or protocol errors.
 func sendJSONRPCRequest(t *testing.T, method string, params any) *JSONRPCResponse {
+	jsonResp := postJSONRPCRequest(t, method, params)
+	if jsonResp.Error != nil {
+		t.Fatalf("JSON-RPC error: %v", jsonResp.Error)
+	}
+	return jsonResp
+}
+
+// postJSONRPCRequest is sendJSONRPCRequest without failing on a JSON-RPC
+// error response.
+func postJSONRPCRequest(t *testing.T, method string, params any) *JSONRPCResponse {
 	req := JSONRPCRequest{
 		JSONRPC: "2.0",
 		ID:      1,
@@ -95,13 +106,32 @@ func sendJSONRPCRequest(t *testing.T, method string, params any) *JSONRPCRespons
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	if jsonResp.Error != nil {
-		t.Fatalf("JSON-RPC error: %v", jsonResp.Error)
-	}
-
 	return &jsonResp
 }
 
+// callTool calls a tool and returns the text of its result and whether the
+// call failed, either as a tool error (isError) or as a JSON-RPC error.
+func callTool(t *testing.T, name string, args map[string]any) (string, bool) {
+	t.Helper()
+	resp := postJSONRPCRequest(t, "tools/call", ToolCallParams{Name: name, Arguments: args})
+	if resp.Error != nil {
+		data, _ := json.Marshal(resp.Error)
+		return string(data), true
+	}
+	result, ok := resp.Result.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected result to be an object")
+	}
+	text := ""
+	if content, ok := result["content"].([]any); ok && len(content) > 0 {
+		if item, ok := content[0].(map[string]any); ok {
+			text, _ = item["text"].(string)
+		}
+	}
+	isError, _ := result["isError"].(bool)
+	return text, isError
+}
+
 // checkServiceAvailable performs a quick HTTP GET and returns true if 200 OK.
 func checkServiceAvailable(url string) bool {
 	client := &http.Client{Timeout: 5 * time.Second}
@@ -437,3 +467,91 @@ func TestHealthCheck(t *testing.T) {
 
 	t.Logf("Health check passed: %s", healthData["status"])
 }
+
+// TestToolPagination tests that query arguments page through the seeded users
+func TestToolPagination(t *testing.T) {
+	if !checkServiceAvailable(pixiuURL) || !checkServiceAvailable(backendURL) {
+		t.Skip("Services are not available")
+	}
+
+	text, isError := callTool(t, "list_users", map[string]any{"page": 3, "limit": 25})
+	if isError {
+		t.Fatalf("list_users failed: %s", text)
+	}
+	var page SearchPage
+	if err := json.Unmarshal([]byte(text), &page); err != nil {
+		t.Fatalf("Failed to parse user page: %v", err)
+	}
+	if page.Page != 3 || len(page.Users) != 25 || page.Users[0]["id"].(float64) != 51 {
+		t.Fatalf("expected users 51-75 on page 3, got page %d with %d users", page.Page, len(page.Users))
+	}
+	if page.Total < 1000 {
+		t.Fatalf("expected thousands of seeded users, got %d", page.Total)
+	}
+}
+
+// TestToolArgumentBinding tests path, header and body arguments of one call
+func TestToolArgumentBinding(t *testing.T) {
+	if !checkServiceAvailable(pixiuURL) || !checkServiceAvailable(backendURL) {
+		t.Skip("Services are not available")
+	}
+
+	id := createTestUser(t)
+	etag := backendRequest(t, http.MethodGet, fmt.Sprintf("/api/users/%d", id), nil, nil, nil).Header.Get("ETag")
+
+	text, isError := callTool(t, "update_user", map[string]any{"id": id, "If-Match": etag, "age": 33})
+	if isError {
+		t.Fatalf("update_user failed: %s", text)
+	}
+	var user map[string]any
+	if err := json.Unmarshal([]byte(text), &user); err != nil {
+		t.Fatalf("Failed to parse user data: %v", err)
+	}
+	if user["id"].(float64) != float64(id) || user["age"].(float64) != 33 || user["name"] != "Backend Test" {
+		t.Fatalf("expected only the age of user %d to change, got %v", id, user)
+	}
+
+	// The ETag used above is stale now, so the header must reach the backend.
+	text, isError = callTool(t, "update_user", map[string]any{"id": id, "If-Match": etag, "age": 34})
+	if !isError || !strings.Contains(text, "precondition_failed") {
+		t.Fatalf("expected a stale If-Match to fail with precondition_failed, got %q", text)
+	}
+
+	text, isError = callTool(t, "create_post", map[string]any{"user_id": id, "title": "Via MCP", "content": "Created by a tool"})
+	if isError || !strings.Contains(text, `"status":"draft"`) {
+		t.Fatalf("create_post failed or ignored the default status: %s", text)
+	}
+	if text, isError = callTool(t, "delete_user", map[string]any{"id": id}); isError {
+		t.Fatalf("delete_user failed: %s", text)
+	}
+}
+
+// TestToolErrorMapping tests that backend 4xx/5xx responses become tool errors
+func TestToolErrorMapping(t *testing.T) {
+	if !checkServiceAvailable(pixiuURL) || !checkServiceAvailable(backendURL) {
+		t.Skip("Services are not available")
+	}
+
+	cases := []struct {
+		tool string
+		args map[string]any
+		code string
+	}{
+		{"get_user", map[string]any{"id": 999999}, "not_found"},
+		{"delete_post", map[string]any{"id": 999999}, "not_found"},
+		{"create_user", map[string]any{"name": "Alice", "email": "alice@example.com"}, "conflict"},
+		{"create_user", map[string]any{"name": "Nobody", "email": "not-an-email"}, "validation_failed"},
+		{"simulate_error", map[string]any{"status": 429}, "rate_limited"},
+		{"simulate_error", map[string]any{"status": 500}, "internal"},
+		{"simulate_error", map[string]any{"status": 503}, "unavailable"},
+	}
+	for _, c := range cases {
+		text, isError := callTool(t, c.tool, c.args)
+		if !isError {
+			t.Fatalf("%s %v: expected a tool error, got %q", c.tool, c.args, text)
+		}
+		if !strings.Contains(text, c.code) {
+			t.Fatalf("%s %v: error text does not carry the backend code %q: %q", c.tool, c.args, c.code, text)
+		}
+	}
+}
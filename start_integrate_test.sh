@@ -36,6 +36,7 @@ array=(
   # grpc proxy
   "grpc/deprecated"
   "grpc/reflection"
+  "grpc/health"
   # plugins
   "plugins/opa/embedded"
   "plugins/opa/server-mode"
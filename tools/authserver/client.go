@@ -18,20 +18,53 @@
 package main
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
 
-// dynamicClientRegistrationRequest represents the RFC 7591 minimal client metadata accepted by /register.
+var (
+	// supportedGrantTypes lists the grant types clients may register for.
+	supportedGrantTypes = []string{"authorization_code", "refresh_token", "client_credentials"}
+	// supportedAuthMethods lists the token endpoint client authentication methods.
+	supportedAuthMethods = []string{"none", "client_secret_basic", "client_secret_post"}
+	// defaultGrantTypes applies when a client registers without grant_types (RFC 7591 section 2).
+	defaultGrantTypes = []string{"authorization_code"}
+)
+
+// dynamicClientRegistrationRequest represents the RFC 7591 client metadata accepted by
+// /register and, for updates, by /register/{client_id}.
 type dynamicClientRegistrationRequest struct {
+	// ClientID and ClientSecret are only sent on RFC 7592 updates and must match the registration.
+	ClientID                string   `json:"client_id,omitempty"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	ClientName              string   `json:"client_name,omitempty"`
 	RedirectURIs            []string `json:"redirect_uris"`
 	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	ResponseTypes           []string `json:"response_types,omitempty"`
+	// Scope is the space-delimited list of scopes the client may request.
+	Scope string `json:"scope,omitempty"`
+}
+
+// registrationError is an RFC 7591 section 3.2.2 error response.
+type registrationError struct {
+	Code        string
+	Description string
+}
+
+func (e *registrationError) Error() string {
+	return e.Code + ": " + e.Description
 }
 
-// handleDynamicClientRegistration implements a minimal RFC 7591 dynamic client registration endpoint.
+// handleDynamicClientRegistration implements the RFC 7591 dynamic client registration endpoint.
 func handleDynamicClientRegistration(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Received %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
 	if r.Method != http.MethodPost {
@@ -45,63 +78,248 @@ func handleDynamicClientRegistration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Basic validation: require at least one redirect URI and simple scheme check.
-	if len(req.RedirectURIs) == 0 {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_redirect_uris", "error_description": "redirect_uris must be provided"})
+	client, regErr := clientFromMetadata(req)
+	if regErr != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": regErr.Code, "error_description": regErr.Description})
 		return
 	}
-	for _, ru := range req.RedirectURIs {
-		if !strings.HasPrefix(ru, "http://") && !strings.HasPrefix(ru, "https://") {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_redirect_uri", "error_description": "redirect_uris must be absolute http(s) URLs"})
-			return
-		}
-	}
-
-	// Default token endpoint auth method
-	if req.TokenEndpointAuthMethod == "" {
-		req.TokenEndpointAuthMethod = "none"
-	}
 
 	clientID, err := generateRandomString(16)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to generate client ID"})
 		return
 	}
+	client.ID = clientID
+	client.ClientIDIssuedAt = time.Now().Unix()
 
-	var clientSecret string
-	if req.TokenEndpointAuthMethod != "none" {
-		clientSecret, err = generateRandomString(32)
+	if client.TokenEndpointAuthMethod != "none" {
+		client.Secret, err = generateRandomString(32)
 		if err != nil {
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to generate client secret"})
 			return
 		}
 	}
 
-	now := time.Now().Unix()
+	// The registration access token authorizes RFC 7592 management requests.
+	// Only its hash is stored, like a password.
+	registrationToken, err := generateRandomString(32)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to generate registration access token"})
+		return
+	}
+	client.RegistrationAccessTokenHash = hashRegistrationToken(registrationToken)
+
+	if err := store.PutClient(client); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to store client"})
+		return
+	}
+
+	resp := clientInformationResponse(client, registrationToken)
+	w.Header().Set("Location", resp["registration_client_uri"].(string))
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// handleClientConfiguration implements the RFC 7592 client configuration endpoint
+// at /register/{client_id}: read (GET), update (PUT) and delete (DELETE).
+func handleClientConfiguration(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	clientID := strings.TrimPrefix(r.URL.Path, "/register/")
+
+	// Unknown clients and bad tokens look the same, so client IDs cannot be probed (RFC 7592 section 3).
+	registrationToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	client, ok := store.GetClient(clientID)
+	if !ok || client.RegistrationAccessTokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(hashRegistrationToken(registrationToken)), []byte(client.RegistrationAccessTokenHash)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, clientInformationResponse(client, registrationToken))
+
+	case http.MethodPut:
+		var req dynamicClientRegistrationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		if req.ClientID != client.ID {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client_metadata", "error_description": "client_id does not match the registration"})
+			return
+		}
+		if req.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(req.ClientSecret), []byte(client.Secret)) != 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client_metadata", "error_description": "client_secret does not match the registration"})
+			return
+		}
+
+		// A PUT replaces the metadata; omitted fields fall back to their defaults (RFC 7592 section 2.2).
+		updated, regErr := clientFromMetadata(req)
+		if regErr != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": regErr.Code, "error_description": regErr.Description})
+			return
+		}
+		updated.ID = client.ID
+		updated.ClientIDIssuedAt = client.ClientIDIssuedAt
+		updated.RegistrationAccessTokenHash = client.RegistrationAccessTokenHash
+		switch {
+		case updated.TokenEndpointAuthMethod == "none":
+			updated.Secret = ""
+		case client.Secret != "":
+			updated.Secret = client.Secret
+		default:
+			// A public client became confidential and needs a secret now.
+			secret, err := generateRandomString(32)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to generate client secret"})
+				return
+			}
+			updated.Secret = secret
+		}
+
+		if err := store.PutClient(updated); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to store client"})
+			return
+		}
+		writeJSON(w, http.StatusOK, clientInformationResponse(updated, registrationToken))
+
+	case http.MethodDelete:
+		if err := store.DeleteClient(client.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to delete client"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+	}
+}
+
+// clientFromMetadata validates registration metadata and applies the RFC 7591 defaults.
+// The returned client has no ID, secret or registration access token yet.
+func clientFromMetadata(req dynamicClientRegistrationRequest) (ClientInfo, *registrationError) {
 	client := ClientInfo{
-		ID:                      clientID,
-		Secret:                  clientSecret,
+		Name:                    req.ClientName,
 		RedirectURIs:            req.RedirectURIs,
 		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
-		ClientIDIssuedAt:        now,
+		GrantTypes:              req.GrantTypes,
+		ResponseTypes:           req.ResponseTypes,
 	}
 
-	clients[clientID] = client
+	// Default token endpoint auth method
+	if client.TokenEndpointAuthMethod == "" {
+		client.TokenEndpointAuthMethod = "none"
+	}
+	if !containsString(supportedAuthMethods, client.TokenEndpointAuthMethod) {
+		return client, &registrationError{"invalid_client_metadata", "unsupported token_endpoint_auth_method"}
+	}
 
-	issuer := issuerBaseURL // Use shared constant
-	regURI := issuer + "/register/" + clientID
+	if len(client.GrantTypes) == 0 {
+		client.GrantTypes = defaultGrantTypes
+	}
+	for _, gt := range client.GrantTypes {
+		if !containsString(supportedGrantTypes, gt) {
+			return client, &registrationError{"invalid_client_metadata", "unsupported grant_type " + gt}
+		}
+	}
+	if containsString(client.GrantTypes, "client_credentials") && client.TokenEndpointAuthMethod == "none" {
+		return client, &registrationError{"invalid_client_metadata", "client_credentials requires a confidential client"}
+	}
+
+	// response_types and grant_types must be consistent (RFC 7591 section 2.1)
+	usesCodeGrant := containsString(client.GrantTypes, "authorization_code")
+	if len(client.ResponseTypes) == 0 && usesCodeGrant {
+		client.ResponseTypes = []string{"code"}
+	}
+	for _, rt := range client.ResponseTypes {
+		if rt != "code" {
+			return client, &registrationError{"invalid_client_metadata", "unsupported response_type " + rt}
+		}
+	}
+	if usesCodeGrant != containsString(client.ResponseTypes, "code") {
+		return client, &registrationError{"invalid_client_metadata", "response_type code and grant_type authorization_code must be registered together"}
+	}
 
+	// The authorization code flow needs somewhere to send the code
+	if usesCodeGrant && len(client.RedirectURIs) == 0 {
+		return client, &registrationError{"invalid_redirect_uri", "redirect_uris must be provided"}
+	}
+	for _, ru := range client.RedirectURIs {
+		if desc := validateRedirectURI(ru); desc != "" {
+			return client, &registrationError{"invalid_redirect_uri", desc}
+		}
+	}
+
+	// Scopes default to everything the server supports
+	client.Scopes = supportedScopes
+	if req.Scope != "" {
+		scope, ok := resolveScope(req.Scope, supportedScopes)
+		if !ok {
+			return client, &registrationError{"invalid_client_metadata", "scope contains unsupported values"}
+		}
+		client.Scopes = parseScope(scope)
+	}
+	return client, nil
+}
+
+// validateRedirectURI applies the redirect URI rules of RFC 6749 section 3.1.2 and RFC 8252.
+// It returns a description of the problem, or an empty string for a valid URI.
+func validateRedirectURI(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() {
+		return "redirect_uris must be absolute URIs"
+	}
+	if u.Fragment != "" {
+		return "redirect_uris must not contain a fragment"
+	}
+
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return "https redirect_uris must have a host"
+		}
+	case "http":
+		// Plain http is only acceptable for loopback redirects of native and local apps
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return "http redirect_uris are only allowed for localhost"
+		}
+	default:
+		// Private-use schemes must be reverse domain names, e.g. com.example.app:/callback
+		if !strings.Contains(u.Scheme, ".") {
+			return "redirect_uris must use https, http on localhost, or a reverse domain name scheme"
+		}
+	}
+	return ""
+}
+
+// clientInformationResponse renders the RFC 7591 section 3.2.1 client information response.
+func clientInformationResponse(client ClientInfo, registrationToken string) map[string]interface{} {
+	issuer := issuerBaseURL // Use shared constant
 	resp := map[string]interface{}{
 		"client_id":                  client.ID,
 		"redirect_uris":              client.RedirectURIs,
 		"client_id_issued_at":        client.ClientIDIssuedAt,
 		"token_endpoint_auth_method": client.TokenEndpointAuthMethod,
-		"registration_client_uri":    regURI,
+		"grant_types":                client.GrantTypes,
+		"response_types":             client.ResponseTypes,
+		"scope":                      strings.Join(client.Scopes, " "),
+		"registration_client_uri":    issuer + "/register/" + client.ID,
+		"registration_access_token":  registrationToken,
+	}
+	if client.Name != "" {
+		resp["client_name"] = client.Name
 	}
 	if client.Secret != "" {
 		resp["client_secret"] = client.Secret
+		resp["client_secret_expires_at"] = 0 // never expires
 	}
+	return resp
+}
 
-	w.Header().Set("Location", regURI)
-	writeJSON(w, http.StatusCreated, resp)
+// hashRegistrationToken returns the hex SHA-256 of a registration access token.
+func hashRegistrationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,217 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleDynamicClientRegistrationValidation(t *testing.T) {
+	initStore(newMemoryStore())
+
+	testCases := []struct {
+		name          string
+		body          string
+		expectedError string
+	}{
+		{
+			name: "Public PKCE client",
+			body: `{"redirect_uris":["http://localhost:3000/callback"]}`,
+		},
+		{
+			name: "Native app with private-use scheme",
+			body: `{"redirect_uris":["com.example.app:/callback"],"grant_types":["authorization_code","refresh_token"]}`,
+		},
+		{
+			name: "Confidential machine client",
+			body: `{"token_endpoint_auth_method":"client_secret_basic","grant_types":["client_credentials"]}`,
+		},
+		{
+			name:          "Missing redirect URIs",
+			body:          `{}`,
+			expectedError: "invalid_redirect_uri",
+		},
+		{
+			name:          "Plain http on a remote host",
+			body:          `{"redirect_uris":["http://example.com/callback"]}`,
+			expectedError: "invalid_redirect_uri",
+		},
+		{
+			name:          "Redirect URI with fragment",
+			body:          `{"redirect_uris":["https://example.com/callback#frag"]}`,
+			expectedError: "invalid_redirect_uri",
+		},
+		{
+			name:          "Unsupported grant type",
+			body:          `{"redirect_uris":["https://example.com/cb"],"grant_types":["password"]}`,
+			expectedError: "invalid_client_metadata",
+		},
+		{
+			name:          "Inconsistent response types",
+			body:          `{"token_endpoint_auth_method":"client_secret_post","grant_types":["client_credentials"],"response_types":["code"]}`,
+			expectedError: "invalid_client_metadata",
+		},
+		{
+			name:          "Implicit response type",
+			body:          `{"redirect_uris":["https://example.com/cb"],"response_types":["token"]}`,
+			expectedError: "invalid_client_metadata",
+		},
+		{
+			name:          "Public client with client_credentials",
+			body:          `{"grant_types":["client_credentials"]}`,
+			expectedError: "invalid_client_metadata",
+		},
+		{
+			name:          "Unsupported auth method",
+			body:          `{"redirect_uris":["https://example.com/cb"],"token_endpoint_auth_method":"private_key_jwt"}`,
+			expectedError: "invalid_client_metadata",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := sendRegistration(http.MethodPost, "/register", "", tc.body)
+			var resp map[string]interface{}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+
+			if tc.expectedError != "" {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Equal(t, tc.expectedError, resp["error"])
+				return
+			}
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.NotEmpty(t, resp["client_id"])
+			assert.NotEmpty(t, resp["registration_access_token"])
+			assert.Equal(t, w.Header().Get("Location"), resp["registration_client_uri"])
+		})
+	}
+}
+
+func TestClientConfigurationLifecycle(t *testing.T) {
+	initStore(newMemoryStore())
+	initJWT()
+
+	// Register a public client
+	w := sendRegistration(http.MethodPost, "/register", "", `{"client_name":"Lifecycle","redirect_uris":["http://localhost:3000/cb"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var registered map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&registered))
+	clientID := registered["client_id"].(string)
+	token := registered["registration_access_token"].(string)
+	path := "/register/" + clientID
+	assert.Nil(t, registered["client_secret"], "public clients get no secret")
+
+	t.Run("Read requires the registration access token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, sendRegistration(http.MethodGet, path, "", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, sendRegistration(http.MethodGet, path, "wrong", "").Code)
+		// Unknown clients are indistinguishable from bad tokens
+		assert.Equal(t, http.StatusUnauthorized, sendRegistration(http.MethodGet, "/register/unknown", token, "").Code)
+
+		w := sendRegistration(http.MethodGet, path, token, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, "Lifecycle", resp["client_name"])
+		assert.Equal(t, []interface{}{"authorization_code"}, resp["grant_types"])
+	})
+
+	t.Run("Update makes the client confidential", func(t *testing.T) {
+		body := `{"client_id":"` + clientID + `","redirect_uris":["http://localhost:3000/cb"],` +
+			`"token_endpoint_auth_method":"client_secret_post","grant_types":["authorization_code","client_credentials"]}`
+		assert.Equal(t, http.StatusBadRequest, sendRegistration(http.MethodPut, path, token, `{"client_id":"other","redirect_uris":["http://localhost:3000/cb"]}`).Code)
+
+		w := sendRegistration(http.MethodPut, path, token, body)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		secret, _ := resp["client_secret"].(string)
+		require.NotEmpty(t, secret)
+		assert.Nil(t, resp["client_name"], "omitted fields are cleared on update")
+
+		// The secret now authenticates the client with client_secret_post only
+		form := url.Values{"grant_type": {"client_credentials"}, "resource": {"test-resource"}, "client_id": {clientID}, "client_secret": {secret}}
+		status, tokenResp := postToken(t, form, nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.NotEmpty(t, tokenResp.AccessToken)
+
+		form.Del("client_secret")
+		status, _ = postToken(t, form, []string{clientID, secret})
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, sendRegistration(http.MethodDelete, path, token, "").Code)
+		_, ok := store.GetClient(clientID)
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusUnauthorized, sendRegistration(http.MethodGet, path, token, "").Code)
+	})
+}
+
+func TestGrantTypeRestrictions(t *testing.T) {
+	initStore(newMemoryStore())
+	initJWT()
+	require.NoError(t, store.PutClient(ClientInfo{
+		ID:                      "code-only",
+		RedirectURIs:            []string{"http://localhost:8081/callback"},
+		TokenEndpointAuthMethod: "none",
+		GrantTypes:              []string{"authorization_code"},
+		ResponseTypes:           []string{"code"},
+		Scopes:                  supportedScopes,
+	}))
+
+	verifier := "test_verifier"
+	require.NoError(t, store.PutAuthCode("code", AuthCodeInfo{
+		ClientID:      "code-only",
+		CodeChallenge: calculateS256Challenge(verifier),
+		Resource:      "test-resource",
+		Expiry:        time.Now().Add(time.Minute),
+	}))
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {"code"}, "client_id": {"code-only"}, "code_verifier": {verifier}, "resource": {"test-resource"}}
+	status, resp := postToken(t, form, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, resp.RefreshToken, "clients without the refresh_token grant get no refresh token")
+
+	status, _ = postToken(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"x"}, "client_id": {"code-only"}}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+// sendRegistration calls the registration endpoints with an optional registration access token.
+func sendRegistration(method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	if path == "/register" {
+		handleDynamicClientRegistration(w, req)
+	} else {
+		handleClientConfiguration(w, req)
+	}
+	return w
+}
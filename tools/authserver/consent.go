@@ -0,0 +1,72 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"flag"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+var (
+	autoApprove = flag.Bool("auto_approve", true, "Skip the consent page and approve every request as the default test user, -auto_approve=false shows the page")
+)
+
+// consentPage is shown by GET /oauth/authorize. It posts the original request
+// parameters back to the same endpoint together with the user's decision.
+var consentPage = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorize {{.Request.ClientID}}</title></head>
+<body>
+  <h1>Authorize {{.Request.ClientID}}</h1>
+  <p>The application <strong>{{.Request.ClientID}}</strong> wants to access <code>{{.Request.Resource}}</code> with the following scopes:</p>
+  <ul>{{range .Scopes}}<li><code>{{.}}</code></li>{{else}}<li>No scopes requested</li>{{end}}</ul>
+  {{if .Error}}<p style="color:red">{{.Error}}</p>{{end}}
+  <form method="POST" action="/oauth/authorize">
+    <input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+    <input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+    <input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+    <input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+    <input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+    <input type="hidden" name="resource" value="{{.Request.Resource}}">
+    <input type="hidden" name="scope" value="{{.Request.Scope}}">
+    <input type="hidden" name="state" value="{{.Request.State}}">
+    <input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+    <p><label>Username <input type="text" name="username" autocomplete="username"></label></p>
+    <p><label>Password <input type="password" name="password" autocomplete="current-password"></label></p>
+    <button type="submit" name="action" value="approve">Approve</button>
+    <button type="submit" name="action" value="deny">Deny</button>
+  </form>
+</body>
+</html>
+`))
+
+// renderConsent writes the consent page, optionally with an error message for a failed login.
+func renderConsent(w http.ResponseWriter, status int, req authorizeRequest, errMsg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	data := map[string]interface{}{
+		"Request": req,
+		"Scopes":  parseScope(req.Scope),
+		"Error":   errMsg,
+	}
+	if err := consentPage.Execute(w, data); err != nil {
+		log.Printf("failed to render consent page: %v", err)
+	}
+}
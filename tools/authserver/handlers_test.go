@@ -110,6 +110,7 @@ func TestHandleMetadata(t *testing.T) {
 	assert.Equal(t, issuer+"/oauth/token", meta["token_endpoint"])
 	assert.Equal(t, issuer+"/.well-known/jwks.json", meta["jwks_uri"])
 	assert.Equal(t, issuer+"/register", meta["registration_endpoint"])
+	assert.ElementsMatch(t, []interface{}{"authorization_code", "refresh_token", "client_credentials"}, meta["grant_types_supported"])
 }
 
 func TestHandleJwks(t *testing.T) {
@@ -130,15 +131,17 @@ func TestHandleJwks(t *testing.T) {
 	require.Len(t, jwksResponse.Keys, 1)
 	key := jwksResponse.Keys[0]
 	assert.Equal(t, "RSA", key.Kty)
-	assert.Equal(t, keyID, key.Kid)
+	assert.Equal(t, keys.current().ID, key.Kid)
 	assert.Equal(t, "sig", key.Use)
 	assert.Equal(t, "RS256", key.Alg)
 }
 
 func TestHandleAuthorize(t *testing.T) {
-	initStore()
+	initStore(newMemoryStore())
+	*autoApprove = false
+	defer func() { *autoApprove = true }()
 
-	t.Run("Successful authorization", func(t *testing.T) {
+	authorizeParams := func() url.Values {
 		q := url.Values{}
 		q.Set("client_id", "sample-client")
 		q.Set("redirect_uri", "http://localhost:8081/callback")
@@ -147,13 +150,32 @@ func TestHandleAuthorize(t *testing.T) {
 		q.Set("code_challenge_method", "S256")
 		q.Set("state", "12345")
 		q.Set("resource", "test-resource")
+		q.Set("scope", "read")
+		return q
+	}
 
-		req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+q.Encode(), nil)
+	t.Run("Consent page", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+authorizeParams().Encode(), nil)
 		w := httptest.NewRecorder()
 
 		handleAuthorize(w, req)
 
 		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+		body := w.Body.String()
+		assert.Contains(t, body, "sample-client")
+		assert.Contains(t, body, `name="scope" value="read"`)
+		assert.Contains(t, body, `name="state" value="12345"`)
+	})
+
+	t.Run("Successful authorization", func(t *testing.T) {
+		form := authorizeParams()
+		form.Set("username", "demo")
+		form.Set("password", "demo")
+		form.Set("action", "approve")
+
+		resp := postForm(handleAuthorize, "/oauth/authorize", form, nil).Result()
 		assert.Equal(t, http.StatusFound, resp.StatusCode)
 
 		loc, err := resp.Location()
@@ -164,9 +186,72 @@ func TestHandleAuthorize(t *testing.T) {
 		assert.NotEmpty(t, code)
 		assert.Equal(t, "12345", loc.Query().Get("state"))
 
-		// Check that the code was stored
-		_, ok := authCodes[code]
+		// Check that the code was stored with the granted scope and the user
+		authCode, ok := store.TakeAuthCode(code)
 		assert.True(t, ok, "Auth code should be stored")
+		assert.Equal(t, "read", authCode.Scope)
+		assert.Equal(t, "demo", authCode.Subject)
+	})
+
+	t.Run("Denied authorization", func(t *testing.T) {
+		form := authorizeParams()
+		form.Set("action", "deny")
+
+		resp := postForm(handleAuthorize, "/oauth/authorize", form, nil).Result()
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+		loc, err := resp.Location()
+		require.NoError(t, err)
+		assert.Equal(t, "access_denied", loc.Query().Get("error"))
+		assert.Equal(t, "12345", loc.Query().Get("state"))
+		assert.Empty(t, loc.Query().Get("code"))
+	})
+
+	t.Run("Wrong password", func(t *testing.T) {
+		form := authorizeParams()
+		form.Set("username", "demo")
+		form.Set("password", "wrong")
+		form.Set("action", "approve")
+
+		w := postForm(handleAuthorize, "/oauth/authorize", form, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid username or password")
+	})
+
+	t.Run("Scope not allowed for client", func(t *testing.T) {
+		q := authorizeParams()
+		q.Set("scope", "read admin")
+
+		req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+q.Encode(), nil)
+		w := httptest.NewRecorder()
+
+		handleAuthorize(w, req)
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+		loc, err := resp.Location()
+		require.NoError(t, err)
+		assert.Equal(t, "invalid_scope", loc.Query().Get("error"))
+	})
+
+	t.Run("Auto approve", func(t *testing.T) {
+		*autoApprove = true
+		defer func() { *autoApprove = false }()
+
+		q := authorizeParams()
+		q.Del("scope") // defaults to every scope of the client
+		req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+q.Encode(), nil)
+		w := httptest.NewRecorder()
+
+		handleAuthorize(w, req)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		loc, err := resp.Location()
+		require.NoError(t, err)
+		authCode, ok := store.TakeAuthCode(loc.Query().Get("code"))
+		require.True(t, ok)
+		assert.Equal(t, "read write", authCode.Scope)
 	})
 
 	t.Run("Invalid client ID", func(t *testing.T) {
@@ -188,7 +273,7 @@ func TestHandleAuthorize(t *testing.T) {
 }
 
 func TestHandleToken(t *testing.T) {
-	initStore()
+	initStore(newMemoryStore())
 	initJWT()
 
 	verifier := "test_verifier"
@@ -197,13 +282,15 @@ func TestHandleToken(t *testing.T) {
 	t.Run("Successful token exchange", func(t *testing.T) {
 		// 1. Setup: Store a valid auth code
 		code := "test_code_success"
-		authCodes[code] = AuthCodeInfo{
+		require.NoError(t, store.PutAuthCode(code, AuthCodeInfo{
 			ClientID:      "sample-client",
 			RedirectURI:   "http://localhost:8081/callback",
 			CodeChallenge: challenge,
 			Resource:      "test-resource",
+			Scope:         "read write",
+			Subject:       "demo",
 			Expiry:        time.Now().Add(10 * time.Minute),
-		}
+		}))
 
 		// 2. Execute: Make the token request
 		data := url.Values{}
@@ -230,9 +317,16 @@ func TestHandleToken(t *testing.T) {
 		assert.NotEmpty(t, tokenResp.AccessToken)
 		assert.Equal(t, "Bearer", tokenResp.TokenType)
 		assert.Equal(t, int64(tokenTTL.Seconds()), tokenResp.ExpiresIn)
+		assert.Equal(t, "read write", tokenResp.Scope)
+
+		// The granted scope and the user end up in the access token
+		claims, err := parseJWT(tokenResp.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, "read write", claims["scope"])
+		assert.Equal(t, "demo", claims["sub"])
 
 		// Check that the auth code was deleted
-		_, ok := authCodes[code]
+		_, ok := store.TakeAuthCode(code)
 		assert.False(t, ok, "Auth code should be deleted after use")
 	})
 
@@ -256,12 +350,12 @@ func TestHandleToken(t *testing.T) {
 	t.Run("PKCE verification failed", func(t *testing.T) {
 		// 1. Setup: Store a valid auth code
 		code := "test_code_pkce_fail"
-		authCodes[code] = AuthCodeInfo{
+		require.NoError(t, store.PutAuthCode(code, AuthCodeInfo{
 			ClientID:      "sample-client",
 			CodeChallenge: challenge,
 			Resource:      "test-resource",
 			Expiry:        time.Now().Add(10 * time.Minute),
-		}
+		}))
 
 		// 2. Execute: Make the token request with a wrong verifier
 		data := url.Values{}
@@ -282,6 +376,178 @@ func TestHandleToken(t *testing.T) {
 	})
 }
 
+func TestHandleTokenRefreshGrant(t *testing.T) {
+	initStore(newMemoryStore())
+	initJWT()
+
+	// Obtain an initial refresh token through the authorization code grant
+	verifier := "test_verifier"
+	code := "test_code_refresh"
+	require.NoError(t, store.PutAuthCode(code, AuthCodeInfo{
+		ClientID:      "sample-client",
+		CodeChallenge: calculateS256Challenge(verifier),
+		Resource:      "test-resource",
+		Expiry:        time.Now().Add(10 * time.Minute),
+	}))
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("code", code)
+	data.Set("client_id", "sample-client")
+	data.Set("code_verifier", verifier)
+	data.Set("resource", "test-resource")
+	status, initial := postToken(t, data, nil)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, initial.RefreshToken)
+
+	refresh := func(token string) (int, tokenResponse) {
+		data := url.Values{}
+		data.Set("grant_type", "refresh_token")
+		data.Set("refresh_token", token)
+		data.Set("client_id", "sample-client")
+		return postToken(t, data, nil)
+	}
+
+	t.Run("Rotation issues a new refresh token", func(t *testing.T) {
+		status, rotated := refresh(initial.RefreshToken)
+		assert.Equal(t, http.StatusOK, status)
+		assert.NotEmpty(t, rotated.AccessToken)
+		assert.NotEmpty(t, rotated.RefreshToken)
+		assert.NotEqual(t, initial.RefreshToken, rotated.RefreshToken)
+
+		t.Run("Reuse of a rotated token revokes the family", func(t *testing.T) {
+			status, _ := refresh(initial.RefreshToken)
+			assert.Equal(t, http.StatusBadRequest, status)
+
+			// The descendant token was revoked as well
+			status, _ = refresh(rotated.RefreshToken)
+			assert.Equal(t, http.StatusBadRequest, status)
+		})
+	})
+
+	t.Run("Token issued to another client", func(t *testing.T) {
+		require.NoError(t, store.PutRefreshToken("foreign_token", RefreshTokenInfo{
+			ClientID: "another-client",
+			Resource: "test-resource",
+			FamilyID: "foreign",
+			Expiry:   time.Now().Add(time.Hour),
+		}))
+		status, _ := refresh("foreign_token")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("Expired refresh token", func(t *testing.T) {
+		require.NoError(t, store.PutRefreshToken("expired_token", RefreshTokenInfo{
+			ClientID: "sample-client",
+			Resource: "test-resource",
+			FamilyID: "expired",
+			Expiry:   time.Now().Add(-time.Minute),
+		}))
+		status, _ := refresh("expired_token")
+		assert.Equal(t, http.StatusBadRequest, status)
+		_, ok := store.GetRefreshToken("expired_token")
+		assert.False(t, ok, "Expired refresh token should be deleted")
+	})
+}
+
+func TestHandleTokenClientCredentialsGrant(t *testing.T) {
+	initStore(newMemoryStore())
+	initJWT()
+	require.NoError(t, store.PutClient(ClientInfo{
+		ID:                      "post-service",
+		Secret:                  "post-secret",
+		TokenEndpointAuthMethod: "client_secret_post",
+		GrantTypes:              []string{"client_credentials"},
+		Scopes:                  supportedScopes,
+	}))
+
+	testCases := []struct {
+		name           string
+		form           url.Values
+		basicAuth      []string
+		expectedStatus int
+		expectedScope  string
+	}{
+		{
+			name:           "Basic authentication",
+			form:           url.Values{"grant_type": {"client_credentials"}, "resource": {"test-resource"}, "scope": {"read"}},
+			basicAuth:      []string{"sample-service", "service-secret"},
+			expectedStatus: http.StatusOK,
+			expectedScope:  "read",
+		},
+		{
+			name:           "Form authentication with default scope",
+			form:           url.Values{"grant_type": {"client_credentials"}, "resource": {"test-resource"}, "client_id": {"post-service"}, "client_secret": {"post-secret"}},
+			expectedStatus: http.StatusOK,
+			expectedScope:  "read write",
+		},
+		{
+			name:           "Auth method other than registered",
+			form:           url.Values{"grant_type": {"client_credentials"}, "resource": {"test-resource"}, "client_id": {"sample-service"}, "client_secret": {"service-secret"}},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Scope not allowed for client",
+			form:           url.Values{"grant_type": {"client_credentials"}, "resource": {"test-resource"}, "scope": {"admin"}},
+			basicAuth:      []string{"sample-service", "service-secret"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Wrong secret",
+			form:           url.Values{"grant_type": {"client_credentials"}, "resource": {"test-resource"}},
+			basicAuth:      []string{"sample-service", "wrong"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Public client",
+			form:           url.Values{"grant_type": {"client_credentials"}, "resource": {"test-resource"}, "client_id": {"sample-client"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Missing resource",
+			form:           url.Values{"grant_type": {"client_credentials"}},
+			basicAuth:      []string{"sample-service", "service-secret"},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, resp := postToken(t, tc.form, tc.basicAuth)
+			assert.Equal(t, tc.expectedStatus, status)
+			if tc.expectedStatus == http.StatusOK {
+				assert.NotEmpty(t, resp.AccessToken)
+				assert.Empty(t, resp.RefreshToken, "client_credentials must not issue a refresh token")
+				assert.Equal(t, tc.expectedScope, resp.Scope)
+			}
+		})
+	}
+}
+
+// postToken sends a form to the token endpoint, optionally with HTTP Basic client credentials.
+func postToken(t *testing.T, data url.Values, basicAuth []string) (int, tokenResponse) {
+	t.Helper()
+	w := postForm(handleToken, "/oauth/token", data, basicAuth)
+
+	resp := w.Result()
+	var tokenResp tokenResponse
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&tokenResp))
+	}
+	return resp.StatusCode, tokenResp
+}
+
+// postForm sends a form to handler, optionally with HTTP Basic client credentials.
+func postForm(handler http.HandlerFunc, path string, data url.Values, basicAuth []string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if len(basicAuth) == 2 {
+		req.SetBasicAuth(basicAuth[0], basicAuth[1])
+	}
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
 // Helper function for generating challenges in tests
 func calculateS256Challenge(verifier string) string {
 	hasher := sha256.New()
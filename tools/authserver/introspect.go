@@ -0,0 +1,162 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// handleIntrospect implements RFC 7662 token introspection for access and refresh tokens.
+// Only confidential clients, such as a gateway acting as resource server, may introspect.
+func handleIntrospect(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	client, ok := authenticateClient(r)
+	if !ok || client.TokenEndpointAuthMethod == "none" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="authserver"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "token parameter required"})
+		return
+	}
+
+	// The hint only decides which lookup happens first (RFC 7662 section 2.1).
+	lookups := []func(string) map[string]interface{}{introspectAccessToken, introspectRefreshToken}
+	if r.PostForm.Get("token_type_hint") == "refresh_token" {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		if resp := lookup(token); resp != nil {
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+	}
+
+	// Unknown, expired or revoked tokens are simply inactive.
+	writeJSON(w, http.StatusOK, map[string]interface{}{"active": false})
+}
+
+// introspectAccessToken returns the introspection response of a valid, unrevoked JWT, or nil.
+func introspectAccessToken(token string) map[string]interface{} {
+	claims, err := parseJWT(token)
+	if err != nil {
+		return nil
+	}
+	if jti, _ := claims["jti"].(string); jti == "" || store.IsAccessTokenRevoked(jti) {
+		return nil
+	}
+
+	resp := map[string]interface{}{
+		"active":     true,
+		"token_type": "Bearer",
+	}
+	for _, claim := range []string{"scope", "client_id", "sub", "iss", "aud", "exp", "iat", "jti"} {
+		if v, ok := claims[claim]; ok {
+			resp[claim] = v
+		}
+	}
+	return resp
+}
+
+// introspectRefreshToken returns the introspection response of a live refresh token, or nil.
+func introspectRefreshToken(token string) map[string]interface{} {
+	info, ok := store.GetRefreshToken(token)
+	if !ok || info.Used || time.Now().After(info.Expiry) {
+		return nil
+	}
+	return map[string]interface{}{
+		"active":     true,
+		"token_type": "refresh_token",
+		"scope":      info.Scope,
+		"client_id":  info.ClientID,
+		"sub":        info.Subject,
+		"iss":        issuerBaseURL,
+		"aud":        info.Resource,
+		"exp":        info.Expiry.Unix(),
+	}
+}
+
+// handleRevoke implements RFC 7009 token revocation.
+// Revoking a refresh token revokes its whole token family; revoking an access token
+// adds it to the revocation list consulted by introspection.
+func handleRevoke(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	client, ok := authenticateClient(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "token parameter required"})
+		return
+	}
+
+	if info, ok := store.GetRefreshToken(token); ok {
+		if info.ClientID != client.ID {
+			// RFC 7009 section 2.1: a client may only revoke its own tokens.
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "token was issued to another client"})
+			return
+		}
+		if err := store.RevokeRefreshTokenFamily(info.FamilyID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to revoke token"})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if claims, err := parseJWT(token); err == nil {
+		if owner, _ := claims["client_id"].(string); owner != client.ID {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "token was issued to another client"})
+			return
+		}
+		jti, _ := claims["jti"].(string)
+		exp, _ := claims["exp"].(float64)
+		if err := store.RevokeAccessToken(jti, time.Unix(int64(exp), 0)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to revoke token"})
+			return
+		}
+	}
+
+	// Invalid or unknown tokens are not an error (RFC 7009 section 2.2).
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,153 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleIntrospect(t *testing.T) {
+	initStore(newMemoryStore())
+	initJWT()
+
+	accessToken, err := issueJWT(tokenGrant{ClientID: "sample-client", Subject: "demo", Resource: "test-resource", Scope: "read"})
+	require.NoError(t, err)
+	require.NoError(t, store.PutRefreshToken("refresh", RefreshTokenInfo{
+		ClientID: "sample-client",
+		Resource: "test-resource",
+		Scope:    "read",
+		FamilyID: "family",
+		Expiry:   time.Now().Add(time.Hour),
+	}))
+
+	// A token signed by a key this server never published
+	ownKeys := keys
+	keys, err = newKeyRing(algRS256, "", "")
+	require.NoError(t, err)
+	foreignToken, err := issueJWT(tokenGrant{ClientID: "sample-client", Subject: "demo", Resource: "test-resource", Scope: "read"})
+	require.NoError(t, err)
+	keys = ownKeys
+
+	service := []string{"sample-service", "service-secret"}
+
+	t.Run("Active access token", func(t *testing.T) {
+		status, resp := postIntrospect(t, url.Values{"token": {accessToken}}, service)
+		require.Equal(t, http.StatusOK, status)
+		assert.Equal(t, true, resp["active"])
+		assert.Equal(t, "read", resp["scope"])
+		assert.Equal(t, "sample-client", resp["client_id"])
+		assert.Equal(t, "test-resource", resp["aud"])
+		assert.Equal(t, issuerBaseURL, resp["iss"])
+	})
+
+	t.Run("Active refresh token with hint", func(t *testing.T) {
+		status, resp := postIntrospect(t, url.Values{"token": {"refresh"}, "token_type_hint": {"refresh_token"}}, service)
+		require.Equal(t, http.StatusOK, status)
+		assert.Equal(t, true, resp["active"])
+		assert.Equal(t, "refresh_token", resp["token_type"])
+	})
+
+	t.Run("Unknown and foreign tokens are inactive", func(t *testing.T) {
+		for _, token := range []string{"garbage", foreignToken, accessToken[:len(accessToken)-4] + "AAAA"} {
+			status, resp := postIntrospect(t, url.Values{"token": {token}}, service)
+			require.Equal(t, http.StatusOK, status)
+			assert.Equal(t, map[string]interface{}{"active": false}, resp)
+		}
+	})
+
+	t.Run("Public clients may not introspect", func(t *testing.T) {
+		status, _ := postIntrospect(t, url.Values{"token": {accessToken}, "client_id": {"sample-client"}}, nil)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("Missing client authentication", func(t *testing.T) {
+		status, _ := postIntrospect(t, url.Values{"token": {accessToken}}, []string{"sample-service", "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+}
+
+func TestHandleRevoke(t *testing.T) {
+	initStore(newMemoryStore())
+	initJWT()
+	service := []string{"sample-service", "service-secret"}
+
+	t.Run("Revoked access token becomes inactive", func(t *testing.T) {
+		accessToken, err := issueJWT(tokenGrant{ClientID: "sample-service", Subject: "demo", Resource: "test-resource", Scope: ""})
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, postRevoke(t, url.Values{"token": {accessToken}}, service))
+
+		_, resp := postIntrospect(t, url.Values{"token": {accessToken}}, service)
+		assert.Equal(t, false, resp["active"])
+	})
+
+	t.Run("Revoking a refresh token revokes its family", func(t *testing.T) {
+		for _, token := range []string{"first", "second"} {
+			require.NoError(t, store.PutRefreshToken(token, RefreshTokenInfo{
+				ClientID: "sample-client",
+				FamilyID: "family",
+				Expiry:   time.Now().Add(time.Hour),
+			}))
+		}
+
+		status := postRevoke(t, url.Values{"token": {"second"}, "token_type_hint": {"refresh_token"}, "client_id": {"sample-client"}}, nil)
+		assert.Equal(t, http.StatusOK, status)
+		_, ok := store.GetRefreshToken("first")
+		assert.False(t, ok)
+	})
+
+	t.Run("Tokens of another client are not revoked", func(t *testing.T) {
+		accessToken, err := issueJWT(tokenGrant{ClientID: "sample-client", Subject: "demo", Resource: "test-resource", Scope: ""})
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, postRevoke(t, url.Values{"token": {accessToken}}, service))
+		_, resp := postIntrospect(t, url.Values{"token": {accessToken}}, service)
+		assert.Equal(t, true, resp["active"])
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, postRevoke(t, url.Values{"token": {"unknown"}}, service))
+	})
+
+	t.Run("Invalid client", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, postRevoke(t, url.Values{"token": {"unknown"}, "client_id": {"nobody"}}, nil))
+	})
+}
+
+func postIntrospect(t *testing.T, data url.Values, basicAuth []string) (int, map[string]interface{}) {
+	t.Helper()
+	w := postForm(handleIntrospect, "/oauth/introspect", data, basicAuth)
+	var resp map[string]interface{}
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	}
+	return w.Code, resp
+}
+
+func postRevoke(t *testing.T, data url.Values, basicAuth []string) int {
+	t.Helper()
+	return postForm(handleRevoke, "/oauth/revoke", data, basicAuth).Code
+}
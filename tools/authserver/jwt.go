@@ -18,13 +18,11 @@
 package main
 
 import (
-	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"flag"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -33,52 +31,90 @@ import (
 )
 
 const (
-	keyID    = "demo-key-1"
 	tokenTTL = time.Hour
 )
 
 var (
-	// privKey is the RSA private key generated at startup.
-	privKey *rsa.PrivateKey
+	keyAlg      = flag.String("key_alg", algRS256, "Algorithm for generated signing keys: RS256, ES256 or EdDSA")
+	keyFile     = flag.String("key_file", "", "PEM file holding a fixed signing key")
+	keyDir      = flag.String("key_dir", "", "Directory to load signing keys from and persist generated keys to")
+	keyRotation = flag.Duration("key_rotation", 0, "Interval for signing key rotation, 0 disables rotation (requires key_dir or ephemeral keys)")
 )
 
-// initJWT generates an ephemeral RSA key for signing tokens.
-// In a production environment, keys should be loaded from a secure vault.
+var (
+	// keys holds the signing keys loaded or generated at startup.
+	keys *keyRing
+)
+
+// initJWT loads the signing keys selected by the key flags.
+// Without key_file or key_dir an ephemeral key is generated, which means
+// tokens issued before a restart can no longer be validated.
 func initJWT() {
 	var err error
-	privKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	keys, err = newKeyRing(*keyAlg, *keyFile, *keyDir)
+	if err != nil {
+		log.Fatalf("failed to initialize signing keys: %v", err)
+	}
+}
+
+// tokenGrant describes what an access token is issued for.
+type tokenGrant struct {
+	ClientID string
+	// Subject is the user who approved the grant, or the client itself for client_credentials.
+	Subject  string
+	Resource string
+	Scope    string
+	// Nonce and AuthTime are only used for id_tokens. A zero AuthTime means no user
+	// authenticated, as with client_credentials, so no id_token is issued.
+	Nonce    string
+	AuthTime time.Time
+}
+
+// issueJWT creates a new JWT for the given grant, using its resource as audience.
+func issueJWT(grant tokenGrant) (string, error) {
+	jti, err := generateRandomString(16)
 	if err != nil {
-		log.Fatalf("failed to generate RSA key: %v", err)
+		return "", errors.Wrap(err, "failed to generate token ID")
 	}
+
+	claims := map[string]interface{}{
+		"iss":       issuerBaseURL, // Use shared constant
+		"sub":       grant.Subject,
+		"aud":       grant.Resource,
+		"scope":     grant.Scope,
+		"client_id": grant.ClientID,
+		"jti":       jti,
+		"iat":       time.Now().Unix(),
+		"exp":       time.Now().Add(tokenTTL).Unix(),
+	}
+	return signJWT(claims)
+}
+
+// signJWT serializes and signs claims with the current signing key.
+func signJWT(claims map[string]interface{}) (string, error) {
+	key := keys.current()
+	return signJWTWithKey(key, key.ID, claims)
 }
 
-// issueJWT creates a new JWT with the given audience and scope.
-func issueJWT(audience, scope string) (string, error) {
+// signJWTWithKey signs claims with key, announcing kid in the header.
+func signJWTWithKey(key *signingKey, kid string, claims map[string]interface{}) (string, error) {
 	header := map[string]string{
-		"alg": "RS256",
+		"alg": key.Alg,
 		"typ": "JWT",
-		"kid": keyID,
+		"kid": kid,
 	}
 	headerBytes, _ := json.Marshal(header)
 	headerEnc := base64.RawURLEncoding.EncodeToString(headerBytes)
 
-	claims := map[string]interface{}{
-		"iss":   issuerBaseURL, // Use shared constant
-		"aud":   audience,
-		"scope": scope,
-		"iat":   time.Now().Unix(),
-		"exp":   time.Now().Add(tokenTTL).Unix(),
+	claimsBytes, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode claims")
 	}
-	claimsBytes, _ := json.Marshal(claims)
 	claimsEnc := base64.RawURLEncoding.EncodeToString(claimsBytes)
 
 	signingInput := headerEnc + "." + claimsEnc
 
-	hasher := sha256.New()
-	hasher.Write([]byte(signingInput))
-	digest := hasher.Sum(nil)
-
-	sig, err := rsa.SignPKCS1v15(rand.Reader, privKey, crypto.SHA256, digest)
+	sig, err := key.sign(signingInput)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to sign token")
 	}
@@ -87,3 +123,53 @@ func issueJWT(audience, scope string) (string, error) {
 
 	return signingInput + "." + sigEnc, nil
 }
+
+// parseJWT verifies a token signed by one of the published keys and returns its claims.
+// Expired tokens are rejected.
+func parseJWT(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed token header")
+	}
+	var header map[string]string
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, errors.Wrap(err, "malformed token header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed token signature")
+	}
+
+	var key *signingKey
+	for _, k := range keys.published() {
+		if k.ID == header["kid"] && k.Alg == header["alg"] {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return nil, errors.Errorf("unknown signing key %q", header["kid"])
+	}
+	if !key.verify(parts[0]+"."+parts[1], sig) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	claimsBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed token claims")
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(claimsBytes, &claims); err != nil {
+		return nil, errors.Wrap(err, "malformed token claims")
+	}
+	exp, _ := claims["exp"].(float64)
+	if time.Now().Unix() >= int64(exp) {
+		return nil, errors.New("token expired")
+	}
+	return claims, nil
+}
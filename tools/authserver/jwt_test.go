@@ -18,8 +18,14 @@
 package main
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"math/big"
 	"strings"
 	"testing"
 	"time"
@@ -34,8 +40,12 @@ func TestInitJWT(t *testing.T) {
 	// Run the init function
 	initJWT()
 
-	// Assert that the private key was initialized
-	assert.NotNil(t, privKey, "Private key should not be nil after init")
+	// Assert that an RSA signing key was initialized
+	require.NotNil(t, keys, "Key ring should not be nil after init")
+	key := keys.current()
+	assert.Equal(t, algRS256, key.Alg)
+	privKey, ok := key.Signer.(*rsa.PrivateKey)
+	require.True(t, ok, "Default key should be an RSA key")
 	assert.NoError(t, privKey.Validate(), "Private key should be a valid key")
 }
 
@@ -72,7 +82,7 @@ func TestIssueJWT(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			tokenString, err := issueJWT(tc.audience, tc.scope)
+			tokenString, err := issueJWT(tokenGrant{ClientID: "test-client", Subject: "demo", Resource: tc.audience, Scope: tc.scope})
 
 			if tc.expectErr {
 				require.Error(t, err)
@@ -94,7 +104,7 @@ func TestIssueJWT(t *testing.T) {
 			require.NoError(t, err)
 			assert.Equal(t, "RS256", header["alg"])
 			assert.Equal(t, "JWT", header["typ"])
-			assert.Equal(t, keyID, header["kid"])
+			assert.Equal(t, keys.current().ID, header["kid"])
 
 			// 3. Decode and validate claims
 			claimsBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
@@ -106,6 +116,9 @@ func TestIssueJWT(t *testing.T) {
 			assert.Equal(t, issuerBaseURL, claims["iss"]) // Use shared constant
 			assert.Equal(t, tc.audience, claims["aud"])
 			assert.Equal(t, tc.scope, claims["scope"])
+			assert.Equal(t, "test-client", claims["client_id"])
+			assert.Equal(t, "demo", claims["sub"])
+			assert.NotEmpty(t, claims["jti"])
 
 			// Check timestamps
 			now := float64(time.Now().Unix())
@@ -127,7 +140,49 @@ func TestIssueJWT(t *testing.T) {
 	}
 }
 
-// Note: A full signature verification test would require a more complex setup
-// to parse the token and use the public key to verify the signature.
-// For the scope of this example, we are trusting the signing function works correctly
-// if it returns no error and a non-empty signature.
+func TestIssueJWTAlgorithms(t *testing.T) {
+	for _, alg := range []string{algRS256, algES256, algEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			ring, err := newKeyRing(alg, "", "")
+			require.NoError(t, err)
+			keys = ring
+
+			tokenString, err := issueJWT(tokenGrant{ClientID: "test-client", Subject: "demo", Resource: "test-audience", Scope: "read"})
+			require.NoError(t, err)
+			parts := strings.Split(tokenString, ".")
+			require.Len(t, parts, 3)
+
+			headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+			require.NoError(t, err)
+			var header map[string]string
+			require.NoError(t, json.Unmarshal(headerBytes, &header))
+			assert.Equal(t, alg, header["alg"])
+
+			sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+			require.NoError(t, err)
+			assert.True(t, verifySignature(t, ring.current().Signer.Public(), parts[0]+"."+parts[1], sig), "signature should verify with the published key")
+		})
+	}
+	initJWT()
+}
+
+// verifySignature checks a JWS signature with the given public key.
+func verifySignature(t *testing.T, pub crypto.PublicKey, signingInput string, sig []byte) bool {
+	t.Helper()
+	digest := sha256.Sum256([]byte(signingInput))
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(p, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		require.Len(t, sig, 64)
+		var r, s big.Int
+		r.SetBytes(sig[:32])
+		s.SetBytes(sig[32:])
+		return ecdsa.Verify(p, digest[:], &r, &s)
+	case ed25519.PublicKey:
+		return ed25519.Verify(p, []byte(signingInput), sig)
+	default:
+		t.Fatalf("unexpected key type %T", pub)
+		return false
+	}
+}
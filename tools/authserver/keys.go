@@ -0,0 +1,411 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	algRS256 = "RS256"
+	algES256 = "ES256"
+	algEdDSA = "EdDSA"
+
+	// retainedKeys is how many keys are published in the JWKS: the current key and the previous one.
+	retainedKeys = 2
+
+	// generatedKeyPrefix starts the name of every key file the server writes.
+	// Only those files are ever deleted from the key directory.
+	generatedKeyPrefix = "authserver-"
+)
+
+// signingKey is a private key together with the JWS metadata derived from it.
+type signingKey struct {
+	ID     string
+	Alg    string
+	Signer crypto.Signer
+	// path is the file in the key directory the server generated for the key,
+	// empty for keys it did not write.
+	path string
+}
+
+// keyRing holds the signing keys, newest first. The first key signs new tokens,
+// the remaining ones are only published so that older tokens keep validating.
+type keyRing struct {
+	mu   sync.RWMutex
+	keys []*signingKey
+	// alg is the algorithm used when new keys are generated.
+	alg string
+	// dir is where generated keys are persisted, empty for ephemeral keys.
+	dir string
+}
+
+// newKeyRing creates a key ring for the given algorithm.
+// keyFile loads a single fixed key; keyDir loads the newest keys from a directory
+// and persists newly generated keys there. Without either, an ephemeral key is generated.
+func newKeyRing(alg, keyFile, keyDir string) (*keyRing, error) {
+	if err := validateAlg(alg); err != nil {
+		return nil, err
+	}
+	ring := &keyRing{alg: alg, dir: keyDir}
+
+	switch {
+	case keyFile != "" && keyDir != "":
+		return nil, errors.New("key_file and key_dir are mutually exclusive")
+	case keyFile != "":
+		key, err := loadKeyFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys = []*signingKey{key}
+	case keyDir != "":
+		keys, err := loadKeyDir(keyDir)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys = keys
+	}
+
+	if len(ring.keys) == 0 {
+		if err := ring.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+// current returns the key used to sign new tokens.
+func (k *keyRing) current() *signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[0]
+}
+
+// published returns the keys advertised in the JWKS.
+func (k *keyRing) published() []*signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return append([]*signingKey(nil), k.keys...)
+}
+
+// rotate generates a new current key and demotes the current key to previous.
+func (k *keyRing) rotate() error {
+	signer, err := generateSigner(k.alg)
+	if err != nil {
+		return err
+	}
+	key, err := newSigningKey(signer)
+	if err != nil {
+		return err
+	}
+	if k.dir != "" {
+		key.path = filepath.Join(k.dir, generatedKeyPrefix+key.ID+".pem")
+		if err := writeKeyFile(key.path, signer); err != nil {
+			return err
+		}
+	}
+
+	k.mu.Lock()
+	k.keys = append([]*signingKey{key}, k.keys...)
+	var dropped []*signingKey
+	if len(k.keys) > retainedKeys {
+		dropped = k.keys[retainedKeys:]
+		k.keys = k.keys[:retainedKeys]
+	}
+	k.mu.Unlock()
+	log.Printf("Signing with new %s key %s", key.Alg, key.ID)
+
+	// A key that is no longer published must not come back on the next start.
+	for _, old := range dropped {
+		if old.path == "" {
+			continue
+		}
+		if err := os.Remove(old.path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove key file %s", old.path)
+		}
+	}
+	return nil
+}
+
+// rotateKeys rotates the signing key every interval until ctx is done.
+func rotateKeys(ctx context.Context, k *keyRing, interval time.Duration) {
+	if interval < tokenTTL {
+		log.Printf("key rotation interval %s is shorter than the token lifetime %s, tokens may outlive their key", interval, tokenTTL)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.rotate(); err != nil {
+				log.Printf("failed to rotate signing key: %v", err)
+			}
+		}
+	}
+}
+
+// validateAlg checks that alg is one of the supported JWS algorithms.
+func validateAlg(alg string) error {
+	switch alg {
+	case algRS256, algES256, algEdDSA:
+		return nil
+	default:
+		return errors.Errorf("unsupported signing algorithm %q, expected %s, %s or %s", alg, algRS256, algES256, algEdDSA)
+	}
+}
+
+// generateSigner creates a new private key for alg.
+func generateSigner(alg string) (crypto.Signer, error) {
+	switch alg {
+	case algRS256:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case algES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case algEdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	default:
+		return nil, validateAlg(alg)
+	}
+}
+
+// newSigningKey derives the algorithm and a stable kid (the RFC 7638 JWK thumbprint) from a private key.
+func newSigningKey(signer crypto.Signer) (*signingKey, error) {
+	pub := publicJWK(signer.Public())
+	if pub == nil {
+		return nil, errors.Errorf("unsupported key type %T", signer)
+	}
+
+	// The thumbprint covers only the required members, in lexicographic order.
+	var members []string
+	switch pub.Kty {
+	case "RSA":
+		members = []string{`"e":"` + pub.E + `"`, `"kty":"RSA"`, `"n":"` + pub.N + `"`}
+	case "EC":
+		members = []string{`"crv":"` + pub.Crv + `"`, `"kty":"EC"`, `"x":"` + pub.X + `"`, `"y":"` + pub.Y + `"`}
+	default:
+		members = []string{`"crv":"` + pub.Crv + `"`, `"kty":"OKP"`, `"x":"` + pub.X + `"`}
+	}
+	sum := sha256.Sum256([]byte("{" + strings.Join(members, ",") + "}"))
+
+	return &signingKey{
+		ID:     base64.RawURLEncoding.EncodeToString(sum[:]),
+		Alg:    pub.Alg,
+		Signer: signer,
+	}, nil
+}
+
+// publicJWK renders a public key as a JWK, or returns nil for unsupported key types.
+func publicJWK(pub crypto.PublicKey) *jwk {
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		return &jwk{
+			Kty: "RSA",
+			Alg: algRS256,
+			N:   base64.RawURLEncoding.EncodeToString(p.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		if p.Curve != elliptic.P256() {
+			return nil
+		}
+		return &jwk{
+			Kty: "EC",
+			Alg: algES256,
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(p.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(p.Y.FillBytes(make([]byte, 32))),
+		}
+	case ed25519.PublicKey:
+		return &jwk{
+			Kty: "OKP",
+			Alg: algEdDSA,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(p),
+		}
+	default:
+		return nil
+	}
+}
+
+// sign produces the JWS signature of signingInput with the key's algorithm.
+func (s *signingKey) sign(signingInput string) ([]byte, error) {
+	switch priv := s.Signer.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		return rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		// JWS uses the fixed-size R || S encoding instead of ASN.1.
+		digest := sha256.Sum256([]byte(signingInput))
+		r, sv, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		sv.FillBytes(sig[32:])
+		return sig, nil
+	case ed25519.PrivateKey:
+		return ed25519.Sign(priv, []byte(signingInput)), nil
+	default:
+		return nil, errors.Errorf("unsupported key type %T", s.Signer)
+	}
+}
+
+// verify checks a JWS signature produced by sign.
+func (s *signingKey) verify(signingInput string, sig []byte) bool {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch pub := s.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		sv := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(pub, digest[:], r, sv)
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, []byte(signingInput), sig)
+	default:
+		return false
+	}
+}
+
+// loadKeyFile reads a PEM encoded private key (PKCS#8, PKCS#1 or SEC 1).
+func loadKeyFile(path string) (*signingKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read key file %s", path)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.Errorf("no PEM data found in %s", path)
+	}
+
+	var parsed any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse key file %s", path)
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("key in %s cannot sign", path)
+	}
+	key, err := newSigningKey(signer)
+	return key, errors.Wrapf(err, "failed to load key file %s", path)
+}
+
+// loadKeyDir loads the newest *.pem keys from dir, newest first, and removes
+// older key files the server generated.
+func loadKeyDir(dir string) ([]*signingKey, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrapf(err, "failed to create key directory %s", dir)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list key directory %s", dir)
+	}
+
+	modTimes := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to stat key file %s", path)
+		}
+		modTimes[path] = info.ModTime()
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if !modTimes[paths[i]].Equal(modTimes[paths[j]]) {
+			return modTimes[paths[i]].After(modTimes[paths[j]])
+		}
+		return paths[i] > paths[j]
+	})
+	// Older generated keys are left over from an interrupted rotation; keys
+	// put there by the operator are never deleted.
+	if len(paths) > retainedKeys {
+		for _, path := range paths[retainedKeys:] {
+			if !isGeneratedKeyFile(path) {
+				log.Printf("Ignoring key file %s, only the newest %d keys in %s are used", path, retainedKeys, dir)
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				return nil, errors.Wrapf(err, "failed to remove key file %s", path)
+			}
+		}
+		paths = paths[:retainedKeys]
+	}
+
+	keys := make([]*signingKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := loadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if isGeneratedKeyFile(path) {
+			key.path = path
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// isGeneratedKeyFile reports whether the server wrote the key file at path.
+func isGeneratedKeyFile(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), generatedKeyPrefix)
+}
+
+// writeKeyFile stores a private key as a PKCS#8 PEM file readable only by the owner.
+func writeKeyFile(path string, signer crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode key")
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return errors.Wrapf(os.WriteFile(path, data, 0o600), "failed to write key file %s", path)
+}
@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRingRotation(t *testing.T) {
+	ring, err := newKeyRing(algES256, "", "")
+	require.NoError(t, err)
+	first := ring.current().ID
+
+	require.NoError(t, ring.rotate())
+	second := ring.current().ID
+	assert.NotEqual(t, first, second, "rotated keys must have distinct kids")
+
+	keys = ring
+	defer initJWT()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	handleJwks(w, req)
+
+	var set jwks
+	require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&set))
+	require.Len(t, set.Keys, 2, "JWKS should publish the current and the previous key")
+	assert.Equal(t, second, set.Keys[0].Kid)
+	assert.Equal(t, first, set.Keys[1].Kid)
+	for _, key := range set.Keys {
+		assert.Equal(t, "EC", key.Kty)
+		assert.Equal(t, "P-256", key.Crv)
+		assert.NotEmpty(t, key.X)
+		assert.NotEmpty(t, key.Y)
+		assert.Empty(t, key.N)
+	}
+
+	// Only the current and previous keys are retained
+	require.NoError(t, ring.rotate())
+	published := ring.published()
+	require.Len(t, published, 2)
+	assert.NotEqual(t, first, published[1].ID)
+}
+
+func TestKeyRingDirectoryPersistence(t *testing.T) {
+	dir := t.TempDir()
+
+	ring, err := newKeyRing(algEdDSA, "", dir)
+	require.NoError(t, err)
+	kid := ring.current().ID
+
+	// A restart loads the persisted key instead of generating a new one
+	reloaded, err := newKeyRing(algEdDSA, "", dir)
+	require.NoError(t, err)
+	assert.Equal(t, kid, reloaded.current().ID)
+	assert.Equal(t, algEdDSA, reloaded.current().Alg)
+
+	require.NoError(t, reloaded.rotate())
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	// Keys rotated out of the JWKS are removed from the directory
+	require.NoError(t, reloaded.rotate())
+	files, err = filepath.Glob(filepath.Join(dir, "*.pem"))
+	require.NoError(t, err)
+	var want []string
+	for _, key := range reloaded.published() {
+		want = append(want, filepath.Join(dir, generatedKeyPrefix+key.ID+".pem"))
+	}
+	assert.ElementsMatch(t, want, files)
+	assert.NotContains(t, files, filepath.Join(dir, generatedKeyPrefix+kid+".pem"))
+}
+
+func TestKeyDirKeepsOperatorKeys(t *testing.T) {
+	dir := t.TempDir()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	operatorPath := filepath.Join(dir, "operator.pem")
+	writePEM(t, operatorPath, "EC PRIVATE KEY", ecDER)
+	require.NoError(t, os.Chtimes(operatorPath, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+
+	// Rotating the operator's key out of the JWKS leaves its file alone
+	ring, err := newKeyRing(algEdDSA, "", dir)
+	require.NoError(t, err)
+	require.NoError(t, ring.rotate())
+	require.NoError(t, ring.rotate())
+	assert.FileExists(t, operatorPath)
+
+	// A restart skips the extra operator key but prunes an old generated one
+	stalePath := filepath.Join(dir, generatedKeyPrefix+"stale.pem")
+	writePEM(t, stalePath, "EC PRIVATE KEY", ecDER)
+	require.NoError(t, os.Chtimes(stalePath, time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour)))
+	reloaded, err := newKeyRing(algEdDSA, "", dir)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, keyIDs(ring.published()), keyIDs(reloaded.published()))
+	assert.FileExists(t, operatorPath)
+	assert.NoFileExists(t, stalePath)
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPath := filepath.Join(dir, "rsa.pem")
+	writePEM(t, rsaPath, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	ecPath := filepath.Join(dir, "ec.pem")
+	writePEM(t, ecPath, "EC PRIVATE KEY", ecDER)
+
+	key, err := loadKeyFile(rsaPath)
+	require.NoError(t, err)
+	assert.Equal(t, algRS256, key.Alg)
+
+	ring, err := newKeyRing(algRS256, ecPath, "")
+	require.NoError(t, err)
+	assert.Equal(t, algES256, ring.current().Alg, "the algorithm follows the loaded key")
+
+	_, err = newKeyRing(algRS256, ecPath, dir)
+	assert.Error(t, err)
+	_, err = newKeyRing("HS256", "", "")
+	assert.Error(t, err)
+	_, err = loadKeyFile(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
+
+func keyIDs(keys []*signingKey) []string {
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.ID
+	}
+	return ids
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
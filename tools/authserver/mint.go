@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	testMint = flag.Bool("test_mint", false, "Serve /test/mint, which signs access tokens with arbitrary claims and lifetimes (tests only, never expose it)")
+)
+
+// defaultMintResource is the audience of minted tokens unless the request overrides it.
+const defaultMintResource = "http://localhost:8888/mcp"
+
+// mintRequest describes a token to mint. Claims are merged over the defaults
+// of a regular access token; a null claim removes the default.
+type mintRequest struct {
+	Claims map[string]interface{} `json:"claims"`
+	// ExpiresIn is the lifetime in seconds. Zero means the regular token
+	// lifetime, a negative value yields a token that has already expired.
+	ExpiresIn int64 `json:"expires_in"`
+	// Kid replaces the key ID in the header without changing the signing key.
+	Kid string `json:"kid"`
+	// UnpublishedKey signs with a freshly generated key that never appears in the JWKS.
+	UnpublishedKey bool `json:"unpublished_key"`
+}
+
+// handleTestMint issues access tokens that the regular grants never would, so
+// resource servers can be tested against expired, foreign or mis-scoped tokens.
+// It is only routed when the server runs with -test_mint.
+func handleTestMint(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	var req mintRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "malformed JSON body"})
+		return
+	}
+
+	claims, err := mintClaims(req)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": err.Error()})
+		return
+	}
+
+	key := keys.current()
+	if req.UnpublishedKey {
+		signer, err := generateSigner(key.Alg)
+		if err == nil {
+			key, err = newSigningKey(signer)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": err.Error()})
+			return
+		}
+	}
+	kid := key.ID
+	if req.Kid != "" {
+		kid = req.Kid
+	}
+
+	token, err := signJWTWithKey(key, kid, claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"kid":          kid,
+		"claims":       claims,
+	})
+}
+
+// mintClaims merges the requested claims over those of a regular access token.
+func mintClaims(req mintRequest) (map[string]interface{}, error) {
+	jti, err := generateRandomString(16)
+	if err != nil {
+		return nil, err
+	}
+	lifetime := tokenTTL
+	if req.ExpiresIn != 0 {
+		lifetime = time.Duration(req.ExpiresIn) * time.Second
+	}
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":       issuerBaseURL,
+		"sub":       *testUsername,
+		"aud":       defaultMintResource,
+		"scope":     "read write",
+		"client_id": "sample-client",
+		"jti":       jti,
+		"iat":       now.Unix(),
+		"exp":       now.Add(lifetime).Unix(),
+	}
+	for name, value := range req.Claims {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims, nil
+}
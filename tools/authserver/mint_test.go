@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleTestMint(t *testing.T) {
+	initJWT()
+
+	t.Run("Defaults verify like a regular token", func(t *testing.T) {
+		status, resp := postMint(t, `{}`)
+		require.Equal(t, http.StatusOK, status)
+		claims, err := parseJWT(resp["access_token"].(string))
+		require.NoError(t, err)
+		assert.Equal(t, defaultMintResource, claims["aud"])
+		assert.Equal(t, "read write", claims["scope"])
+		assert.Equal(t, keys.current().ID, resp["kid"])
+	})
+
+	t.Run("Claims override and remove defaults", func(t *testing.T) {
+		status, resp := postMint(t, `{"claims": {"scope": "read", "aud": "http://other", "sub": null, "tenant": "acme"}}`)
+		require.Equal(t, http.StatusOK, status)
+		claims, err := parseJWT(resp["access_token"].(string))
+		require.NoError(t, err)
+		assert.Equal(t, "read", claims["scope"])
+		assert.Equal(t, "http://other", claims["aud"])
+		assert.Equal(t, "acme", claims["tenant"])
+		assert.NotContains(t, claims, "sub")
+	})
+
+	t.Run("Negative lifetime yields an expired token", func(t *testing.T) {
+		status, resp := postMint(t, `{"expires_in": -600}`)
+		require.Equal(t, http.StatusOK, status)
+		_, err := parseJWT(resp["access_token"].(string))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "expired")
+
+		exp := resp["claims"].(map[string]interface{})["exp"].(float64)
+		assert.InDelta(t, float64(time.Now().Add(-10*time.Minute).Unix()), exp, 5)
+	})
+
+	t.Run("Unknown kid", func(t *testing.T) {
+		status, resp := postMint(t, `{"kid": "unknown-kid"}`)
+		require.Equal(t, http.StatusOK, status)
+		_, err := parseJWT(resp["access_token"].(string))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown signing key")
+	})
+
+	t.Run("Unpublished key", func(t *testing.T) {
+		status, resp := postMint(t, `{"unpublished_key": true}`)
+		require.Equal(t, http.StatusOK, status)
+		assert.NotEqual(t, keys.current().ID, resp["kid"])
+		_, err := parseJWT(resp["access_token"].(string))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown signing key")
+	})
+
+	t.Run("Malformed body", func(t *testing.T) {
+		status, _ := postMint(t, `{"claims": [`)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
+
+func postMint(t *testing.T, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/test/mint", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handleTestMint(w, req)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	return w.Code, resp
+}
@@ -19,71 +19,142 @@ package main
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
-	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 )
 
 // tokenResponse defines the structure of the JSON response from the token endpoint.
 type tokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int64  `json:"expires_in"`
-	Scope       string `json:"scope,omitempty"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
 }
 
+const (
+	authCodeTTL     = 10 * time.Minute
+	refreshTokenTTL = 24 * time.Hour
+)
+
+// authorizeRequest holds the parameters of an authorization request. They are
+// carried through the consent page as hidden form fields.
+type authorizeRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Resource            string
+	Scope               string
+	State               string
+	// Nonce is the OpenID Connect nonce, echoed in the id_token.
+	Nonce string
+}
+
+// handleAuthorize shows the consent page on GET and processes the user's decision on POST.
 func handleAuthorize(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Received %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
-	// Parse query parameters
-	query := r.URL.Query()
-	clientID := query.Get("client_id")
-	redirectURI := query.Get("redirect_uri")
-	responseType := query.Get("response_type")
-	codeChallenge := query.Get("code_challenge")
-	codeChallengeMethod := query.Get("code_challenge_method")
-	resource := query.Get("resource")
-	state := query.Get("state") // Preserve state parameter
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	// Parse query parameters, or the consent form on POST
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	req := authorizeRequest{
+		ClientID:            r.Form.Get("client_id"),
+		RedirectURI:         r.Form.Get("redirect_uri"),
+		ResponseType:        r.Form.Get("response_type"),
+		CodeChallenge:       r.Form.Get("code_challenge"),
+		CodeChallengeMethod: r.Form.Get("code_challenge_method"),
+		Resource:            r.Form.Get("resource"),
+		Scope:               r.Form.Get("scope"),
+		State:               r.Form.Get("state"), // Preserve state parameter
+		Nonce:               r.Form.Get("nonce"),
+	}
 
-	// Validate client
-	client, ok := clients[clientID]
+	req, ok := validateAuthorizeRequest(w, r, req)
 	if !ok {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
 		return
 	}
-	// Validate matching redirect URI against registered redirect_uris
-	matched := false
-	for _, ru := range client.RedirectURIs {
-		if ru == redirectURI {
-			matched = true
-			break
+
+	if r.Method == http.MethodGet {
+		if *autoApprove {
+			issueAuthorizationCode(w, r, req, *testUsername)
+			return
 		}
+		renderConsent(w, http.StatusOK, req, "")
+		return
 	}
-	if !matched {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_redirect_uri"})
+
+	// The user denied access: report it to the client (RFC 6749 section 4.1.2.1)
+	if r.PostForm.Get("action") != "approve" {
+		redirectWithParams(w, r, req.RedirectURI, url.Values{"error": {"access_denied"}}, req.State)
+		return
+	}
+	username := r.PostForm.Get("username")
+	if !authenticateUser(username, r.PostForm.Get("password")) {
+		renderConsent(w, http.StatusUnauthorized, req, "Invalid username or password")
 		return
 	}
+	issueAuthorizationCode(w, r, req, username)
+}
+
+// validateAuthorizeRequest checks the client, redirect URI, PKCE and scope of an authorization request.
+// It writes the error response itself and returns the request with its scope resolved.
+func validateAuthorizeRequest(w http.ResponseWriter, r *http.Request, req authorizeRequest) (authorizeRequest, bool) {
+	// Validate client
+	client, ok := store.GetClient(req.ClientID)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+		return req, false
+	}
+	// Validate matching redirect URI against registered redirect_uris
+	if !containsString(client.RedirectURIs, req.RedirectURI) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_redirect_uri"})
+		return req, false
+	}
 
 	// Validate request parameters
-	if responseType != "code" {
+	if req.ResponseType != "code" {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_response_type"})
-		return
+		return req, false
 	}
-	if codeChallenge == "" || codeChallengeMethod != "S256" {
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "code_challenge required and must be S256"})
-		return
+		return req, false
 	}
 
-	// Require resource parameter
-	if resource == "" {
+	// Require resource parameter, except for OpenID Connect requests that only need the UserInfo endpoint
+	if req.Resource == "" && !hasScope(req.Scope, "openid") {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "resource parameter required"})
-		return
+		return req, false
 	}
 
-	// In a real server, this is where you would authenticate the user and ask for consent.
-	// For this demo, we auto-approve.
+	// The redirect URI is trusted at this point, so the remaining errors go back to the client
+	if !client.allowsGrantType("authorization_code") {
+		redirectWithParams(w, r, req.RedirectURI, url.Values{"error": {"unauthorized_client"}}, req.State)
+		return req, false
+	}
+	scope, ok := resolveScope(req.Scope, client.Scopes)
+	if !ok {
+		redirectWithParams(w, r, req.RedirectURI, url.Values{"error": {"invalid_scope"}}, req.State)
+		return req, false
+	}
+	req.Scope = scope
+	return req, true
+}
 
+// issueAuthorizationCode stores a new authorization code for an approved request and redirects back to the client.
+func issueAuthorizationCode(w http.ResponseWriter, r *http.Request, req authorizeRequest, subject string) {
 	// Generate and store authorization code
 	code, err := generateRandomString(32)
 	if err != nil {
@@ -91,22 +162,43 @@ func handleAuthorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authCodes[code] = AuthCodeInfo{
-		ClientID:            clientID,
-		RedirectURI:         redirectURI,
-		CodeChallenge:       codeChallenge,
-		CodeChallengeMethod: codeChallengeMethod,
-		Resource:            resource,
-		Expiry:              time.Now().Add(10 * time.Minute),
+	err = store.PutAuthCode(code, AuthCodeInfo{
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Resource:            req.Resource,
+		Scope:               req.Scope,
+		Subject:             subject,
+		Nonce:               req.Nonce,
+		AuthTime:            time.Now(),
+		Expiry:              time.Now().Add(authCodeTTL),
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to store authorization code"})
+		return
 	}
 
 	// Redirect back to the client
-	redirectURL := fmt.Sprintf("%s?code=%s", redirectURI, code)
+	redirectWithParams(w, r, req.RedirectURI, url.Values{"code": {code}}, req.State)
+}
+
+// redirectWithParams redirects to redirectURI with params and the state parameter appended to its query.
+func redirectWithParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values, state string) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_redirect_uri"})
+		return
+	}
+	query := target.Query()
+	for k, v := range params {
+		query[k] = v
+	}
 	if state != "" {
-		redirectURL += "&state=" + state
+		query.Set("state", state)
 	}
-
-	http.Redirect(w, r, redirectURL, http.StatusFound)
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
 }
 
 func handleToken(w http.ResponseWriter, r *http.Request) {
@@ -116,36 +208,48 @@ func handleToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate grant type
-	grantType := r.PostForm.Get("grant_type")
-	if grantType != "authorization_code" {
+	// Dispatch on grant type
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		handleAuthorizationCodeGrant(w, r)
+	case "refresh_token":
+		handleRefreshTokenGrant(w, r)
+	case "client_credentials":
+		handleClientCredentialsGrant(w, r)
+	default:
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
-		return
 	}
+}
 
-	// Validate authorization code
+// handleAuthorizationCodeGrant exchanges an authorization code for an access and refresh token.
+func handleAuthorizationCodeGrant(w http.ResponseWriter, r *http.Request) {
+	// Validate authorization code. Codes are single use, so it is consumed
+	// even if one of the checks below fails.
 	code := r.PostForm.Get("code")
-	authCode, ok := authCodes[code]
+	authCode, ok := store.TakeAuthCode(code)
 	if !ok {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
 		return
 	}
 	if time.Now().After(authCode.Expiry) {
-		delete(authCodes, code) // Clean up expired code
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "authorization code expired"})
 		return
 	}
 
 	// Validate client and redirect URI
-	clientID := r.PostForm.Get("client_id")
-	if clientID != authCode.ClientID {
+	client, ok := authenticateClient(r)
+	if !ok || client.ID != authCode.ClientID {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
 		return
 	}
+	if !client.allowsGrantType("authorization_code") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unauthorized_client"})
+		return
+	}
 
 	// Require resource parameter and verify it matches the one associated with the auth code
 	resource := r.PostForm.Get("resource")
-	if resource == "" {
+	if resource == "" && authCode.Resource != "" {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "resource parameter required"})
 		return
 	}
@@ -161,25 +265,229 @@ func handleToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// All checks passed, clean up the auth code
-	delete(authCodes, code)
+	// Start a new refresh token family for this grant, if the client uses refresh tokens
+	var familyID string
+	if client.allowsGrantType("refresh_token") {
+		var err error
+		familyID, err = generateRandomString(16)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to issue token"})
+			return
+		}
+	}
+	// OpenID Connect requests without a resource get the UserInfo endpoint as audience
+	audience := authCode.Resource
+	if audience == "" {
+		audience = userinfoEndpoint
+	}
+	writeTokenResponse(w, tokenGrant{
+		ClientID: client.ID,
+		Subject:  authCode.Subject,
+		Resource: audience,
+		Scope:    authCode.Scope,
+		Nonce:    authCode.Nonce,
+		AuthTime: authCode.AuthTime,
+	}, familyID)
+}
+
+// handleRefreshTokenGrant exchanges a refresh token for a new access token.
+// Refresh tokens are rotated on every use; presenting an already rotated token
+// is treated as token theft and revokes every token of the same family.
+func handleRefreshTokenGrant(w http.ResponseWriter, r *http.Request) {
+	client, ok := authenticateClient(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if !client.allowsGrantType("refresh_token") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unauthorized_client"})
+		return
+	}
 
-	// Issue JWT
-	accessToken, err := issueJWT(authCode.Resource, "") // Scope can be added here if needed
+	token := r.PostForm.Get("refresh_token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "refresh_token parameter required"})
+		return
+	}
+	info, ok := store.GetRefreshToken(token)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if info.Used {
+		rejectRefreshTokenReuse(w, info)
+		return
+	}
+	if time.Now().After(info.Expiry) {
+		_ = store.DeleteRefreshToken(token) // Clean up expired token
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "refresh token expired"})
+		return
+	}
+	if client.ID != info.ClientID {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "refresh token was issued to another client"})
+		return
+	}
+
+	// The resource parameter is optional on refresh but must not change the audience
+	if resource := r.PostForm.Get("resource"); resource != "" && resource != info.Resource {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "resource mismatch"})
+		return
+	}
+
+	// Rotate: keep the old token around as used so that a replay can be detected.
+	// The check is repeated atomically because a concurrent request may have won the race.
+	prev, ok, err := store.MarkRefreshTokenUsed(token)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to rotate refresh token"})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if prev.Used {
+		rejectRefreshTokenReuse(w, info)
+		return
+	}
+
+	writeTokenResponse(w, tokenGrant{
+		ClientID: client.ID,
+		Subject:  info.Subject,
+		Resource: info.Resource,
+		Scope:    info.Scope,
+		AuthTime: info.AuthTime,
+	}, info.FamilyID)
+}
+
+// rejectRefreshTokenReuse revokes the token family of a replayed refresh token.
+func rejectRefreshTokenReuse(w http.ResponseWriter, info RefreshTokenInfo) {
+	log.Printf("Refresh token reuse detected for client %s, revoking token family", info.ClientID)
+	if err := store.RevokeRefreshTokenFamily(info.FamilyID); err != nil {
+		log.Printf("failed to revoke refresh token family: %v", err)
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "refresh token already used"})
+}
+
+// handleClientCredentialsGrant issues an access token to a confidential client acting on its own behalf.
+func handleClientCredentialsGrant(w http.ResponseWriter, r *http.Request) {
+	client, ok := authenticateClient(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	// Registration guarantees that only confidential clients use client_credentials
+	if !client.allowsGrantType("client_credentials") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unauthorized_client"})
+		return
+	}
+
+	resource := r.PostForm.Get("resource")
+	if resource == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "resource parameter required"})
+		return
+	}
+
+	scope, ok := resolveScope(r.PostForm.Get("scope"), client.Scopes)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_scope"})
+		return
+	}
+
+	// No refresh token for client_credentials (RFC 6749 section 4.4.3)
+	writeTokenResponse(w, tokenGrant{
+		ClientID: client.ID,
+		Subject:  client.ID,
+		Resource: resource,
+		Scope:    scope,
+	}, "")
+}
+
+// writeTokenResponse issues an access token and, when familyID is set, a rotated refresh token.
+func writeTokenResponse(w http.ResponseWriter, grant tokenGrant, familyID string) {
+	accessToken, err := issueJWT(grant)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to issue token"})
 		return
 	}
 
-	// Return the token
 	resp := tokenResponse{
 		AccessToken: accessToken,
 		TokenType:   "Bearer",
 		ExpiresIn:   int64(tokenTTL.Seconds()),
+		Scope:       grant.Scope,
+	}
+
+	// OpenID Connect: authenticate the user to the client as well
+	if hasScope(grant.Scope, "openid") && !grant.AuthTime.IsZero() {
+		resp.IDToken, err = issueIDToken(grant)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to issue id_token"})
+			return
+		}
+	}
+
+	if familyID != "" {
+		refreshToken, err := generateRandomString(32)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to issue refresh token"})
+			return
+		}
+		err = store.PutRefreshToken(refreshToken, RefreshTokenInfo{
+			ClientID: grant.ClientID,
+			Subject:  grant.Subject,
+			Resource: grant.Resource,
+			Scope:    grant.Scope,
+			FamilyID: familyID,
+			AuthTime: grant.AuthTime,
+			Expiry:   time.Now().Add(refreshTokenTTL),
+		})
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": "failed to store refresh token"})
+			return
+		}
+		resp.RefreshToken = refreshToken
 	}
+
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// authenticateClient resolves the client making a token request.
+// Each client must use the token endpoint auth method it registered: HTTP Basic auth for
+// client_secret_basic, the client_id/client_secret form fields for client_secret_post,
+// and only the client_id form field for public clients ("none").
+func authenticateClient(r *http.Request) (ClientInfo, bool) {
+	method := "client_secret_basic"
+	clientID, clientSecret, hasBasic := r.BasicAuth()
+	if hasBasic {
+		// Basic credentials are form-encoded before being base64 encoded (RFC 6749 section 2.3.1)
+		var errID, errSecret error
+		clientID, errID = url.QueryUnescape(clientID)
+		clientSecret, errSecret = url.QueryUnescape(clientSecret)
+		if errID != nil || errSecret != nil {
+			return ClientInfo{}, false
+		}
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+		method = "client_secret_post"
+		if clientSecret == "" {
+			method = "none"
+		}
+	}
+
+	client, ok := store.GetClient(clientID)
+	if !ok || client.TokenEndpointAuthMethod != method {
+		return ClientInfo{}, false
+	}
+	if method == "none" {
+		return client, true
+	}
+	if subtle.ConstantTimeCompare([]byte(clientSecret), []byte(client.Secret)) != 1 {
+		return ClientInfo{}, false
+	}
+	return client, true
+}
+
 // validatePKCE performs the S256 PKCE challenge verification.
 func validatePKCE(challenge, verifier string) bool {
 	hasher := sha256.New()
@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// userinfoEndpoint is also the audience of access tokens issued to OpenID Connect
+// requests that do not name a resource.
+const userinfoEndpoint = issuerBaseURL + "/userinfo"
+
+// scopeClaims maps the OpenID Connect scopes to the standard claims they release (OIDC Core 5.4).
+// Custom claims of a test user that are not listed here are released with openid alone.
+var scopeClaims = map[string][]string{
+	"profile": {"name", "family_name", "given_name", "middle_name", "nickname", "preferred_username",
+		"profile", "picture", "website", "gender", "birthdate", "zoneinfo", "locale", "updated_at"},
+	"email": {"email", "email_verified"},
+}
+
+// userClaims returns the claims of a user that the granted scope releases, always including sub.
+func userClaims(username, scope string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": username}
+	u, ok := findUser(username)
+	if !ok {
+		return claims
+	}
+
+	standard := make(map[string]string)
+	for s, names := range scopeClaims {
+		for _, name := range names {
+			standard[name] = s
+		}
+	}
+	for name, value := range u.Claims {
+		if name == "sub" {
+			continue // the subject is always the username
+		}
+		if s, ok := standard[name]; !ok || hasScope(scope, s) {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+// issueIDToken creates an OpenID Connect id_token for the client that made the request.
+func issueIDToken(grant tokenGrant) (string, error) {
+	claims := userClaims(grant.Subject, grant.Scope)
+	claims["iss"] = issuerBaseURL // Use shared constant
+	claims["aud"] = grant.ClientID
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(tokenTTL).Unix()
+	claims["auth_time"] = grant.AuthTime.Unix()
+	if grant.Nonce != "" {
+		claims["nonce"] = grant.Nonce
+	}
+	return signJWT(claims)
+}
+
+// handleUserinfo implements the OpenID Connect UserInfo endpoint (OIDC Core 5.3).
+func handleUserinfo(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	claims, err := parseJWT(token)
+	if err != nil || store.IsAccessTokenRevoked(stringClaim(claims, "jti")) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+
+	scope := stringClaim(claims, "scope")
+	if !hasScope(scope, "openid") {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "insufficient_scope"})
+		return
+	}
+	writeJSON(w, http.StatusOK, userClaims(stringClaim(claims, "sub"), scope))
+}
+
+// handleOpenIDConfiguration serves the OpenID Connect discovery document.
+func handleOpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+
+	algs := make(map[string]bool)
+	for _, key := range keys.published() {
+		algs[key.Alg] = true
+	}
+	algs[keys.alg] = true
+	signingAlgs := make([]string, 0, len(algs))
+	for alg := range algs {
+		signingAlgs = append(signingAlgs, alg)
+	}
+	sort.Strings(signingAlgs)
+
+	claims := []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce"}
+	for _, s := range oidcScopes {
+		claims = append(claims, scopeClaims[s]...)
+	}
+
+	meta := authorizationServerMetadata()
+	meta["userinfo_endpoint"] = userinfoEndpoint
+	meta["subject_types_supported"] = []string{"public"}
+	meta["id_token_signing_alg_values_supported"] = signingAlgs
+	meta["claims_supported"] = claims
+	writeJSON(w, http.StatusOK, meta)
+}
+
+// stringClaim returns a string claim, or an empty string if it is missing.
+func stringClaim(claims map[string]interface{}, name string) string {
+	v, _ := claims[name].(string)
+	return v
+}
@@ -0,0 +1,160 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenIDConnectFlow(t *testing.T) {
+	initStore(newMemoryStore())
+	initJWT()
+	require.NoError(t, loadUsers("users.example.json"))
+
+	// Log in as alice and approve an authentication request without a resource
+	verifier := "oidc_verifier"
+	form := url.Values{}
+	form.Set("client_id", "sample-client")
+	form.Set("redirect_uri", "http://localhost:8081/callback")
+	form.Set("response_type", "code")
+	form.Set("code_challenge", calculateS256Challenge(verifier))
+	form.Set("code_challenge_method", "S256")
+	form.Set("scope", "openid profile read")
+	form.Set("nonce", "n-0S6_WzA2Mj")
+	form.Set("username", "alice")
+	form.Set("password", "alice")
+	form.Set("action", "approve")
+	resp := postForm(handleAuthorize, "/oauth/authorize", form, nil).Result()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	loc, err := resp.Location()
+	require.NoError(t, err)
+
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("code", loc.Query().Get("code"))
+	data.Set("client_id", "sample-client")
+	data.Set("code_verifier", verifier)
+	status, tokens := postToken(t, data, nil)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, tokens.IDToken)
+	assert.Equal(t, "openid profile read", tokens.Scope)
+
+	t.Run("id_token", func(t *testing.T) {
+		claims, err := parseJWT(tokens.IDToken)
+		require.NoError(t, err)
+		assert.Equal(t, issuerBaseURL, claims["iss"])
+		assert.Equal(t, "sample-client", claims["aud"])
+		assert.Equal(t, "alice", claims["sub"])
+		assert.Equal(t, "n-0S6_WzA2Mj", claims["nonce"])
+		assert.NotZero(t, claims["auth_time"])
+		assert.Equal(t, "Alice Liddell", claims["name"], "profile scope releases profile claims")
+		assert.Nil(t, claims["email"], "email scope was not granted")
+		assert.Equal(t, []interface{}{"admins", "developers"}, claims["groups"], "custom claims come with openid")
+	})
+
+	t.Run("Access token audience defaults to the UserInfo endpoint", func(t *testing.T) {
+		claims, err := parseJWT(tokens.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, userinfoEndpoint, claims["aud"])
+	})
+
+	t.Run("UserInfo", func(t *testing.T) {
+		w := getUserinfo(tokens.AccessToken)
+		require.Equal(t, http.StatusOK, w.Code)
+		var info map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&info))
+		assert.Equal(t, "alice", info["sub"])
+		assert.Equal(t, "Alice", info["given_name"])
+		assert.Nil(t, info["email_verified"])
+	})
+
+	t.Run("UserInfo errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, getUserinfo("garbage").Code)
+
+		apiOnly, err := issueJWT(tokenGrant{ClientID: "sample-client", Subject: "alice", Resource: "test-resource", Scope: "read"})
+		require.NoError(t, err)
+		w := getUserinfo(apiOnly)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "insufficient_scope")
+	})
+
+	t.Run("Refreshed id_token keeps auth_time but drops the nonce", func(t *testing.T) {
+		original, err := parseJWT(tokens.IDToken)
+		require.NoError(t, err)
+
+		data := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}, "client_id": {"sample-client"}}
+		status, refreshed := postToken(t, data, nil)
+		require.Equal(t, http.StatusOK, status)
+		claims, err := parseJWT(refreshed.IDToken)
+		require.NoError(t, err)
+		assert.Equal(t, original["auth_time"], claims["auth_time"])
+		assert.Nil(t, claims["nonce"])
+	})
+
+	t.Run("No id_token without openid", func(t *testing.T) {
+		require.NoError(t, store.PutAuthCode("api-code", AuthCodeInfo{
+			ClientID:      "sample-client",
+			CodeChallenge: calculateS256Challenge(verifier),
+			Resource:      "test-resource",
+			Scope:         "read",
+			Subject:       "alice",
+			AuthTime:      time.Now(),
+			Expiry:        time.Now().Add(time.Minute),
+		}))
+		data := url.Values{"grant_type": {"authorization_code"}, "code": {"api-code"}, "client_id": {"sample-client"}, "code_verifier": {verifier}, "resource": {"test-resource"}}
+		status, resp := postToken(t, data, nil)
+		require.Equal(t, http.StatusOK, status)
+		assert.Empty(t, resp.IDToken)
+	})
+}
+
+func TestHandleOpenIDConfiguration(t *testing.T) {
+	initJWT()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	w := httptest.NewRecorder()
+
+	handleOpenIDConfiguration(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var meta map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&meta))
+	assert.Equal(t, issuerBaseURL, meta["issuer"])
+	assert.Equal(t, userinfoEndpoint, meta["userinfo_endpoint"])
+	assert.Equal(t, issuerBaseURL+"/.well-known/jwks.json", meta["jwks_uri"])
+	assert.Equal(t, []interface{}{"public"}, meta["subject_types_supported"])
+	assert.Equal(t, []interface{}{"RS256"}, meta["id_token_signing_alg_values_supported"])
+	assert.Contains(t, meta["scopes_supported"], "openid")
+	assert.Contains(t, meta["claims_supported"], "email")
+}
+
+func getUserinfo(token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handleUserinfo(w, req)
+	return w
+}
@@ -0,0 +1,80 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"strings"
+)
+
+var (
+	// apiScopes are the scopes protecting the MCP resources behind Pixiu.
+	apiScopes = []string{"read", "write"}
+	// oidcScopes are the OpenID Connect scopes; they must always be requested explicitly.
+	oidcScopes = []string{"openid", "profile", "email"}
+	// supportedScopes lists every scope this server knows about. Clients are
+	// registered for a subset of these.
+	supportedScopes = append(append([]string{}, apiScopes...), oidcScopes...)
+)
+
+// parseScope splits a space-delimited scope string (RFC 6749 section 3.3).
+func parseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// resolveScope checks a requested scope against the scopes a client may use.
+// An empty request defaults to everything the client is allowed except the
+// OpenID Connect scopes, which turn a request into an authentication request.
+// The result is normalized, with duplicates removed.
+func resolveScope(requested string, allowed []string) (string, bool) {
+	if strings.TrimSpace(requested) == "" {
+		var defaults []string
+		for _, s := range allowed {
+			if !containsString(oidcScopes, s) {
+				defaults = append(defaults, s)
+			}
+		}
+		return strings.Join(defaults, " "), true
+	}
+
+	seen := make(map[string]bool)
+	var granted []string
+	for _, s := range parseScope(requested) {
+		if !containsString(allowed, s) {
+			return "", false
+		}
+		if !seen[s] {
+			seen[s] = true
+			granted = append(granted, s)
+		}
+	}
+	return strings.Join(granted, " "), true
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// hasScope reports whether the space-delimited scope contains s.
+func hasScope(scope, s string) bool {
+	return containsString(parseScope(scope), s)
+}
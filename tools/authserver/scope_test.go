@@ -0,0 +1,48 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveScope(t *testing.T) {
+	allowed := []string{"read", "write"}
+	testCases := []struct {
+		name      string
+		requested string
+		expected  string
+		ok        bool
+	}{
+		{name: "Empty defaults to allowed", requested: "", expected: "read write", ok: true},
+		{name: "Subset", requested: "write", expected: "write", ok: true},
+		{name: "Duplicates removed", requested: "read  read write", expected: "read write", ok: true},
+		{name: "Unknown scope", requested: "read admin", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scope, ok := resolveScope(tc.requested, allowed)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, scope)
+		})
+	}
+}
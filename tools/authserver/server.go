@@ -18,8 +18,11 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net/http"
+	"time"
 )
 
 const (
@@ -28,17 +31,50 @@ const (
 	issuerBaseURL = "http://localhost:9000"
 )
 
+var (
+	storeKind     = flag.String("store", "memory", "Storage backend for clients and tokens: memory or file")
+	storeFile     = flag.String("store_file", "authserver-store.json", "JSON file used by the file storage backend")
+	sweepInterval = flag.Duration("sweep_interval", time.Minute, "How often expired codes and refresh tokens are removed")
+)
+
 func main() {
+	flag.Parse()
+	if *keyRotation > 0 && *keyFile != "" {
+		log.Fatalf("key_rotation cannot be combined with a fixed key_file, use key_dir instead")
+	}
+
 	// Initialize data stores and JWT keys.
-	initStore()
+	s, err := newStore(*storeKind, *storeFile)
+	if err != nil {
+		log.Fatalf("failed to open store: %v", err)
+	}
+	initStore(s)
+	if *usersFile != "" {
+		if err := loadUsers(*usersFile); err != nil {
+			log.Fatalf("failed to load test users: %v", err)
+		}
+	}
 	initJWT()
+	go sweepExpired(context.Background(), s, *sweepInterval)
+	if *keyRotation > 0 {
+		go rotateKeys(context.Background(), keys, *keyRotation)
+	}
 
 	// Setup HTTP routes.
 	http.HandleFunc("/register", handleDynamicClientRegistration)
+	http.HandleFunc("/register/", handleClientConfiguration)
 	http.HandleFunc("/.well-known/oauth-authorization-server", handleMetadata)
+	http.HandleFunc("/.well-known/openid-configuration", handleOpenIDConfiguration)
 	http.HandleFunc("/.well-known/jwks.json", handleJwks)
 	http.HandleFunc("/oauth/authorize", handleAuthorize)
 	http.HandleFunc("/oauth/token", handleToken)
+	http.HandleFunc("/oauth/introspect", handleIntrospect)
+	http.HandleFunc("/oauth/revoke", handleRevoke)
+	http.HandleFunc("/userinfo", handleUserinfo)
+	if *testMint {
+		log.Printf("WARNING: /test/mint is enabled and signs arbitrary tokens, use it for tests only")
+		http.HandleFunc("/test/mint", handleTestMint)
+	}
 
 	log.Printf("OAuth Authorization Server listening on %s", listenAddr)
 
@@ -18,19 +18,46 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
-// ClientInfo holds the static information about a client.
-// For this demo, we are hardcoding the clients.
+import (
+	"github.com/pkg/errors"
+)
+
+// ClientInfo holds the information about a registered client.
 type ClientInfo struct {
-	ID           string
-	Secret       string // Not used in public clients, but good practice to have.
+	ID   string
+	Name string
+	// Secret authenticates confidential clients at the token endpoint; empty for public clients.
+	Secret       string
 	RedirectURIs []string
-	// token endpoint auth method (e.g. "none" for public clients)
+	// token endpoint auth method: "none" for public clients,
+	// "client_secret_basic" or "client_secret_post" for confidential ones
 	TokenEndpointAuthMethod string
+	GrantTypes              []string
+	ResponseTypes           []string
+	// RegistrationAccessTokenHash is the SHA-256 of the RFC 7592 registration access token.
+	RegistrationAccessTokenHash string
 	// client_id_issued_at (unix seconds)
 	ClientIDIssuedAt int64
+	// Scopes the client may request.
+	Scopes []string
+}
+
+// allowsGrantType reports whether the client registered for the grant type.
+// Clients stored without grant types get the RFC 7591 default.
+func (c ClientInfo) allowsGrantType(grantType string) bool {
+	if len(c.GrantTypes) == 0 {
+		return containsString(defaultGrantTypes, grantType)
+	}
+	return containsString(c.GrantTypes, grantType)
 }
 
 // AuthCodeInfo holds the information associated with an authorization code.
@@ -40,24 +67,386 @@ type AuthCodeInfo struct {
 	CodeChallenge       string
 	CodeChallengeMethod string
 	Resource            string
-	Expiry              time.Time
+	// Scope is the space-delimited scope the user granted on the consent page.
+	Scope string
+	// Subject is the user who approved the request.
+	Subject string
+	// Nonce and AuthTime end up in the id_token of OpenID Connect requests.
+	Nonce    string
+	AuthTime time.Time
+	Expiry   time.Time
 }
 
-var (
-	// clients stores the registered clients in memory.
-	clients = make(map[string]ClientInfo)
-	// authCodes stores the authorization codes in memory.
-	authCodes = make(map[string]AuthCodeInfo)
-)
+// RefreshTokenInfo holds the information associated with a refresh token.
+// Tokens issued from the same authorization grant share a FamilyID so that
+// reuse of a rotated token can revoke the whole chain.
+type RefreshTokenInfo struct {
+	ClientID string
+	Subject  string
+	Resource string
+	Scope    string
+	FamilyID string
+	// AuthTime is when the user originally authenticated, repeated in refreshed id_tokens.
+	AuthTime time.Time
+	Expiry   time.Time
+	// Used is set once the token has been exchanged and rotated.
+	Used bool
+}
+
+// Store persists clients, authorization codes and refresh tokens.
+// Implementations must be safe for concurrent use by the HTTP handlers.
+type Store interface {
+	GetClient(id string) (ClientInfo, bool)
+	PutClient(client ClientInfo) error
+	DeleteClient(id string) error
+
+	PutAuthCode(code string, info AuthCodeInfo) error
+	// TakeAuthCode returns and removes the code so that it can be redeemed at most once.
+	TakeAuthCode(code string) (AuthCodeInfo, bool)
+
+	GetRefreshToken(token string) (RefreshTokenInfo, bool)
+	PutRefreshToken(token string, info RefreshTokenInfo) error
+	// MarkRefreshTokenUsed flags the token as used and returns its previous state,
+	// so callers can tell a first use from a replay.
+	MarkRefreshTokenUsed(token string) (RefreshTokenInfo, bool, error)
+	DeleteRefreshToken(token string) error
+	// RevokeRefreshTokenFamily removes every refresh token that belongs to the given family.
+	RevokeRefreshTokenFamily(familyID string) error
+
+	// RevokeAccessToken adds an access token ID to the revocation list until the token expires.
+	RevokeAccessToken(jti string, expiry time.Time) error
+	IsAccessTokenRevoked(jti string) bool
+
+	// DeleteExpired removes authorization codes, refresh tokens and revocation
+	// entries that expired before now.
+	DeleteExpired(now time.Time) error
+}
+
+// store is the backend used by the HTTP handlers.
+var store Store
+
+// initStore installs the given backend and seeds it with the sample clients.
+func initStore(s Store) {
+	store = s
 
-// initStore initializes the in-memory data store.
-func initStore() {
 	// Initialize with a sample client for tests and local demos.
-	clients["sample-client"] = ClientInfo{
-		ID:                      "sample-client",
-		Secret:                  "secret",
-		RedirectURIs:            []string{"http://localhost:8081/callback"},
-		TokenEndpointAuthMethod: "none",
-		ClientIDIssuedAt:        time.Now().Unix(),
+	seed := []ClientInfo{
+		{
+			ID:                      "sample-client",
+			RedirectURIs:            []string{"http://localhost:8081/callback"},
+			TokenEndpointAuthMethod: "none",
+			GrantTypes:              []string{"authorization_code", "refresh_token"},
+			ResponseTypes:           []string{"code"},
+			ClientIDIssuedAt:        time.Now().Unix(),
+			Scopes:                  supportedScopes,
+		},
+		// Confidential client for machine-to-machine (client_credentials) demos.
+		{
+			ID:                      "sample-service",
+			Secret:                  "service-secret",
+			TokenEndpointAuthMethod: "client_secret_basic",
+			GrantTypes:              []string{"client_credentials"},
+			ClientIDIssuedAt:        time.Now().Unix(),
+			Scopes:                  apiScopes,
+		},
+	}
+	for _, client := range seed {
+		if _, ok := store.GetClient(client.ID); ok {
+			continue // keep whatever was persisted across restarts
+		}
+		if err := store.PutClient(client); err != nil {
+			log.Fatalf("failed to seed client %s: %v", client.ID, err)
+		}
+	}
+}
+
+// newStore creates the backend selected by the -store flag.
+func newStore(kind, path string) (Store, error) {
+	switch kind {
+	case "memory":
+		return newMemoryStore(), nil
+	case "file":
+		return newFileStore(path)
+	default:
+		return nil, errors.Errorf("unknown store %q, expected memory or file", kind)
+	}
+}
+
+// sweepExpired periodically removes expired codes, refresh tokens and revocation entries until ctx is done.
+func sweepExpired(ctx context.Context, s Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := s.DeleteExpired(now); err != nil {
+				log.Printf("failed to sweep expired entries: %v", err)
+			}
+		}
+	}
+}
+
+// storeData is the state held by a store, also used as the on-disk JSON layout.
+type storeData struct {
+	Clients       map[string]ClientInfo       `json:"clients"`
+	AuthCodes     map[string]AuthCodeInfo     `json:"auth_codes"`
+	RefreshTokens map[string]RefreshTokenInfo `json:"refresh_tokens"`
+	// RevokedTokens maps revoked access token IDs to the expiry of the token.
+	RevokedTokens map[string]time.Time `json:"revoked_tokens"`
+}
+
+// memoryStore keeps everything in process memory guarded by a mutex.
+type memoryStore struct {
+	mu   sync.RWMutex
+	data storeData
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{data: storeData{
+		Clients:       make(map[string]ClientInfo),
+		AuthCodes:     make(map[string]AuthCodeInfo),
+		RefreshTokens: make(map[string]RefreshTokenInfo),
+		RevokedTokens: make(map[string]time.Time),
+	}}
+}
+
+func (m *memoryStore) GetClient(id string) (ClientInfo, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	client, ok := m.data.Clients[id]
+	return client, ok
+}
+
+func (m *memoryStore) PutClient(client ClientInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data.Clients[client.ID] = client
+	return nil
+}
+
+func (m *memoryStore) DeleteClient(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data.Clients, id)
+	return nil
+}
+
+func (m *memoryStore) PutAuthCode(code string, info AuthCodeInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data.AuthCodes[code] = info
+	return nil
+}
+
+func (m *memoryStore) TakeAuthCode(code string) (AuthCodeInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, ok := m.data.AuthCodes[code]
+	delete(m.data.AuthCodes, code)
+	return info, ok
+}
+
+func (m *memoryStore) GetRefreshToken(token string) (RefreshTokenInfo, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	info, ok := m.data.RefreshTokens[token]
+	return info, ok
+}
+
+func (m *memoryStore) PutRefreshToken(token string, info RefreshTokenInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data.RefreshTokens[token] = info
+	return nil
+}
+
+func (m *memoryStore) MarkRefreshTokenUsed(token string) (RefreshTokenInfo, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, ok := m.data.RefreshTokens[token]
+	if !ok {
+		return RefreshTokenInfo{}, false, nil
+	}
+	updated := info
+	updated.Used = true
+	m.data.RefreshTokens[token] = updated
+	return info, true, nil
+}
+
+func (m *memoryStore) DeleteRefreshToken(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data.RefreshTokens, token)
+	return nil
+}
+
+func (m *memoryStore) RevokeRefreshTokenFamily(familyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for token, info := range m.data.RefreshTokens {
+		if info.FamilyID == familyID {
+			delete(m.data.RefreshTokens, token)
+		}
 	}
+	return nil
+}
+
+func (m *memoryStore) RevokeAccessToken(jti string, expiry time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data.RevokedTokens[jti] = expiry
+	return nil
+}
+
+func (m *memoryStore) IsAccessTokenRevoked(jti string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.data.RevokedTokens[jti]
+	return ok
+}
+
+func (m *memoryStore) DeleteExpired(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for code, info := range m.data.AuthCodes {
+		if now.After(info.Expiry) {
+			delete(m.data.AuthCodes, code)
+		}
+	}
+	for token, info := range m.data.RefreshTokens {
+		if now.After(info.Expiry) {
+			delete(m.data.RefreshTokens, token)
+		}
+	}
+	// An expired token is rejected anyway, so it no longer needs a revocation entry.
+	for jti, expiry := range m.data.RevokedTokens {
+		if now.After(expiry) {
+			delete(m.data.RevokedTokens, jti)
+		}
+	}
+	return nil
+}
+
+// fileStore is a memoryStore that writes a JSON snapshot to disk after every change,
+// so registered clients and outstanding tokens survive a restart.
+type fileStore struct {
+	*memoryStore
+	path string
+	// saveMu serializes snapshots so that an older state never overwrites a newer one.
+	saveMu sync.Mutex
+}
+
+func newFileStore(path string) (*fileStore, error) {
+	f := &fileStore{memoryStore: newMemoryStore(), path: path}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read store file %s", path)
+	}
+	if err := json.Unmarshal(raw, &f.data); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse store file %s", path)
+	}
+	// Older or hand-written files may omit some sections.
+	if f.data.Clients == nil {
+		f.data.Clients = make(map[string]ClientInfo)
+	}
+	if f.data.AuthCodes == nil {
+		f.data.AuthCodes = make(map[string]AuthCodeInfo)
+	}
+	if f.data.RefreshTokens == nil {
+		f.data.RefreshTokens = make(map[string]RefreshTokenInfo)
+	}
+	if f.data.RevokedTokens == nil {
+		f.data.RevokedTokens = make(map[string]time.Time)
+	}
+	return f, nil
+}
+
+// save writes the current state to a temporary file and renames it into place.
+func (f *fileStore) save() error {
+	f.saveMu.Lock()
+	defer f.saveMu.Unlock()
+
+	f.mu.RLock()
+	raw, err := json.MarshalIndent(f.data, "", "  ")
+	f.mu.RUnlock()
+	if err != nil {
+		return errors.Wrap(err, "failed to encode store")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary store file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write store file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write store file")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), f.path), "failed to replace store file")
+}
+
+func (f *fileStore) PutClient(client ClientInfo) error {
+	_ = f.memoryStore.PutClient(client)
+	return f.save()
+}
+
+func (f *fileStore) DeleteClient(id string) error {
+	_ = f.memoryStore.DeleteClient(id)
+	return f.save()
+}
+
+func (f *fileStore) PutAuthCode(code string, info AuthCodeInfo) error {
+	_ = f.memoryStore.PutAuthCode(code, info)
+	return f.save()
+}
+
+func (f *fileStore) TakeAuthCode(code string) (AuthCodeInfo, bool) {
+	info, ok := f.memoryStore.TakeAuthCode(code)
+	if ok {
+		if err := f.save(); err != nil {
+			log.Printf("failed to persist store: %v", err)
+		}
+	}
+	return info, ok
+}
+
+func (f *fileStore) PutRefreshToken(token string, info RefreshTokenInfo) error {
+	_ = f.memoryStore.PutRefreshToken(token, info)
+	return f.save()
+}
+
+func (f *fileStore) MarkRefreshTokenUsed(token string) (RefreshTokenInfo, bool, error) {
+	info, ok, _ := f.memoryStore.MarkRefreshTokenUsed(token)
+	if !ok {
+		return info, false, nil
+	}
+	return info, true, f.save()
+}
+
+func (f *fileStore) DeleteRefreshToken(token string) error {
+	_ = f.memoryStore.DeleteRefreshToken(token)
+	return f.save()
+}
+
+func (f *fileStore) RevokeRefreshTokenFamily(familyID string) error {
+	_ = f.memoryStore.RevokeRefreshTokenFamily(familyID)
+	return f.save()
+}
+
+func (f *fileStore) RevokeAccessToken(jti string, expiry time.Time) error {
+	_ = f.memoryStore.RevokeAccessToken(jti, expiry)
+	return f.save()
+}
+
+func (f *fileStore) DeleteExpired(now time.Time) error {
+	_ = f.memoryStore.DeleteExpired(now)
+	return f.save()
 }
@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreConcurrentRedemption(t *testing.T) {
+	s := newMemoryStore()
+	require.NoError(t, s.PutAuthCode("code", AuthCodeInfo{ClientID: "c", Expiry: time.Now().Add(time.Minute)}))
+	require.NoError(t, s.PutRefreshToken("token", RefreshTokenInfo{ClientID: "c", Expiry: time.Now().Add(time.Minute)}))
+
+	var codeWins, tokenWins int32
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := s.TakeAuthCode("code"); ok {
+				atomic.AddInt32(&codeWins, 1)
+			}
+			if prev, ok, _ := s.MarkRefreshTokenUsed("token"); ok && !prev.Used {
+				atomic.AddInt32(&tokenWins, 1)
+			}
+			_ = s.PutClient(ClientInfo{ID: "client"})
+			s.GetClient("client")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), codeWins, "an authorization code must be redeemed exactly once")
+	assert.Equal(t, int32(1), tokenWins, "a refresh token must be rotated exactly once")
+}
+
+func TestMemoryStoreDeleteExpired(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Now()
+	require.NoError(t, s.PutAuthCode("live", AuthCodeInfo{Expiry: now.Add(time.Minute)}))
+	require.NoError(t, s.PutAuthCode("stale", AuthCodeInfo{Expiry: now.Add(-time.Minute)}))
+	require.NoError(t, s.PutRefreshToken("live", RefreshTokenInfo{Expiry: now.Add(time.Minute)}))
+	require.NoError(t, s.PutRefreshToken("stale", RefreshTokenInfo{Expiry: now.Add(-time.Minute)}))
+
+	require.NoError(t, s.DeleteExpired(now))
+
+	_, ok := s.TakeAuthCode("stale")
+	assert.False(t, ok)
+	_, ok = s.TakeAuthCode("live")
+	assert.True(t, ok)
+	_, ok = s.GetRefreshToken("stale")
+	assert.False(t, ok)
+	_, ok = s.GetRefreshToken("live")
+	assert.True(t, ok)
+}
+
+func TestSweepExpired(t *testing.T) {
+	s := newMemoryStore()
+	require.NoError(t, s.PutAuthCode("stale", AuthCodeInfo{Expiry: time.Now().Add(-time.Minute)}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sweepExpired(ctx, s, 10*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return len(s.data.AuthCodes) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFileStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	s, err := newFileStore(path)
+	require.NoError(t, err)
+	require.NoError(t, s.PutClient(ClientInfo{ID: "dynamic-client", RedirectURIs: []string{"http://localhost/cb"}}))
+	require.NoError(t, s.PutRefreshToken("token", RefreshTokenInfo{ClientID: "dynamic-client", FamilyID: "f", Expiry: time.Now().Add(time.Hour)}))
+	_, _, err = s.MarkRefreshTokenUsed("token")
+	require.NoError(t, err)
+
+	// Reopen the same file as a restarted server would
+	reopened, err := newFileStore(path)
+	require.NoError(t, err)
+
+	client, ok := reopened.GetClient("dynamic-client")
+	require.True(t, ok, "registered client should survive a restart")
+	assert.Equal(t, []string{"http://localhost/cb"}, client.RedirectURIs)
+
+	info, ok := reopened.GetRefreshToken("token")
+	require.True(t, ok)
+	assert.True(t, info.Used, "rotation state should survive a restart")
+
+	require.NoError(t, reopened.RevokeRefreshTokenFamily("f"))
+	again, err := newFileStore(path)
+	require.NoError(t, err)
+	_, ok = again.GetRefreshToken("token")
+	assert.False(t, ok)
+}
+
+func TestNewStore(t *testing.T) {
+	s, err := newStore("memory", "")
+	require.NoError(t, err)
+	assert.IsType(t, &memoryStore{}, s)
+
+	s, err = newStore("file", filepath.Join(t.TempDir(), "store.json"))
+	require.NoError(t, err)
+	assert.IsType(t, &fileStore{}, s)
+
+	_, err = newStore("bolt", "")
+	assert.Error(t, err)
+}
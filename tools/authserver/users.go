@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"os"
+	"sync"
+)
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	testUsername = flag.String("test_user", "demo", "Username of the default test user who logs in on the consent page")
+	testPassword = flag.String("test_password", "demo", "Password of the default test user who logs in on the consent page")
+	usersFile    = flag.String("users_file", "", "JSON file with additional test users and their OpenID Connect claims")
+)
+
+// testUser is an end user who can log in on the consent page.
+// Claims are returned in id_tokens and by /userinfo according to the granted scopes.
+type testUser struct {
+	Username string                 `json:"username"`
+	Password string                 `json:"password"`
+	Claims   map[string]interface{} `json:"claims"`
+}
+
+var (
+	usersMu sync.RWMutex
+	// users holds the test users loaded from the users file, by username.
+	users = make(map[string]testUser)
+)
+
+// loadUsers reads test users from a JSON array in path.
+func loadUsers(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read users file %s", path)
+	}
+	var list []testUser
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return errors.Wrapf(err, "failed to parse users file %s", path)
+	}
+
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	for _, u := range list {
+		if u.Username == "" {
+			return errors.Errorf("users file %s contains a user without username", path)
+		}
+		users[u.Username] = u
+	}
+	return nil
+}
+
+// findUser looks up a test user. The default user configured by -test_user always exists.
+func findUser(username string) (testUser, bool) {
+	usersMu.RLock()
+	u, ok := users[username]
+	usersMu.RUnlock()
+	if ok {
+		return u, true
+	}
+	if username != *testUsername {
+		return testUser{}, false
+	}
+	return testUser{
+		Username: *testUsername,
+		Password: *testPassword,
+		Claims: map[string]interface{}{
+			"name":               "Demo User",
+			"preferred_username": *testUsername,
+			"email":              *testUsername + "@example.com",
+			"email_verified":     true,
+		},
+	}, true
+}
+
+// authenticateUser checks the credentials entered on the consent page.
+func authenticateUser(username, password string) bool {
+	u, ok := findUser(username)
+	return ok && subtle.ConstantTimeCompare([]byte(password), []byte(u.Password)) == 1
+}
@@ -18,9 +18,7 @@
 package main
 
 import (
-	"encoding/base64"
 	"log"
-	"math/big"
 	"net/http"
 )
 
@@ -30,42 +28,55 @@ type jwks struct {
 }
 
 // jwk represents a single JSON Web Key.
+// N and E are set for RSA keys, Crv, X and Y for EC and OKP keys.
 type jwk struct {
 	Kty string `json:"kty"`
 	Kid string `json:"kid"`
 	Use string `json:"use"`
 	Alg string `json:"alg"`
-	N   string `json:"n"`
-	E   string `json:"e"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
 }
 
 func handleMetadata(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Received %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, authorizationServerMetadata())
+}
+
+// authorizationServerMetadata returns the RFC 8414 metadata, which the OpenID Connect discovery document extends.
+func authorizationServerMetadata() map[string]interface{} {
 	issuer := issuerBaseURL // Use shared constant
 	meta := map[string]interface{}{
-		"issuer":                                issuer,
-		"authorization_endpoint":                issuer + "/oauth/authorize",
-		"token_endpoint":                        issuer + "/oauth/token",
-		"jwks_uri":                              issuer + "/.well-known/jwks.json",
-		"registration_endpoint":                 issuer + "/register",
-		"grant_types_supported":                 []string{"authorization_code"},
-		"response_types_supported":              []string{"code"},
-		"token_endpoint_auth_methods_supported": []string{"none"}, // PKCE does not require a client secret
-		"code_challenge_methods_supported":      []string{"S256"},
+		"issuer":                                        issuer,
+		"authorization_endpoint":                        issuer + "/oauth/authorize",
+		"token_endpoint":                                issuer + "/oauth/token",
+		"jwks_uri":                                      issuer + "/.well-known/jwks.json",
+		"registration_endpoint":                         issuer + "/register",
+		"introspection_endpoint":                        issuer + "/oauth/introspect",
+		"revocation_endpoint":                           issuer + "/oauth/revoke",
+		"grant_types_supported":                         supportedGrantTypes,
+		"response_types_supported":                      []string{"code"},
+		"token_endpoint_auth_methods_supported":         supportedAuthMethods, // PKCE clients use "none"
+		"scopes_supported":                              supportedScopes,
+		"code_challenge_methods_supported":              []string{"S256"},
+		"introspection_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"revocation_endpoint_auth_methods_supported":    supportedAuthMethods,
 	}
-	writeJSON(w, http.StatusOK, meta)
+	return meta
 }
 
 func handleJwks(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Received %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
-	pubKey := privKey.PublicKey
-	key := jwk{
-		Kty: "RSA",
-		Kid: keyID,
-		Use: "sig",
-		Alg: "RS256",
-		N:   base64.RawURLEncoding.EncodeToString(pubKey.N.Bytes()),
-		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pubKey.E)).Bytes()),
+	// Publish the current key and the previous one so tokens survive a rotation.
+	set := jwks{Keys: []jwk{}}
+	for _, key := range keys.published() {
+		pub := publicJWK(key.Signer.Public())
+		pub.Kid = key.ID
+		pub.Use = "sig"
+		set.Keys = append(set.Keys, *pub)
 	}
-	writeJSON(w, http.StatusOK, jwks{Keys: []jwk{key}})
+	writeJSON(w, http.StatusOK, set)
 }
@@ -0,0 +1,333 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// tool is one entry of the dgp.filter.mcp.mcpserver tools block.
+type tool struct {
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description"`
+	Cluster     string  `yaml:"cluster"`
+	Request     request `yaml:"request"`
+	Args        []arg   `yaml:"args"`
+}
+
+type request struct {
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Timeout string            `yaml:"timeout"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+type arg struct {
+	Name        string        `yaml:"name"`
+	Type        string        `yaml:"type"`
+	In          string        `yaml:"in"`
+	Description string        `yaml:"description"`
+	Required    bool          `yaml:"required"`
+	Default     interface{}   `yaml:"default"`
+	Enum        []interface{} `yaml:"enum"`
+}
+
+// issue is something of an operation the tools config cannot express. A
+// skipped operation produces no tool at all; otherwise the tool is emitted
+// with the detail lost.
+type issue struct {
+	Operation string
+	Message   string
+	Skipped   bool
+}
+
+func (i issue) String() string {
+	if i.Skipped {
+		return i.Operation + ": skipped: " + i.Message
+	}
+	return i.Operation + ": " + i.Message
+}
+
+// converter turns the operations of a document into tools.
+type converter struct {
+	doc     *document
+	cluster string
+	timeout string
+
+	issues []issue
+}
+
+var (
+	toolName  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+	nonIdent  = regexp.MustCompile(`[^A-Za-z0-9]+`)
+	argTypes  = []string{"string", "integer", "number", "boolean", "array", "object"}
+	argPlaces = []string{"path", "query", "header"}
+)
+
+// convert maps every operation of the document to a tool. Operations that
+// cannot be represented are left out and reported in c.issues.
+func (c *converter) convert() []tool {
+	var tools []tool
+	names := map[string]string{}
+	for _, po := range c.doc.Paths {
+		id := strings.ToUpper(po.Method) + " " + po.Path
+		t, err := c.tool(po, id)
+		if err != nil {
+			c.issues = append(c.issues, issue{Operation: id, Message: err.Error(), Skipped: true})
+			continue
+		}
+		if other, ok := names[t.Name]; ok {
+			c.issues = append(c.issues, issue{Operation: id, Message: fmt.Sprintf("tool name %q is already used by %s", t.Name, other), Skipped: true})
+			continue
+		}
+		names[t.Name] = id
+		tools = append(tools, t)
+	}
+	return tools
+}
+
+func (c *converter) tool(po pathOperation, id string) (tool, error) {
+	op := po.Operation
+	t := tool{
+		Name:        operationToolName(po),
+		Description: strings.TrimSpace(op.Description),
+		Cluster:     c.cluster,
+		Request: request{
+			Method:  strings.ToUpper(po.Method),
+			Path:    po.Path,
+			Timeout: c.timeout,
+		},
+	}
+	if t.Description == "" {
+		t.Description = strings.TrimSpace(op.Summary)
+	}
+	if t.Description == "" {
+		c.note(id, "no summary or description, the tool has no description")
+	}
+
+	params, err := c.parameters(po)
+	if err != nil {
+		return tool{}, err
+	}
+	seen := map[string]string{}
+	for _, p := range params {
+		if p.In == "cookie" {
+			return tool{}, fmt.Errorf("cookie parameter %q cannot be passed by a tool", p.Name)
+		}
+		if p.Schema == nil {
+			return tool{}, fmt.Errorf("parameter %q uses content instead of schema", p.Name)
+		}
+		a, err := c.arg(id, p.Name, p.In, p.Description, p.Required || p.In == "path", p.Schema)
+		if err != nil {
+			return tool{}, fmt.Errorf("parameter %q: %w", p.Name, err)
+		}
+		seen[a.Name] = a.In
+		t.Args = append(t.Args, a)
+	}
+
+	if op.RequestBody != nil {
+		body, err := c.doc.requestBody(op.RequestBody)
+		if err != nil {
+			return tool{}, fmt.Errorf("request body: %w", err)
+		}
+		contentType, media, ok := jsonContent(body.Content)
+		if !ok {
+			return tool{}, fmt.Errorf("request body has no JSON content (%s)", strings.Join(contentTypes(body.Content), ", "))
+		}
+		args, err := c.bodyArgs(id, body, media)
+		if err != nil {
+			return tool{}, fmt.Errorf("request body: %w", err)
+		}
+		for _, a := range args {
+			if in, ok := seen[a.Name]; ok {
+				return tool{}, fmt.Errorf("body property %q clashes with the %s parameter of the same name", a.Name, in)
+			}
+			seen[a.Name] = a.In
+		}
+		t.Args = append(t.Args, args...)
+		t.Request.Headers = map[string]string{"Content-Type": contentType}
+	}
+	return t, nil
+}
+
+// parameters merges the path level parameters with the operation's own,
+// which override those with the same name and location.
+func (c *converter) parameters(po pathOperation) ([]*parameter, error) {
+	var params []*parameter
+	index := map[string]int{}
+	for _, list := range [][]parameter{po.Parameters, po.Operation.Parameters} {
+		for _, raw := range list {
+			p, err := c.doc.parameter(raw)
+			if err != nil {
+				return nil, err
+			}
+			if p.Schema, err = c.doc.schema(p.Schema); err != nil {
+				return nil, fmt.Errorf("parameter %q: %w", p.Name, err)
+			}
+			key := p.In + ":" + p.Name
+			if i, ok := index[key]; ok {
+				params[i] = p
+				continue
+			}
+			index[key] = len(params)
+			params = append(params, p)
+		}
+	}
+	return params, nil
+}
+
+// bodyArgs spreads the properties of a JSON object body into body args.
+func (c *converter) bodyArgs(id string, body *requestBody, media mediaType) ([]arg, error) {
+	s, err := c.doc.schema(media.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, fmt.Errorf("no schema")
+	}
+	if len(s.AllOf) > 0 || len(s.OneOf) > 0 || len(s.AnyOf) > 0 {
+		return nil, fmt.Errorf("composed schemas (allOf, oneOf, anyOf) cannot be spread into args")
+	}
+	if s.Type != "" && s.Type != "object" {
+		return nil, fmt.Errorf("only an object body can be spread into args, not %s", s.Type)
+	}
+	props, err := c.doc.properties(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(props) == 0 {
+		return nil, fmt.Errorf("object without properties cannot be spread into args")
+	}
+	var args []arg
+	for _, prop := range props {
+		required := body.Required && containsString(s.Required, prop.Name)
+		a, err := c.arg(id, prop.Name, "body", prop.Schema.Description, required, prop.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("property %q: %w", prop.Name, err)
+		}
+		args = append(args, a)
+	}
+	return args, nil
+}
+
+func (c *converter) arg(id string, name string, in string, description string, required bool, s *schema) (arg, error) {
+	if in != "body" && !containsString(argPlaces, in) {
+		return arg{}, fmt.Errorf("unsupported location %q", in)
+	}
+	if len(s.AllOf) > 0 || len(s.OneOf) > 0 || len(s.AnyOf) > 0 {
+		return arg{}, fmt.Errorf("composed schemas (allOf, oneOf, anyOf) have no arg type")
+	}
+	a := arg{
+		Name:        name,
+		Type:        string(s.Type),
+		In:          in,
+		Description: strings.TrimSpace(description),
+		Required:    required,
+		Default:     s.Default,
+		Enum:        s.Enum,
+	}
+	if a.Description == "" {
+		a.Description = strings.TrimSpace(s.Description)
+	}
+	switch {
+	case a.Type == "" && len(s.Properties.Content) > 0:
+		a.Type = "object"
+	case a.Type == "" && s.Items != nil:
+		a.Type = "array"
+	case a.Type == "":
+		a.Type = "string"
+		c.note(id, fmt.Sprintf("%s %q has no type, assuming string", in, name))
+	case !containsString(argTypes, a.Type):
+		return arg{}, fmt.Errorf("unsupported type %q", a.Type)
+	}
+	if a.Type == "array" || a.Type == "object" {
+		c.note(id, fmt.Sprintf("%s %q is passed as a plain %s, its nested schema is not carried over", in, name, a.Type))
+	}
+	return a, nil
+}
+
+func (c *converter) note(id string, message string) {
+	c.issues = append(c.issues, issue{Operation: id, Message: message})
+}
+
+// operationToolName prefers a summary that already is a tool name, as in the
+// specs imported by Nacos, then the operationId and finally method and path.
+func operationToolName(po pathOperation) string {
+	if summary := strings.TrimSpace(po.Operation.Summary); toolName.MatchString(summary) {
+		return summary
+	}
+	if po.Operation.OperationID != "" {
+		return snakeCase(po.Operation.OperationID)
+	}
+	return strings.Trim(strings.ToLower(po.Method+"_"+nonIdent.ReplaceAllString(po.Path, "_")), "_")
+}
+
+// snakeCase turns getUserById or get-user-by-id into get_user_by_id.
+func snakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			r = '_'
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return strings.Trim(nonIdent.ReplaceAllString(b.String(), "_"), "_")
+}
+
+// jsonContent picks application/json, or else another JSON media type, from a body's content.
+func jsonContent(content map[string]mediaType) (string, mediaType, bool) {
+	if media, ok := content["application/json"]; ok {
+		return "application/json", media, true
+	}
+	for _, contentType := range contentTypes(content) {
+		base := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+		if base == "application/json" || strings.HasSuffix(base, "+json") {
+			return contentType, content[contentType], true
+		}
+	}
+	return "", mediaType{}, false
+}
+
+func contentTypes(content map[string]mediaType) []string {
+	types := make([]string, 0, len(content))
+	for contentType := range content {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestNacosSpecMatchesSimpleConf keeps the OpenAPI spec imported into Nacos
+// and the hand-written tools of the simple sample describing the same calls.
+// Descriptions and timeouts are allowed to differ.
+func TestNacosSpecMatchesSimpleConf(t *testing.T) {
+	doc, err := loadDocument("../../mcp/nacos/mcptools/mcptools.yaml")
+	require.NoError(t, err)
+	c := &converter{doc: doc, cluster: "mock-server", timeout: "10s"}
+	generated := c.convert()
+	require.Empty(t, c.issues)
+	require.Len(t, generated, 6)
+
+	conf := map[string]tool{}
+	for _, t := range loadConfTools(t, "../../mcp/simple/pixiu/conf.yaml") {
+		conf[t.Name] = t
+	}
+	for _, gen := range generated {
+		want, ok := conf[gen.Name]
+		if !assert.True(t, ok, "tool %s missing from mcp/simple/pixiu/conf.yaml", gen.Name) {
+			continue
+		}
+		assert.Equal(t, want.Request.Method, gen.Request.Method, gen.Name)
+		assert.Equal(t, want.Request.Path, gen.Request.Path, gen.Name)
+		assert.Equal(t, want.Request.Headers, gen.Request.Headers, gen.Name)
+		require.Len(t, gen.Args, len(want.Args), gen.Name)
+		for i := range gen.Args {
+			got, exp := gen.Args[i], want.Args[i]
+			got.Description, exp.Description = "", ""
+			assert.True(t, reflect.DeepEqual(exp, got), "%s: arg %d: want %+v, got %+v", gen.Name, i, exp, got)
+		}
+	}
+}
+
+// loadConfTools reads the tools of the mcpserver filter from a Pixiu config.
+func loadConfTools(t *testing.T, path string) []tool {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var conf struct {
+		StaticResources struct {
+			Listeners []struct {
+				FilterChains struct {
+					Filters []struct {
+						Config struct {
+							HTTPFilters []struct {
+								Name   string `yaml:"name"`
+								Config struct {
+									Tools []tool `yaml:"tools"`
+								} `yaml:"config"`
+							} `yaml:"http_filters"`
+						} `yaml:"config"`
+					} `yaml:"filters"`
+				} `yaml:"filter_chains"`
+			} `yaml:"listeners"`
+		} `yaml:"static_resources"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &conf))
+	for _, l := range conf.StaticResources.Listeners {
+		for _, f := range l.FilterChains.Filters {
+			for _, hf := range f.Config.HTTPFilters {
+				if hf.Name == "dgp.filter.mcp.mcpserver" {
+					return hf.Config.Tools
+				}
+			}
+		}
+	}
+	t.Fatalf("%s has no dgp.filter.mcp.mcpserver filter", path)
+	return nil
+}
+
+const edgeCaseSpec = `
+openapi: 3.1.0
+info: {title: Edge cases, version: 1.0.0}
+paths:
+  /orders/{id}:
+    parameters:
+      - $ref: "#/components/parameters/OrderID"
+      - name: verbose
+        in: query
+        schema: {type: boolean}
+    get:
+      operationId: getOrderByID
+      parameters:
+        - name: verbose
+          in: query
+          description: Include line items
+          schema: {type: boolean, default: true}
+    put:
+      summary: Replace an order
+      operationId: replaceOrder
+      requestBody:
+        $ref: "#/components/requestBodies/Order"
+    patch:
+      operationId: patchOrder
+      requestBody:
+        content:
+          application/merge-patch+json:
+            schema:
+              type: object
+              properties:
+                id: {type: string}
+  /orders:
+    post:
+      summary: create_orders
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: array
+              items: {$ref: "#/components/schemas/Order"}
+    get:
+      summary: list_orders
+      parameters:
+        - name: session
+          in: cookie
+          schema: {type: string}
+  /upload:
+    post:
+      requestBody:
+        content:
+          multipart/form-data:
+            schema: {type: object}
+  /search:
+    get:
+      summary: replace_order
+components:
+  parameters:
+    OrderID:
+      name: id
+      in: path
+      description: Order ID
+      schema: {type: [integer, "null"]}
+  requestBodies:
+    Order:
+      required: true
+      content:
+        application/json:
+          schema: {$ref: "#/components/schemas/Order"}
+  schemas:
+    Order:
+      type: object
+      required: [item]
+      properties:
+        item: {type: string, description: Item name}
+        tags: {type: array, items: {type: string}}
+        note: {description: Free text}
+`
+
+func TestConvertEdgeCases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(edgeCaseSpec), 0o644))
+	doc, err := loadDocument(path)
+	require.NoError(t, err)
+	c := &converter{doc: doc, cluster: "orders", timeout: "5s"}
+	tools := c.convert()
+
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Name
+	}
+	assert.Equal(t, []string{"get_order_by_id", "replace_order"}, names)
+
+	get := tools[0]
+	require.Len(t, get.Args, 2)
+	assert.Equal(t, arg{Name: "id", Type: "integer", In: "path", Description: "Order ID", Required: true}, get.Args[0])
+	assert.Equal(t, true, get.Args[1].Default, "the operation parameter overrides the path level one")
+	assert.Equal(t, "Include line items", get.Args[1].Description)
+
+	put := tools[1]
+	assert.Equal(t, "Replace an order", put.Description)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, put.Request.Headers)
+	require.Len(t, put.Args, 5, "path level parameters apply to every operation")
+	assert.Equal(t, arg{Name: "item", Type: "string", In: "body", Description: "Item name", Required: true}, put.Args[2])
+	assert.Equal(t, "array", put.Args[3].Type)
+	assert.Equal(t, "string", put.Args[4].Type)
+
+	var messages []string
+	for _, i := range c.issues {
+		messages = append(messages, i.String())
+	}
+	report := strings.Join(messages, "\n")
+	for _, want := range []string{
+		`PATCH /orders/{id}: skipped: body property "id" clashes with the path parameter of the same name`,
+		`POST /orders: skipped: request body: only an object body can be spread into args, not array`,
+		`GET /orders: skipped: cookie parameter "session" cannot be passed by a tool`,
+		`POST /upload: skipped: request body has no JSON content (multipart/form-data)`,
+		`GET /search: skipped: tool name "replace_order" is already used by PUT /orders/{id}`,
+		`PUT /orders/{id}: body "tags" is passed as a plain array`,
+		`PUT /orders/{id}: body "note" has no type, assuming string`,
+	} {
+		assert.Contains(t, report, want)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// writeTools renders the tools block in the layout of the sample configs,
+// with the tools: key indented by indent spaces.
+func writeTools(tools []tool, indent int) string {
+	pad := strings.Repeat(" ", indent)
+	var b strings.Builder
+	b.WriteString(pad + "tools:\n")
+	for i, t := range tools {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s  - name: %s\n", pad, yamlScalar(t.Name))
+		if t.Description != "" {
+			fmt.Fprintf(&b, "%s    description: %s\n", pad, yamlScalar(t.Description))
+		}
+		fmt.Fprintf(&b, "%s    cluster: %s\n", pad, yamlScalar(t.Cluster))
+		fmt.Fprintf(&b, "%s    request:\n", pad)
+		fmt.Fprintf(&b, "%s      method: %s\n", pad, yamlScalar(t.Request.Method))
+		fmt.Fprintf(&b, "%s      path: %s\n", pad, yamlScalar(t.Request.Path))
+		fmt.Fprintf(&b, "%s      timeout: %s\n", pad, yamlScalar(t.Request.Timeout))
+		if len(t.Request.Headers) > 0 {
+			fmt.Fprintf(&b, "%s      headers:\n", pad)
+			names := make([]string, 0, len(t.Request.Headers))
+			for name := range t.Request.Headers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(&b, "%s        %s: %s\n", pad, name, yamlScalar(t.Request.Headers[name]))
+			}
+		}
+		if len(t.Args) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s    args:\n", pad)
+		for _, a := range t.Args {
+			fmt.Fprintf(&b, "%s      - name: %s\n", pad, yamlScalar(a.Name))
+			fmt.Fprintf(&b, "%s        type: %s\n", pad, yamlScalar(a.Type))
+			fmt.Fprintf(&b, "%s        in: %s\n", pad, yamlScalar(a.In))
+			if a.Description != "" {
+				fmt.Fprintf(&b, "%s        description: %s\n", pad, yamlScalar(a.Description))
+			}
+			fmt.Fprintf(&b, "%s        required: %t\n", pad, a.Required)
+			if a.Default != nil {
+				fmt.Fprintf(&b, "%s        default: %s\n", pad, yamlScalar(a.Default))
+			}
+			if len(a.Enum) > 0 {
+				values := make([]string, len(a.Enum))
+				for i, v := range a.Enum {
+					values[i] = yamlScalar(v)
+				}
+				fmt.Fprintf(&b, "%s        enum: [%s]\n", pad, strings.Join(values, ", "))
+			}
+		}
+	}
+	return b.String()
+}
+
+// yamlScalar renders v as JSON, which YAML reads back unchanged: strings come
+// out double quoted, numbers and booleans bare, lists and maps in flow style.
+func yamlScalar(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(jsonValue(v)); err != nil {
+		return fmt.Sprintf("%q", fmt.Sprint(v))
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// jsonValue converts the map[string]interface{} keys yaml.v3 may produce for
+// nested defaults into something encoding/json accepts.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = jsonValue(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = jsonValue(value)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// syncTools replaces the tools block of a Pixiu config with the generated
+// one, keeping its indentation and everything around it, and reports whether
+// the file changed.
+func syncTools(path string, tools []tool, write bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	start, indent := -1, 0
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(trimmed) == "tools:" {
+			if start >= 0 {
+				return false, fmt.Errorf("%s has more than one tools block", path)
+			}
+			start, indent = i, len(trimmed)-len(strings.TrimLeft(trimmed, " "))
+		}
+	}
+	if start < 0 {
+		return false, fmt.Errorf("%s has no tools block", path)
+	}
+	end := start + 1
+	for end < len(lines) {
+		line := strings.TrimRight(lines[end], "\r\n")
+		body := strings.TrimLeft(line, " ")
+		lineIndent := len(line) - len(body)
+		// Items of a list that is not indented under tools: share its column.
+		if body != "" && (lineIndent < indent || lineIndent == indent && !strings.HasPrefix(body, "- ")) {
+			break
+		}
+		end++
+	}
+	// Keep the blank lines and comments that lead into the next key.
+	for end > start+1 {
+		line := strings.TrimSpace(lines[end-1])
+		if line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		end--
+	}
+
+	out := strings.Join(lines[:start], "") + writeTools(tools, indent) + strings.Join(lines[end:], "")
+	if out == string(data) {
+		return false, nil
+	}
+	if write {
+		return true, os.WriteFile(path, []byte(out), 0o644)
+	}
+	return true, nil
+}
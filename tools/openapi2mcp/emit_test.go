@@ -0,0 +1,106 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/yaml.v3"
+)
+
+const testConf = `http_filters:
+  - name: "dgp.filter.mcp.mcpserver"
+    config:
+      server_info:
+        name: "test"
+      tools:
+        - name: "stale"
+          cluster: "old"
+          request:
+            method: "GET"
+            path: "/old"
+
+      resources:
+        - name: "docs"
+
+  # Downstream HTTP proxy
+  - name: "dgp.filter.http.httpproxy"
+`
+
+func TestSyncTools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testConf), 0o644))
+	tools := []tool{{
+		Name:        "get_user",
+		Description: "Get a user: \"by id\"",
+		Cluster:     "mock-server",
+		Request:     request{Method: "GET", Path: "/api/users/{id}", Timeout: "10s"},
+		Args: []arg{
+			{Name: "id", Type: "integer", In: "path", Required: true},
+			{Name: "status", Type: "string", In: "query", Default: "all", Enum: []interface{}{"all", "draft"}},
+		},
+	}}
+
+	changed, err := syncTools(path, tools, false)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, testConf, string(data), "a check must not write")
+
+	changed, err = syncTools(path, tools, true)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = syncTools(path, tools, true)
+	require.NoError(t, err)
+	assert.False(t, changed, "a synced config stays unchanged")
+
+	data, _ = os.ReadFile(path)
+	var conf struct {
+		HTTPFilters []struct {
+			Name   string `yaml:"name"`
+			Config struct {
+				Tools     []tool                   `yaml:"tools"`
+				Resources []map[string]interface{} `yaml:"resources"`
+			} `yaml:"config"`
+		} `yaml:"http_filters"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &conf), string(data))
+	require.Len(t, conf.HTTPFilters, 2, string(data))
+	assert.Equal(t, "dgp.filter.http.httpproxy", conf.HTTPFilters[1].Name)
+	assert.Len(t, conf.HTTPFilters[0].Config.Resources, 1)
+	assert.Equal(t, tools, conf.HTTPFilters[0].Config.Tools)
+}
+
+func TestSimpleSampleToolsUpToDate(t *testing.T) {
+	doc, err := loadDocument("../../mcp/simple/mcptools/mcptools.yaml")
+	require.NoError(t, err)
+	c := &converter{doc: doc, cluster: "mock-server", timeout: "10s"}
+	tools := c.convert()
+	assert.Empty(t, c.issues)
+
+	changed, err := syncTools("../../mcp/simple/pixiu/conf.yaml", tools, false)
+	require.NoError(t, err)
+	assert.False(t, changed, "regenerate the tools of mcp/simple/pixiu/conf.yaml from mcp/simple/mcptools/mcptools.yaml")
+}
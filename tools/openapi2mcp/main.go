@@ -0,0 +1,82 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command openapi2mcp generates the tools block of the dgp.filter.mcp.mcpserver
+// filter from an OpenAPI 3 spec, so the spec stays the single source of truth
+// for the tools a Pixiu MCP server exposes.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+var (
+	specFile = flag.String("spec", "", "OpenAPI 3 spec to convert (YAML or JSON)")
+	cluster  = flag.String("cluster", "mock-server", "Pixiu cluster the tools send their requests to")
+	timeout  = flag.String("timeout", "10s", "Request timeout of every tool")
+	indent   = flag.Int("indent", 0, "Indentation of the tools: key when printing to stdout")
+	syncConf = flag.String("sync-conf", "", "Pixiu config whose tools block is replaced in place instead of printing")
+	check    = flag.Bool("check", false, "With -sync-conf, only report whether the tools block is out of date")
+	strict   = flag.Bool("strict", false, "Fail when an operation cannot be represented as a tool")
+)
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+	if *specFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	doc, err := loadDocument(*specFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := &converter{doc: doc, cluster: *cluster, timeout: *timeout}
+	tools := c.convert()
+
+	skipped := 0
+	for _, i := range c.issues {
+		log.Printf("%s: %s", *specFile, i)
+		if i.Skipped {
+			skipped++
+		}
+	}
+	if *strict && skipped > 0 {
+		log.Fatalf("%d operations cannot be represented as tools", skipped)
+	}
+
+	if *syncConf == "" {
+		fmt.Print(writeTools(tools, *indent))
+		return
+	}
+	changed, err := syncTools(*syncConf, tools, !*check)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case changed && *check:
+		log.Fatalf("%s: tools are out of date with %s, run without -check to update them", *syncConf, *specFile)
+	case changed:
+		log.Printf("%s: updated %d tools from %s", *syncConf, len(tools), *specFile)
+	default:
+		log.Printf("%s: tools are up to date", *syncConf)
+	}
+}
@@ -0,0 +1,251 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// maxRefDepth bounds how many $refs are followed in a row, so a reference
+// cycle is reported instead of looping forever.
+const maxRefDepth = 16
+
+// httpMethods are the operation keys of a path item, in the order tools are
+// emitted when a path item defines several of them.
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// document is a parsed OpenAPI 3 spec. Paths and properties are kept as
+// nodes, so the generated tools follow the order of the spec.
+type document struct {
+	root *yaml.Node
+	// Paths lists every operation in spec order.
+	Paths []pathOperation
+}
+
+type pathOperation struct {
+	Path      string
+	Method    string
+	Operation operation
+	// Parameters are the parameters shared by all operations of the path.
+	Parameters []parameter
+}
+
+type operation struct {
+	Summary     string       `yaml:"summary"`
+	Description string       `yaml:"description"`
+	OperationID string       `yaml:"operationId"`
+	Parameters  []parameter  `yaml:"parameters"`
+	RequestBody *requestBody `yaml:"requestBody"`
+}
+
+type parameter struct {
+	Ref         string                 `yaml:"$ref"`
+	Name        string                 `yaml:"name"`
+	In          string                 `yaml:"in"`
+	Description string                 `yaml:"description"`
+	Required    bool                   `yaml:"required"`
+	Schema      *schema                `yaml:"schema"`
+	Content     map[string]interface{} `yaml:"content"`
+}
+
+type requestBody struct {
+	Ref         string               `yaml:"$ref"`
+	Description string               `yaml:"description"`
+	Required    bool                 `yaml:"required"`
+	Content     map[string]mediaType `yaml:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `yaml:"schema"`
+}
+
+type schema struct {
+	Ref         string        `yaml:"$ref"`
+	Type        schemaType    `yaml:"type"`
+	Description string        `yaml:"description"`
+	Default     interface{}   `yaml:"default"`
+	Enum        []interface{} `yaml:"enum"`
+	Required    []string      `yaml:"required"`
+	Properties  yaml.Node     `yaml:"properties"`
+	Items       *schema       `yaml:"items"`
+	AllOf       []*schema     `yaml:"allOf"`
+	OneOf       []*schema     `yaml:"oneOf"`
+	AnyOf       []*schema     `yaml:"anyOf"`
+}
+
+// schemaType accepts both the OpenAPI 3.0 form, a single type, and the 3.1
+// form, a list such as [string, "null"], of which the first non-null type is kept.
+type schemaType string
+
+func (t *schemaType) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = schemaType(node.Value)
+		return nil
+	}
+	var types []string
+	if err := node.Decode(&types); err != nil {
+		return err
+	}
+	for _, typ := range types {
+		if typ != "null" {
+			*t = schemaType(typ)
+			return nil
+		}
+	}
+	return nil
+}
+
+// property is one entry of an object schema's properties.
+type property struct {
+	Name   string
+	Schema *schema
+}
+
+// loadDocument reads an OpenAPI 3 spec in YAML or JSON.
+func loadDocument(path string) (*document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not an OpenAPI document", path)
+	}
+	doc := &document{root: root.Content[0]}
+
+	version := mappingValue(doc.root, "openapi")
+	if version == nil || !strings.HasPrefix(version.Value, "3.") {
+		return nil, fmt.Errorf("%s: only OpenAPI 3 documents are supported", path)
+	}
+	paths := mappingValue(doc.root, "paths")
+	if paths == nil {
+		return doc, nil
+	}
+	for i := 0; i+1 < len(paths.Content); i += 2 {
+		name, item := paths.Content[i].Value, paths.Content[i+1]
+		if ref := mappingValue(item, "$ref"); ref != nil {
+			if item, err = doc.lookup(ref.Value); err != nil {
+				return nil, fmt.Errorf("path %s: %w", name, err)
+			}
+		}
+		var shared []parameter
+		if node := mappingValue(item, "parameters"); node != nil {
+			if err := node.Decode(&shared); err != nil {
+				return nil, fmt.Errorf("path %s: %w", name, err)
+			}
+		}
+		for _, method := range httpMethods {
+			node := mappingValue(item, method)
+			if node == nil {
+				continue
+			}
+			var op operation
+			if err := node.Decode(&op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), name, err)
+			}
+			doc.Paths = append(doc.Paths, pathOperation{Path: name, Method: method, Operation: op, Parameters: shared})
+		}
+	}
+	return doc, nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// lookup follows a local JSON pointer such as #/components/schemas/User.
+func (d *document) lookup(ref string) (*yaml.Node, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("external reference %q is not supported", ref)
+	}
+	node := d.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		if node = mappingValue(node, token); node == nil {
+			return nil, fmt.Errorf("reference %q not found", ref)
+		}
+	}
+	return node, nil
+}
+
+// deref replaces v by the object its $ref points to, following chained references.
+func deref[T any](d *document, v *T, ref func(*T) string) (*T, error) {
+	for depth := 0; v != nil && ref(v) != ""; depth++ {
+		if depth == maxRefDepth {
+			return nil, fmt.Errorf("reference cycle at %q", ref(v))
+		}
+		node, err := d.lookup(ref(v))
+		if err != nil {
+			return nil, err
+		}
+		next := new(T)
+		if err := node.Decode(next); err != nil {
+			return nil, fmt.Errorf("decode %q: %w", ref(v), err)
+		}
+		v = next
+	}
+	return v, nil
+}
+
+func (d *document) parameter(p parameter) (*parameter, error) {
+	return deref(d, &p, func(p *parameter) string { return p.Ref })
+}
+
+func (d *document) requestBody(b *requestBody) (*requestBody, error) {
+	return deref(d, b, func(b *requestBody) string { return b.Ref })
+}
+
+func (d *document) schema(s *schema) (*schema, error) {
+	return deref(d, s, func(s *schema) string { return s.Ref })
+}
+
+// properties returns the resolved properties of an object schema in spec order.
+func (d *document) properties(s *schema) ([]property, error) {
+	var props []property
+	for i := 0; i+1 < len(s.Properties.Content); i += 2 {
+		name := s.Properties.Content[i].Value
+		prop := &schema{}
+		if err := s.Properties.Content[i+1].Decode(prop); err != nil {
+			return nil, fmt.Errorf("property %q: %w", name, err)
+		}
+		prop, err := d.schema(prop)
+		if err != nil {
+			return nil, fmt.Errorf("property %q: %w", name, err)
+		}
+		props = append(props, property{Name: name, Schema: prop})
+	}
+	return props, nil
+}
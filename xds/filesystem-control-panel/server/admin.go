@@ -0,0 +1,128 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// adminHandler serves the snapshot admin API:
+//
+//	GET  /nodes                  status and history of every node
+//	GET  /nodes/{node}           status and history of one node
+//	POST /nodes/{node}/rollback  republish {"version": "..."}, or the previous snapshot
+//	POST /reload                 re-read the config directory now
+//
+// and the in-memory service registry:
+//
+//	GET    /registry                                 instances of every service
+//	PUT    /registry/{service}/instances/{id}        register or replace an instance
+//	DELETE /registry/{service}/instances/{id}        deregister an instance
+func adminHandler(p *panel) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		p.reload()
+		writeJSON(w, http.StatusOK, p.status())
+	})
+	mux.HandleFunc("/nodes", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, p.status())
+	})
+	mux.HandleFunc("/nodes/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/nodes/"), "/")
+		node := parts[0]
+		switch {
+		case len(parts) == 1 && r.Method == http.MethodGet:
+			for _, s := range p.status() {
+				if s.Node == node {
+					writeJSON(w, http.StatusOK, s)
+					return
+				}
+			}
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown node " + node})
+		case len(parts) == 2 && parts[1] == "rollback" && r.Method == http.MethodPost:
+			var req struct {
+				Version string `json:"version"`
+			}
+			if r.ContentLength != 0 {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+					return
+				}
+			}
+			rec, err := p.rollback(node, req.Version)
+			if err != nil {
+				writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, rec)
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+	})
+	mux.HandleFunc("/registry", func(w http.ResponseWriter, r *http.Request) {
+		if p.registry == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no registry"})
+			return
+		}
+		writeJSON(w, http.StatusOK, p.registry.services())
+	})
+	mux.HandleFunc("/registry/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/registry/"), "/")
+		if p.registry == nil || len(parts) != 3 || parts[1] != "instances" || parts[0] == "" || parts[2] == "" {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+		service, id := parts[0], parts[2]
+		switch r.Method {
+		case http.MethodPut:
+			var instance registryInstance
+			if err := json.NewDecoder(r.Body).Decode(&instance); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid instance: " + err.Error()})
+				return
+			}
+			instance.ID = id
+			if err := p.registry.put(service, instance); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+		case http.MethodDelete:
+			if err := p.registry.remove(service, id); err != nil {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		p.reload()
+		writeJSON(w, http.StatusOK, p.registry.services()[service])
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,253 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	listenersFile = "lds.json"
+	clustersFile  = "cds.json"
+)
+
+// pixiuConfig is the listener and cluster set served to one node.
+type pixiuConfig struct {
+	listeners *pixiupb.PixiuExtensionListeners
+	clusters  *pixiupb.PixiuExtensionClusters
+}
+
+// loadPixiuConfig reads the listeners and clusters from the first of dirs
+// that provides them, so a node directory only needs the files it overrides.
+// A directory provides them through lds.json and cds.json or, for the parts
+// without a JSON file, through a static.yaml Pixiu config.
+func loadPixiuConfig(dirs ...string) (*pixiuConfig, error) {
+	cfg := &pixiuConfig{}
+	for _, dir := range dirs {
+		if err := cfg.fillFrom(dir); err != nil {
+			return nil, err
+		}
+		if cfg.listeners != nil && cfg.clusters != nil {
+			return cfg, nil
+		}
+	}
+	if cfg.listeners == nil {
+		return nil, fmt.Errorf("neither %s nor %s found in %v", listenersFile, staticConfFile, dirs)
+	}
+	return nil, fmt.Errorf("neither %s nor %s found in %v", clustersFile, staticConfFile, dirs)
+}
+
+// fillFrom sets the parts of c that are still missing from dir.
+func (c *pixiuConfig) fillFrom(dir string) error {
+	if c.listeners == nil {
+		listeners := &pixiupb.PixiuExtensionListeners{}
+		if ok, err := readProtoJSON(filepath.Join(dir, listenersFile), listeners); err != nil {
+			return err
+		} else if ok {
+			c.listeners = listeners
+		}
+	}
+	if c.clusters == nil {
+		clusters := &pixiupb.PixiuExtensionClusters{}
+		if ok, err := readProtoJSON(filepath.Join(dir, clustersFile), clusters); err != nil {
+			return err
+		} else if ok {
+			c.clusters = clusters
+		}
+	}
+	if c.listeners != nil && c.clusters != nil {
+		return nil
+	}
+
+	path := filepath.Join(dir, staticConfFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	static, err := loadStaticConf(path)
+	if err != nil {
+		return err
+	}
+	if c.listeners == nil {
+		c.listeners = static.listeners
+	}
+	if c.clusters == nil {
+		c.clusters = static.clusters
+	}
+	return nil
+}
+
+// readProtoJSON parses path into m and reports whether the file exists.
+func readProtoJSON(path string, m proto.Message) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := protojson.Unmarshal(data, m); err != nil {
+		return false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// validate rejects configs Pixiu would fail to apply: missing or duplicate
+// names, endpoints without an address and routes to unknown clusters.
+func (c *pixiuConfig) validate() error {
+	if len(c.listeners.Listeners) == 0 {
+		return fmt.Errorf("%s defines no listeners", listenersFile)
+	}
+
+	clusters := make(map[string]bool, len(c.clusters.Clusters))
+	for _, cluster := range c.clusters.Clusters {
+		if cluster.Name == "" {
+			return fmt.Errorf("cluster without name")
+		}
+		if clusters[cluster.Name] {
+			return fmt.Errorf("duplicate cluster %q", cluster.Name)
+		}
+		clusters[cluster.Name] = true
+		for _, endpoint := range cluster.Endpoints {
+			if endpoint.Address == nil || endpoint.Address.Address == "" || endpoint.Address.Port <= 0 {
+				return fmt.Errorf("cluster %q: endpoint %q has no address", cluster.Name, endpoint.Id)
+			}
+		}
+	}
+
+	listeners := make(map[string]bool, len(c.listeners.Listeners))
+	for _, listener := range c.listeners.Listeners {
+		if listener.Name == "" {
+			return fmt.Errorf("listener without name")
+		}
+		if listeners[listener.Name] {
+			return fmt.Errorf("duplicate listener %q", listener.Name)
+		}
+		listeners[listener.Name] = true
+		if listener.Address == nil || listener.Address.SocketAddress == nil || listener.Address.SocketAddress.Port <= 0 {
+			return fmt.Errorf("listener %q has no socket address", listener.Name)
+		}
+		for _, name := range routeClusters(listener) {
+			if !clusters[name] {
+				return fmt.Errorf("listener %q routes to unknown cluster %q", listener.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// routeClusters returns the clusters referenced by the route_config of the
+// listener's struct-typed filters.
+func routeClusters(listener *pixiupb.Listener) []string {
+	if listener.FilterChain == nil {
+		return nil
+	}
+	var names []string
+	for _, filter := range listener.FilterChain.Filters {
+		config := filter.GetStruct()
+		if config == nil {
+			continue
+		}
+		routeConfig := config.Fields["route_config"].GetStructValue()
+		if routeConfig == nil {
+			continue
+		}
+		for _, route := range routeConfig.Fields["routes"].GetListValue().GetValues() {
+			action := route.GetStructValue().GetFields()["route"].GetStructValue()
+			if name := action.GetFields()["cluster"].GetStringValue(); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// version hashes the parsed config, so formatting-only edits keep the
+// version and Pixiu is not pushed an identical snapshot.
+func (c *pixiuConfig) version() (string, error) {
+	h := sha256.New()
+	opts := proto.MarshalOptions{Deterministic: true}
+	for _, m := range []proto.Message{c.listeners, c.clusters} {
+		data, err := opts.Marshal(m)
+		if err != nil {
+			return "", err
+		}
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// snapshot wraps the config into the extension resources Pixiu subscribes to.
+func (c *pixiuConfig) snapshot(version string) (*cache.Snapshot, error) {
+	ldsResource, err := marshalAny(c.listeners)
+	if err != nil {
+		return nil, err
+	}
+	cdsResource, err := marshalAny(c.clusters)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := cache.NewSnapshot(version,
+		map[resource.Type][]types.Resource{
+			resource.ExtensionConfigType: {
+				&core.TypedExtensionConfig{
+					Name:        xds.ClusterType,
+					TypedConfig: cdsResource,
+				},
+				&core.TypedExtensionConfig{
+					Name:        xds.ListenerType,
+					TypedConfig: ldsResource,
+				},
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := snap.Consistent(); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// marshalAny wraps m like anypb.New, but with deterministic map ordering so an
+// unchanged config yields identical bytes and is not resent as a change.
+func marshalAny(m proto.Message) (*anypb.Any, error) {
+	out := &anypb.Any{}
+	if err := anypb.MarshalFrom(out, m, proto.MarshalOptions{Deterministic: true}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -8,4 +8,5 @@ require (
 	github.com/fsnotify/fsnotify v1.5.1
 	google.golang.org/grpc v1.56.3
 	google.golang.org/protobuf v1.30.0
+	gopkg.in/yaml.v3 v3.0.1
 )
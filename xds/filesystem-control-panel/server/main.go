@@ -21,33 +21,27 @@ import (
 	"context"
 	"flag"
 	"log"
-	"os"
-	"strconv"
+	"net/http"
+	"time"
 )
 
 import (
-	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
-	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
-
-	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
-	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/test/v3"
-
-	"github.com/fsnotify/fsnotify"
-
-	"google.golang.org/protobuf/encoding/protojson"
-
-	"google.golang.org/protobuf/types/known/anypb"
 )
 
 var (
-	l       Logger
-	port    = uint(18000)
-	nodeID  = "test-id"
-	version = 100
+	l Logger
+
+	port        = flag.Uint("port", 18000, "xDS gRPC port")
+	adminAddr   = flag.String("admin", ":18001", "address of the snapshot admin API")
+	configDir   = flag.String("config-dir", "../pixiu", "directory with lds.json, cds.json and per-node overrides in nodes/<node-id>/")
+	nodeID      = flag.String("node", "test-id", "node served from the files at the top of -config-dir")
+	historySize = flag.Int("history", 10, "number of snapshots kept per node for rollback")
+	debounce    = flag.Duration("debounce", 300*time.Millisecond, "quiet period after a file event before reloading")
+	delta       = flag.Bool("delta", false, "serve from per-node linear caches, so incremental xDS clients only receive changed resources")
+	adsOnly     = flag.Bool("ads", false, "register only the aggregated discovery service")
+	registryDir = flag.String("registry-dir", "", "directory with <service>.json instance files whose instances become the endpoints of the cluster of the same name")
 )
 
 func init() {
@@ -58,111 +52,29 @@ func init() {
 func main() {
 	flag.Parse()
 
-	// Create a snaphost
-	snaphost := cache.NewSnapshotCache(false, cache.IDHash{}, l)
+	ctx := context.Background()
+	var store resourceStore = newSnapshotStore(*adsOnly)
+	if *delta {
+		store = newLinearStore()
+	}
+	p := newPanel(store, newRegistry(*registryDir), *configDir, *nodeID, *historySize)
+	// A node whose files are invalid at startup is served once they are fixed.
+	p.reload()
 
 	go func() {
-		// Create the config that we'll serve to Envoy
-		config := GenerateSnapshotPixiuFromFile()
-
-		if err := config.Consistent(); err != nil {
-			l.Errorf("config inconsistency: %+v\n%+v", config, err)
-			os.Exit(1)
+		if err := p.watch(ctx, *debounce); err != nil {
+			log.Fatal(err)
 		}
-		l.Debugf("will serve config %+v", config)
-
-		// Add the config to the snaphost
-		if err := snaphost.SetSnapshot(context.Background(), nodeID, config); err != nil {
-			l.Errorf("config error %q for %+v", err, config)
-			os.Exit(1)
+	}()
+	go func() {
+		log.Printf("snapshot admin API listening on %s\n", *adminAddr)
+		if err := http.ListenAndServe(*adminAddr, adminHandler(p)); err != nil {
+			log.Fatal(err)
 		}
-
-		go func() {
-			watcher, err := fsnotify.NewWatcher()
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer watcher.Close()
-			done := make(chan bool)
-			go func() {
-				for {
-					select {
-					case event, ok := <-watcher.Events:
-						if !ok {
-							return
-						}
-						if event.Op == fsnotify.Write {
-							log.Println("event:", event)
-							log.Println("modified file:", event.Name)
-							conf := GenerateSnapshotPixiuFromFile()
-							// Add the config to the snaphost
-							if err := snaphost.SetSnapshot(context.Background(), nodeID, conf); err != nil {
-								l.Errorf("config error %q for %+v", err, config)
-								os.Exit(1)
-							}
-						}
-					case err, ok := <-watcher.Errors:
-						if !ok {
-							return
-						}
-						log.Println("error:", err)
-					}
-				}
-			}()
-
-			err = watcher.Add("../pixiu")
-			if err != nil {
-				log.Fatal(err)
-			}
-			<-done
-		}()
 	}()
 
 	// Run the xDS server
-	ctx := context.Background()
 	cb := &test.Callbacks{Debug: l.Debug}
-	srv := server.NewServer(ctx, snaphost, cb)
-	RunServer(ctx, srv, port)
-}
-
-func GenerateSnapshotPixiuFromFile() *cache.Snapshot {
-	cdsStr, err := os.ReadFile("../pixiu/cds.json")
-	if err != nil {
-		l.Errorf("%s", err)
-	}
-	cds := &pixiupb.PixiuExtensionClusters{}
-	err = protojson.Unmarshal(cdsStr, cds)
-	if err != nil {
-		l.Errorf("%s", err)
-	}
-
-	ldsStr, err := os.ReadFile("../pixiu/lds.json")
-	if err != nil {
-		l.Errorf("%s", err)
-	}
-
-	lds := &pixiupb.PixiuExtensionListeners{}
-	err = protojson.Unmarshal(ldsStr, lds)
-	if err != nil {
-		l.Errorf("%s", err)
-	}
-
-	version++
-	ldsResource, _ := anypb.New(lds)
-	cdsResource, _ := anypb.New(cds)
-	snap, _ := cache.NewSnapshot(strconv.Itoa(version),
-		map[resource.Type][]types.Resource{
-			resource.ExtensionConfigType: {
-				&core.TypedExtensionConfig{
-					Name:        xds.ClusterType,
-					TypedConfig: cdsResource,
-				},
-				&core.TypedExtensionConfig{
-					Name:        xds.ListenerType,
-					TypedConfig: ldsResource,
-				},
-			},
-		},
-	)
-	return snap
+	srv := server.NewServer(ctx, store, cb)
+	RunServer(ctx, srv, *port, *adsOnly)
 }
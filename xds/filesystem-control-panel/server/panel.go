@@ -0,0 +1,330 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// nodesDir holds one sub directory per node ID whose files override the
+// shared lds.json and cds.json of the config directory.
+const nodesDir = "nodes"
+
+// snapshotRecord is one published snapshot of a node.
+type snapshotRecord struct {
+	Version     string    `json:"version"`
+	Source      string    `json:"source"`
+	PublishedAt time.Time `json:"published_at"`
+
+	snapshot *cache.Snapshot
+}
+
+type nodeState struct {
+	current *snapshotRecord
+	// fileVersion is the version of the node's files when they last loaded,
+	// which differs from current.Version after a rollback.
+	fileVersion string
+	// history holds the last published snapshots, oldest first, without
+	// duplicate versions.
+	history   []*snapshotRecord
+	lastError string
+	errorAt   time.Time
+}
+
+// nodeStatus is the admin view of a node.
+type nodeStatus struct {
+	Node      string            `json:"node"`
+	Version   string            `json:"version,omitempty"`
+	Source    string            `json:"source,omitempty"`
+	LastError string            `json:"last_error,omitempty"`
+	ErrorAt   *time.Time        `json:"error_at,omitempty"`
+	History   []*snapshotRecord `json:"history"`
+}
+
+// panel publishes the config directory to the snapshot cache. A node only
+// gets a new snapshot once its files parse and validate; otherwise it keeps
+// serving the last good one.
+type panel struct {
+	mu sync.Mutex
+
+	store       resourceStore
+	registry    *registry
+	dir         string
+	defaultNode string
+	historySize int
+	nodes       map[string]*nodeState
+	reloads     int
+}
+
+// newPanel serves the config directory dir. Clusters named after a service
+// of reg, which may be nil, take their endpoints from the registry.
+func newPanel(store resourceStore, reg *registry, dir string, defaultNode string, historySize int) *panel {
+	if historySize < 2 {
+		historySize = 2
+	}
+	return &panel{
+		store:       store,
+		registry:    reg,
+		dir:         dir,
+		defaultNode: defaultNode,
+		historySize: historySize,
+		nodes:       map[string]*nodeState{},
+	}
+}
+
+// nodeDirs maps every node to the directories its config is read from, most
+// specific first. The default node is served from the config directory alone.
+func (p *panel) nodeDirs() (map[string][]string, error) {
+	dirs := map[string][]string{p.defaultNode: {p.dir}}
+	entries, err := os.ReadDir(filepath.Join(p.dir, nodesDir))
+	if os.IsNotExist(err) {
+		return dirs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs[entry.Name()] = []string{filepath.Join(p.dir, nodesDir, entry.Name()), p.dir}
+		}
+	}
+	return dirs, nil
+}
+
+// reload re-reads the config of every node and the registry and publishes
+// the nodes that changed. Nodes whose directory was removed stop being
+// served.
+func (p *panel) reload() {
+	dirs, err := p.nodeDirs()
+	if err != nil {
+		l.Errorf("list nodes in %s: %s", p.dir, err)
+		return
+	}
+	var services map[string][]registryInstance
+	if p.registry != nil {
+		p.registry.load()
+		services = p.registry.services()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reloads++
+	for node := range p.nodes {
+		if _, ok := dirs[node]; !ok {
+			l.Infof("node %s removed, clearing its snapshot", node)
+			p.store.clear(node)
+			delete(p.nodes, node)
+		}
+	}
+	for node, nodeDirs := range dirs {
+		if err := p.reloadNodeLocked(node, nodeDirs, services); err != nil {
+			state := p.state(node)
+			state.lastError = err.Error()
+			state.errorAt = time.Now()
+			if state.current != nil {
+				l.Errorf("node %s: %s; keeping version %s", node, err, state.current.Version)
+			} else {
+				l.Errorf("node %s: %s; nothing to serve yet", node, err)
+			}
+		}
+	}
+}
+
+func (p *panel) reloadNodeLocked(node string, dirs []string, services map[string][]registryInstance) error {
+	cfg, err := loadPixiuConfig(dirs...)
+	if err != nil {
+		return err
+	}
+	cfg.applyRegistry(services)
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	version, err := cfg.version()
+	if err != nil {
+		return err
+	}
+	state := p.state(node)
+	state.lastError = ""
+	if state.fileVersion == version {
+		return nil
+	}
+	snap, err := cfg.snapshot(version)
+	if err != nil {
+		return err
+	}
+	if err := p.publishLocked(node, &snapshotRecord{Version: version, Source: "file", snapshot: snap}); err != nil {
+		return err
+	}
+	state.fileVersion = version
+	return nil
+}
+
+func (p *panel) state(node string) *nodeState {
+	state, ok := p.nodes[node]
+	if !ok {
+		state = &nodeState{}
+		p.nodes[node] = state
+	}
+	return state
+}
+
+// publishLocked hands rec to the store and moves it to the end of
+// the node's history.
+func (p *panel) publishLocked(node string, rec *snapshotRecord) error {
+	if err := p.store.publish(node, rec.snapshot); err != nil {
+		return err
+	}
+	rec.PublishedAt = time.Now()
+	state := p.state(node)
+	history := state.history[:0]
+	for _, old := range state.history {
+		if old.Version != rec.Version {
+			history = append(history, old)
+		}
+	}
+	history = append(history, rec)
+	if len(history) > p.historySize {
+		history = history[len(history)-p.historySize:]
+	}
+	state.history = history
+	state.current = rec
+	l.Infof("node %s: published version %s (%s)", node, rec.Version, rec.Source)
+	return nil
+}
+
+// rollback republishes a snapshot from the node's history, by default the
+// one before the current. It stays active until the node's files change.
+func (p *panel) rollback(node string, version string) (*snapshotRecord, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state, ok := p.nodes[node]
+	if !ok || state.current == nil {
+		return nil, fmt.Errorf("node %q has no snapshot", node)
+	}
+	var target *snapshotRecord
+	if version == "" {
+		if len(state.history) < 2 {
+			return nil, fmt.Errorf("node %q has no earlier snapshot", node)
+		}
+		target = state.history[len(state.history)-2]
+	} else {
+		for _, rec := range state.history {
+			if rec.Version == version {
+				target = rec
+			}
+		}
+		if target == nil {
+			return nil, fmt.Errorf("version %q is not in the history of node %q", version, node)
+		}
+	}
+	rec := &snapshotRecord{Version: target.Version, Source: "rollback", snapshot: target.snapshot}
+	if err := p.publishLocked(node, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (p *panel) status() []nodeStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]nodeStatus, 0, len(p.nodes))
+	for node, state := range p.nodes {
+		s := nodeStatus{Node: node, LastError: state.lastError, History: append([]*snapshotRecord(nil), state.history...)}
+		if state.current != nil {
+			s.Version = state.current.Version
+			s.Source = state.current.Source
+		}
+		if state.lastError != "" {
+			errorAt := state.errorAt
+			s.ErrorAt = &errorAt
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Node < out[j].Node })
+	return out
+}
+
+// watch reloads the config once no file event has arrived for debounce, so
+// an editor that saves a file in several writes triggers one reload.
+func (p *panel) watch(ctx context.Context, debounce time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	addDirs := func() {
+		dirs, err := p.nodeDirs()
+		if err != nil {
+			l.Errorf("list nodes in %s: %s", p.dir, err)
+			return
+		}
+		_ = watcher.Add(filepath.Join(p.dir, nodesDir))
+		if p.registry != nil && p.registry.dir != "" {
+			if err := watcher.Add(p.registry.dir); err != nil {
+				l.Errorf("watch %s: %s", p.registry.dir, err)
+			}
+		}
+		for _, nodeDirs := range dirs {
+			if err := watcher.Add(nodeDirs[0]); err != nil {
+				l.Errorf("watch %s: %s", nodeDirs[0], err)
+			}
+		}
+	}
+	addDirs()
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			l.Debugf("file event: %s", event)
+			pending = time.After(debounce)
+		case <-pending:
+			pending = nil
+			addDirs()
+			p.reload()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			l.Errorf("watch error: %s", err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+)
+
+// newTestPanel serves a copy of ../pixiu from a temporary directory.
+func newTestPanel(t *testing.T) (*panel, string) {
+	t.Helper()
+	dir := testConfigDir(t)
+	return newPanel(newSnapshotStore(false), nil, dir, "test-id", 3), dir
+}
+
+func testConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{listenersFile, clustersFile} {
+		data, err := os.ReadFile(filepath.Join("../pixiu", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(dir, name), string(data))
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedVersion(t *testing.T, p *panel, node string) string {
+	t.Helper()
+	snap, err := p.store.(*snapshotStore).GetSnapshot(node)
+	if err != nil {
+		t.Fatalf("node %s has no snapshot: %v", node, err)
+	}
+	return snap.GetVersion(resource.ExtensionConfigType)
+}
+
+var backendPort = regexp.MustCompile(`"port": "\d+"`)
+
+// editCluster changes the port of the first backend in cds.json.
+func editCluster(t *testing.T, dir string, port string) {
+	t.Helper()
+	path := filepath.Join(dir, clustersFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := true
+	edited := backendPort.ReplaceAllStringFunc(string(data), func(match string) string {
+		if !first {
+			return match
+		}
+		first = false
+		return `"port": "` + port + `"`
+	})
+	writeFile(t, path, edited)
+}
+
+func TestPanelKeepsLastGoodSnapshot(t *testing.T) {
+	p, dir := newTestPanel(t)
+	p.reload()
+	good := servedVersion(t, p, "test-id")
+
+	writeFile(t, filepath.Join(dir, clustersFile), `{"clusters": [`)
+	p.reload()
+	if got := servedVersion(t, p, "test-id"); got != good {
+		t.Fatalf("broken cds.json replaced version %s with %s", good, got)
+	}
+	if status := p.status()[0]; status.LastError == "" {
+		t.Fatalf("expected the parse error to be reported: %+v", status)
+	}
+
+	writeFile(t, filepath.Join(dir, clustersFile), `{"clusters": [{"name": "other", "endpoints": []}]}`)
+	p.reload()
+	if status := p.status()[0]; !strings.Contains(status.LastError, `unknown cluster "http_bin"`) {
+		t.Fatalf("expected a dangling route to be rejected, got %q", status.LastError)
+	}
+	if got := servedVersion(t, p, "test-id"); got != good {
+		t.Fatalf("invalid cds.json replaced version %s with %s", good, got)
+	}
+}
+
+func TestPanelVersionsAndRollback(t *testing.T) {
+	p, dir := newTestPanel(t)
+	p.reload()
+	v1 := servedVersion(t, p, "test-id")
+
+	// Reformatting the file keeps the content hash.
+	data, _ := os.ReadFile(filepath.Join(dir, listenersFile))
+	writeFile(t, filepath.Join(dir, listenersFile), "\n"+string(data)+"\n")
+	p.reload()
+	if got := servedVersion(t, p, "test-id"); got != v1 {
+		t.Fatalf("whitespace edit changed version %s to %s", v1, got)
+	}
+
+	editCluster(t, dir, "9091")
+	p.reload()
+	v2 := servedVersion(t, p, "test-id")
+	if v2 == v1 {
+		t.Fatal("content change kept the version")
+	}
+
+	if _, err := p.rollback("test-id", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := servedVersion(t, p, "test-id"); got != v1 {
+		t.Fatalf("rollback served %s, want %s", got, v1)
+	}
+	// Unchanged files do not undo the rollback.
+	p.reload()
+	if got := servedVersion(t, p, "test-id"); got != v1 {
+		t.Fatalf("reload undid the rollback: %s", got)
+	}
+	if _, err := p.rollback("test-id", "unknown"); err == nil {
+		t.Fatal("expected rollback to an unknown version to fail")
+	}
+
+	editCluster(t, dir, "9092")
+	p.reload()
+	editCluster(t, dir, "9093")
+	p.reload()
+	if history := p.status()[0].History; len(history) != 3 {
+		t.Fatalf("expected history capped at 3, got %d", len(history))
+	}
+}
+
+func TestPanelNodeDirectories(t *testing.T) {
+	p, dir := newTestPanel(t)
+	data, _ := os.ReadFile(filepath.Join(dir, clustersFile))
+	writeFile(t, filepath.Join(dir, nodesDir, "canary", clustersFile),
+		strings.Replace(string(data), `"port": "8081"`, `"port": "9999"`, 1))
+	p.reload()
+
+	if servedVersion(t, p, "canary") == servedVersion(t, p, "test-id") {
+		t.Fatal("canary override did not change its snapshot")
+	}
+
+	// A broken node directory does not affect the other nodes.
+	writeFile(t, filepath.Join(dir, nodesDir, "broken", listenersFile), `{"listeners": []}`)
+	p.reload()
+	for _, s := range p.status() {
+		if (s.Node == "broken") != (s.LastError != "") {
+			t.Fatalf("unexpected status %+v", s)
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, nodesDir, "canary")); err != nil {
+		t.Fatal(err)
+	}
+	p.reload()
+	if _, err := p.store.(*snapshotStore).GetSnapshot("canary"); err == nil {
+		t.Fatal("removed node is still served")
+	}
+}
+
+func TestPanelDebouncesFileEvents(t *testing.T) {
+	p, dir := newTestPanel(t)
+	p.reload()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = p.watch(ctx, 200*time.Millisecond)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	for _, port := range []string{"9001", "9002", "9003"} {
+		editCluster(t, dir, port)
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(600 * time.Millisecond)
+
+	p.mu.Lock()
+	reloads := p.reloads
+	p.mu.Unlock()
+	if reloads != 2 {
+		t.Fatalf("expected one reload for a burst of writes, got %d", reloads-1)
+	}
+}
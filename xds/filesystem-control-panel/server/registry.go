@@ -0,0 +1,244 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+)
+
+// defaultWeight is the weight of an instance that does not set one, as in
+// Dubbo.
+const defaultWeight = 100
+
+var errUnknownInstance = errors.New("unknown instance")
+
+// registryInstance is one instance of a service in the local registry.
+type registryInstance struct {
+	ID       string            `json:"id"`
+	Address  string            `json:"address"`
+	Port     int64             `json:"port"`
+	Healthy  bool              `json:"healthy"`
+	Weight   uint32            `json:"weight"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// UnmarshalJSON makes an instance healthy with the default weight unless
+// the JSON says otherwise.
+func (i *registryInstance) UnmarshalJSON(data []byte) error {
+	type plain registryInstance
+	v := plain{Healthy: true, Weight: defaultWeight}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*i = registryInstance(v)
+	return nil
+}
+
+func (i registryInstance) validate() error {
+	if i.ID == "" {
+		return fmt.Errorf("instance without id")
+	}
+	if i.Address == "" || i.Port <= 0 || i.Port > 65535 {
+		return fmt.Errorf("instance %q has no valid address", i.ID)
+	}
+	return nil
+}
+
+// endpoint returns the Pixiu endpoint of the instance. Pixiu endpoints have
+// no weight field, so the weight travels as metadata for consumers that read
+// it; Pixiu's load balancers do not, and only weight 0 (left out) has effect.
+func (i registryInstance) endpoint() *pixiupb.Endpoint {
+	metadata := map[string]string{"weight": strconv.FormatUint(uint64(i.Weight), 10)}
+	for k, v := range i.Metadata {
+		metadata[k] = v
+	}
+	return &pixiupb.Endpoint{
+		Id:       i.ID,
+		Address:  &pixiupb.SocketAddress{Address: i.Address, Port: i.Port},
+		Metadata: metadata,
+	}
+}
+
+// registry is a local service registry standing in for Nacos or Kubernetes.
+// Services come from <service>.json files in a directory and from an
+// in-memory store changed through the admin API; an in-memory instance
+// replaces the file instance with the same ID.
+type registry struct {
+	mu sync.Mutex
+
+	dir string
+	// files holds the last instances that parsed from each service file.
+	files  map[string][]registryInstance
+	memory map[string]map[string]registryInstance
+}
+
+func newRegistry(dir string) *registry {
+	return &registry{
+		dir:    dir,
+		files:  map[string][]registryInstance{},
+		memory: map[string]map[string]registryInstance{},
+	}
+}
+
+// load re-reads the registry directory. A service file that fails to parse
+// keeps the instances it had, so a half-written file does not empty a
+// cluster.
+func (r *registry) load() {
+	if r.dir == "" {
+		return
+	}
+	paths, err := filepath.Glob(filepath.Join(r.dir, "*.json"))
+	if err != nil {
+		l.Errorf("list registry %s: %s", r.dir, err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	files := make(map[string][]registryInstance, len(paths))
+	for _, path := range paths {
+		service := strings.TrimSuffix(filepath.Base(path), ".json")
+		instances, err := readServiceFile(path)
+		if err != nil {
+			l.Errorf("registry: %s; keeping the previous instances of %s", err, service)
+			if old, ok := r.files[service]; ok {
+				files[service] = old
+			}
+			continue
+		}
+		files[service] = instances
+	}
+	r.files = files
+}
+
+func readServiceFile(path string) ([]registryInstance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Instances []registryInstance `json:"instances"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for _, instance := range file.Instances {
+		if err := instance.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if seen[instance.ID] {
+			return nil, fmt.Errorf("%s: duplicate instance %q", path, instance.ID)
+		}
+		seen[instance.ID] = true
+	}
+	return file.Instances, nil
+}
+
+// services returns the instances of every service, sorted by ID.
+func (r *registry) services() map[string][]registryInstance {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	merged := map[string]map[string]registryInstance{}
+	for service, instances := range r.files {
+		merged[service] = map[string]registryInstance{}
+		for _, instance := range instances {
+			merged[service][instance.ID] = instance
+		}
+	}
+	for service, instances := range r.memory {
+		if merged[service] == nil {
+			merged[service] = map[string]registryInstance{}
+		}
+		for id, instance := range instances {
+			merged[service][id] = instance
+		}
+	}
+
+	out := make(map[string][]registryInstance, len(merged))
+	for service, instances := range merged {
+		list := make([]registryInstance, 0, len(instances))
+		for _, instance := range instances {
+			list = append(list, instance)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		out[service] = list
+	}
+	return out
+}
+
+// put registers or replaces an in-memory instance.
+func (r *registry) put(service string, instance registryInstance) error {
+	if err := instance.validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.memory[service] == nil {
+		r.memory[service] = map[string]registryInstance{}
+	}
+	r.memory[service][instance.ID] = instance
+	return nil
+}
+
+// remove deregisters an in-memory instance. Instances from files are
+// removed by editing the file.
+func (r *registry) remove(service string, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.memory[service][id]; !ok {
+		return fmt.Errorf("instance %q of service %q: %w", id, service, errUnknownInstance)
+	}
+	delete(r.memory[service], id)
+	if len(r.memory[service]) == 0 {
+		delete(r.memory, service)
+	}
+	return nil
+}
+
+// applyRegistry replaces the endpoints of every cluster named after a
+// registry service with the healthy instances of the service. Instances with
+// weight 0 are drained and left out as well.
+func (c *pixiuConfig) applyRegistry(services map[string][]registryInstance) {
+	for _, cluster := range c.clusters.Clusters {
+		instances, ok := services[cluster.Name]
+		if !ok {
+			continue
+		}
+		endpoints := []*pixiupb.Endpoint{}
+		for _, instance := range instances {
+			if instance.Healthy && instance.Weight > 0 {
+				endpoints = append(endpoints, instance.endpoint())
+			}
+		}
+		cluster.Endpoints = endpoints
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+)
+
+func newRegistryPanel(t *testing.T) (*panel, string) {
+	t.Helper()
+	registryDir := t.TempDir()
+	return newPanel(newSnapshotStore(false), newRegistry(registryDir), testConfigDir(t), "test-id", 3), registryDir
+}
+
+// servedEndpoints lists the served endpoints of http_bin as id@port/weight.
+func servedEndpoints(t *testing.T, p *panel) []string {
+	t.Helper()
+	snap, err := p.store.(*snapshotStore).GetSnapshot("test-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := snap.GetResources(resource.ExtensionConfigType)[xds.ClusterType].(*core.TypedExtensionConfig)
+	clusters := &pixiupb.PixiuExtensionClusters{}
+	if err := res.TypedConfig.UnmarshalTo(clusters); err != nil {
+		t.Fatal(err)
+	}
+	out := []string{}
+	for _, e := range clusters.Clusters[0].Endpoints {
+		out = append(out, fmt.Sprintf("%s@%d/%s", e.Id, e.Address.Port, e.Metadata["weight"]))
+	}
+	return out
+}
+
+func TestRegistryFileDiscovery(t *testing.T) {
+	p, registryDir := newRegistryPanel(t)
+	p.reload()
+	if got := servedEndpoints(t, p); !reflect.DeepEqual(got, []string{"backend3@8081/", "backend4@8082/"}) {
+		t.Fatalf("expected the cds.json endpoints without a service file, got %v", got)
+	}
+
+	path := filepath.Join(registryDir, "http_bin.json")
+	writeFile(t, path, `{"instances": [
+		{"id": "b", "address": "127.0.0.1", "port": 9002, "weight": 50},
+		{"id": "a", "address": "127.0.0.1", "port": 9001},
+		{"id": "c", "address": "127.0.0.1", "port": 9003, "healthy": false}
+	]}`)
+	p.reload()
+	if got := servedEndpoints(t, p); !reflect.DeepEqual(got, []string{"a@9001/100", "b@9002/50"}) {
+		t.Fatalf("expected the healthy instances, got %v", got)
+	}
+	v1 := servedVersion(t, p, "test-id")
+
+	// A half-written file keeps the previous instances.
+	writeFile(t, path, `{"instances": [{"id": "a"`)
+	p.reload()
+	if got := servedVersion(t, p, "test-id"); got != v1 {
+		t.Fatalf("broken service file changed version %s to %s", v1, got)
+	}
+
+	writeFile(t, path, `{"instances": [
+		{"id": "a", "address": "127.0.0.1", "port": 9001, "weight": 0},
+		{"id": "c", "address": "127.0.0.1", "port": 9003}
+	]}`)
+	p.reload()
+	if got := servedEndpoints(t, p); !reflect.DeepEqual(got, []string{"c@9003/100"}) {
+		t.Fatalf("expected a drained and a recovered instance, got %v", got)
+	}
+}
+
+func TestRegistryAdminAPI(t *testing.T) {
+	p, registryDir := newRegistryPanel(t)
+	writeFile(t, filepath.Join(registryDir, "http_bin.json"),
+		`{"instances": [{"id": "a", "address": "127.0.0.1", "port": 9001}]}`)
+	p.reload()
+	admin := httptest.NewServer(adminHandler(p))
+	defer admin.Close()
+
+	do := func(method string, path string, body string) int {
+		req, err := http.NewRequest(method, admin.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := do(http.MethodPut, "/registry/http_bin/instances/b", `{"address": "127.0.0.1", "port": 9002, "weight": 20}`); code != http.StatusOK {
+		t.Fatalf("register returned %d", code)
+	}
+	if got := servedEndpoints(t, p); !reflect.DeepEqual(got, []string{"a@9001/100", "b@9002/20"}) {
+		t.Fatalf("expected the registered instance to be served, got %v", got)
+	}
+
+	// An in-memory instance overrides the file instance with its ID.
+	if code := do(http.MethodPut, "/registry/http_bin/instances/a", `{"address": "127.0.0.1", "port": 9001, "healthy": false}`); code != http.StatusOK {
+		t.Fatalf("mark unhealthy returned %d", code)
+	}
+	if got := servedEndpoints(t, p); !reflect.DeepEqual(got, []string{"b@9002/20"}) {
+		t.Fatalf("expected the unhealthy instance to be left out, got %v", got)
+	}
+
+	for _, c := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodPut, "/registry/http_bin/instances/c", `{"port": 9003}`, http.StatusBadRequest},
+		{http.MethodDelete, "/registry/http_bin/instances/c", "", http.StatusNotFound},
+		{http.MethodPut, "/registry/http_bin", `{}`, http.StatusNotFound},
+		{http.MethodDelete, "/registry/http_bin/instances/a", "", http.StatusOK},
+		{http.MethodDelete, "/registry/http_bin/instances/b", "", http.StatusOK},
+	} {
+		if code := do(c.method, c.path, c.body); code != c.code {
+			t.Fatalf("%s %s returned %d, want %d", c.method, c.path, code, c.code)
+		}
+	}
+	if got := servedEndpoints(t, p); !reflect.DeepEqual(got, []string{"a@9001/100"}) {
+		t.Fatalf("expected the file instance after deregistering, got %v", got)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/dubbo-go-pixiu/pixiu-api/pkg/xds"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discoverygrpc "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	extensionpb "github.com/envoyproxy/go-control-plane/envoy/service/extension/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/test/v3"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// startXDS serves store on a local port and returns a client connection.
+func startXDS(t *testing.T, store resourceStore, adsOnly bool) *grpc.ClientConn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	grpcServer := newGRPCServer(server.NewServer(ctx, store, &test.Callbacks{}), adsOnly)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		grpcServer.Stop()
+		cancel()
+	})
+	return conn
+}
+
+var testNode = &core.Node{Id: "test-id"}
+
+func TestDeltaSendsOnlyChangedResources(t *testing.T) {
+	dir := testConfigDir(t)
+	p := newPanel(newLinearStore(), nil, dir, "test-id", 3)
+	p.reload()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn := startXDS(t, p.store, false)
+	stream, err := discoverygrpc.NewAggregatedDiscoveryServiceClient(conn).DeltaAggregatedResources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&discoverygrpc.DeltaDiscoveryRequest{Node: testNode, TypeUrl: resource.ExtensionConfigType}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Resources) != 2 {
+		t.Fatalf("expected listeners and clusters on subscribe, got %d resources", len(resp.Resources))
+	}
+	ack := &discoverygrpc.DeltaDiscoveryRequest{TypeUrl: resource.ExtensionConfigType, ResponseNonce: resp.Nonce}
+	if err := stream.Send(ack); err != nil {
+		t.Fatal(err)
+	}
+
+	editCluster(t, dir, "9091")
+	p.reload()
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Resources) != 1 || resp.Resources[0].Name != xds.ClusterType || len(resp.RemovedResources) != 0 {
+		t.Fatalf("expected only %s after a cds.json edit, got %v", xds.ClusterType, resp)
+	}
+
+	// A rollback touches the clusters only, too.
+	ack.ResponseNonce = resp.Nonce
+	if err := stream.Send(ack); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.rollback("test-id", ""); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Resources) != 1 || resp.Resources[0].Name != xds.ClusterType {
+		t.Fatalf("expected only %s after a rollback, got %v", xds.ClusterType, resp)
+	}
+}
+
+func TestSotwResendsAllResources(t *testing.T) {
+	dir := testConfigDir(t)
+	p := newPanel(newSnapshotStore(true), nil, dir, "test-id", 3)
+	p.reload()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn := startXDS(t, p.store, true)
+	stream, err := discoverygrpc.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{xds.ClusterType, xds.ListenerType}
+	req := &discoverygrpc.DiscoveryRequest{Node: testNode, TypeUrl: resource.ExtensionConfigType, ResourceNames: names}
+	if err := stream.Send(req); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.VersionInfo, req.ResponseNonce = resp.VersionInfo, resp.Nonce
+	if err := stream.Send(req); err != nil {
+		t.Fatal(err)
+	}
+
+	editCluster(t, dir, "9091")
+	p.reload()
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Resources) != 2 {
+		t.Fatalf("expected the full set after a cds.json edit, got %d resources", len(resp.Resources))
+	}
+}
+
+func TestADSOnly(t *testing.T) {
+	p, _ := newTestPanel(t)
+	p.reload()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn := startXDS(t, p.store, true)
+	stream, err := extensionpb.NewExtensionConfigDiscoveryServiceClient(conn).StreamExtensionConfigs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = stream.Send(&discoverygrpc.DiscoveryRequest{Node: testNode, TypeUrl: resource.ExtensionConfigType})
+	if _, err := stream.Recv(); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected the extension config service to be unregistered, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"gopkg.in/yaml.v3"
+)
+
+// staticConfFile is a regular Pixiu conf.yaml whose static_resources are
+// served instead of lds.json and cds.json.
+const staticConfFile = "static.yaml"
+
+// pixiuBootstrap is the part of a Pixiu conf.yaml that has a counterpart in
+// the xDS extension resources. Keys without one end up in Extra and are
+// reported as dropped.
+type pixiuBootstrap struct {
+	StaticResources struct {
+		Listeners []staticListener `yaml:"listeners"`
+		Clusters  []staticCluster  `yaml:"clusters"`
+	} `yaml:"static_resources"`
+}
+
+type staticListener struct {
+	Name         string `yaml:"name"`
+	ProtocolType string `yaml:"protocol_type"`
+	Address      struct {
+		SocketAddress staticSocketAddress `yaml:"socket_address"`
+		Name          string              `yaml:"name"`
+	} `yaml:"address"`
+	FilterChains struct {
+		Filters []struct {
+			Name   string                 `yaml:"name"`
+			Config map[string]interface{} `yaml:"config"`
+		} `yaml:"filters"`
+	} `yaml:"filter_chains"`
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+type staticSocketAddress struct {
+	Address      string   `yaml:"address"`
+	Port         int64    `yaml:"port"`
+	ResolverName string   `yaml:"resolver_name"`
+	Domains      []string `yaml:"domains"`
+	CertsDir     string   `yaml:"certs_dir"`
+}
+
+type staticCluster struct {
+	Name         string                 `yaml:"name"`
+	Type         string                 `yaml:"type"`
+	LbPolicy     string                 `yaml:"lb_policy"`
+	Endpoints    []staticEndpoint       `yaml:"endpoints"`
+	HealthChecks []staticHealthCheck    `yaml:"health_checks"`
+	Extra        map[string]interface{} `yaml:",inline"`
+}
+
+type staticEndpoint struct {
+	ID            string                 `yaml:"id"`
+	LegacyID      string                 `yaml:"ID"`
+	Name          string                 `yaml:"name"`
+	SocketAddress staticSocketAddress    `yaml:"socket_address"`
+	Metadata      map[string]string      `yaml:"metadata"`
+	Extra         map[string]interface{} `yaml:",inline"`
+}
+
+type staticHealthCheck struct {
+	Protocol           string `yaml:"protocol"`
+	Timeout            string `yaml:"timeout"`
+	Interval           string `yaml:"interval"`
+	HealthyThreshold   uint32 `yaml:"healthy_threshold"`
+	UnhealthyThreshold uint32 `yaml:"unhealthy_threshold"`
+}
+
+// loadStaticConf translates the listeners, filter chains and clusters of a
+// Pixiu conf.yaml into the xDS extension resources.
+func loadStaticConf(path string) (*pixiuConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var bootstrap pixiuBootstrap
+	if err := yaml.Unmarshal(data, &bootstrap); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	cfg := &pixiuConfig{
+		listeners: &pixiupb.PixiuExtensionListeners{},
+		clusters:  &pixiupb.PixiuExtensionClusters{},
+	}
+	for _, sl := range bootstrap.StaticResources.Listeners {
+		listener, err := sl.translate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: listener %q: %w", path, sl.Name, err)
+		}
+		warnDropped(path, "listener "+sl.Name, sl.Extra)
+		cfg.listeners.Listeners = append(cfg.listeners.Listeners, listener)
+	}
+	for _, sc := range bootstrap.StaticResources.Clusters {
+		cluster, err := sc.translate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: cluster %q: %w", path, sc.Name, err)
+		}
+		warnDropped(path, "cluster "+sc.Name, sc.Extra)
+		for _, se := range sc.Endpoints {
+			warnDropped(path, "endpoint "+se.id()+" of cluster "+sc.Name, se.Extra)
+		}
+		cfg.clusters.Clusters = append(cfg.clusters.Clusters, cluster)
+	}
+	return cfg, nil
+}
+
+func (sl staticListener) translate() (*pixiupb.Listener, error) {
+	protocol := pixiupb.Listener_HTTP
+	if sl.ProtocolType != "" {
+		value, ok := pixiupb.Listener_Protocols_value[strings.ToUpper(sl.ProtocolType)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported protocol_type %q", sl.ProtocolType)
+		}
+		protocol = pixiupb.Listener_Protocols(value)
+	}
+
+	chain := &pixiupb.FilterChain{}
+	for _, filter := range sl.FilterChains.Filters {
+		config, err := structpb.NewStruct(filter.Config)
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %w", filter.Name, err)
+		}
+		chain.Filters = append(chain.Filters, &pixiupb.NetworkFilter{
+			Name:   filter.Name,
+			Config: &pixiupb.NetworkFilter_Struct{Struct: config},
+		})
+	}
+
+	return &pixiupb.Listener{
+		Name:     sl.Name,
+		Protocol: protocol,
+		Address: &pixiupb.Address{
+			SocketAddress: sl.Address.SocketAddress.translate(),
+			Name:          sl.Address.Name,
+		},
+		FilterChain: chain,
+	}, nil
+}
+
+func (sa staticSocketAddress) translate() *pixiupb.SocketAddress {
+	return &pixiupb.SocketAddress{
+		Address:      sa.Address,
+		Port:         sa.Port,
+		ResolverName: sa.ResolverName,
+		Domains:      sa.Domains,
+		CertsDir:     sa.CertsDir,
+	}
+}
+
+func (sc staticCluster) translate() (*pixiupb.Cluster, error) {
+	cluster := &pixiupb.Cluster{
+		Name:    sc.Name,
+		TypeStr: sc.Type,
+		LbStr:   sc.LbPolicy,
+	}
+	for _, se := range sc.Endpoints {
+		cluster.Endpoints = append(cluster.Endpoints, &pixiupb.Endpoint{
+			Id:       se.id(),
+			Name:     se.Name,
+			Address:  se.SocketAddress.translate(),
+			Metadata: se.Metadata,
+		})
+	}
+	for _, shc := range sc.HealthChecks {
+		hc, err := shc.translate()
+		if err != nil {
+			return nil, err
+		}
+		cluster.HealthChecks = append(cluster.HealthChecks, hc)
+	}
+	return cluster, nil
+}
+
+// id accepts both spellings found in Pixiu configs, "id" and "ID".
+func (se staticEndpoint) id() string {
+	if se.ID != "" {
+		return se.ID
+	}
+	return se.LegacyID
+}
+
+func (shc staticHealthCheck) translate() (*pixiupb.HealthCheck, error) {
+	hc := &pixiupb.HealthCheck{}
+	for _, d := range []struct {
+		value  string
+		target **durationpb.Duration
+	}{{shc.Timeout, &hc.Timeout}, {shc.Interval, &hc.Interval}} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("health check: %w", err)
+		}
+		*d.target = durationpb.New(parsed)
+	}
+	if shc.HealthyThreshold > 0 {
+		hc.HealthyThreshold = wrapperspb.UInt32(shc.HealthyThreshold)
+	}
+	if shc.UnhealthyThreshold > 0 {
+		hc.UnhealthyThreshold = wrapperspb.UInt32(shc.UnhealthyThreshold)
+	}
+	switch strings.ToLower(shc.Protocol) {
+	case "", "tcp":
+		hc.HealthChecker = &pixiupb.HealthCheck_TcpHealthCheck_{TcpHealthCheck: &pixiupb.HealthCheck_TcpHealthCheck{}}
+	default:
+		return nil, fmt.Errorf("unsupported health check protocol %q", shc.Protocol)
+	}
+	return hc, nil
+}
+
+// warnDropped logs the keys of a static config that the xDS resources cannot
+// carry, so a migrated config does not silently lose behavior.
+func warnDropped(path string, what string, extra map[string]interface{}) {
+	if len(extra) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	l.Warnf("%s: %s: %s not supported by xDS, dropped", path, what, strings.Join(keys, ", "))
+}
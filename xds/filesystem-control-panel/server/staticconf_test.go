@@ -0,0 +1,89 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+)
+
+const staticDemoDir = "../pixiu/nodes/static-demo"
+
+func TestStaticConfTranslation(t *testing.T) {
+	cfg, err := loadPixiuConfig(staticDemoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	listener := cfg.listeners.Listeners[0]
+	if listener.Protocol != pixiupb.Listener_HTTP || listener.Address.SocketAddress.Port != 8888 {
+		t.Fatalf("unexpected listener %v", listener)
+	}
+	if got := routeClusters(listener); !reflect.DeepEqual(got, []string{"http_bin"}) {
+		t.Fatalf("unexpected route clusters %v", got)
+	}
+
+	cluster := cfg.clusters.Clusters[0]
+	if cluster.LbStr != "RoundRobin" || cluster.TypeStr != "Static" || len(cluster.Endpoints) != 2 {
+		t.Fatalf("unexpected cluster %v", cluster)
+	}
+	if ep := cluster.Endpoints[1]; ep.Id != "backend4" || ep.Address.Port != 8082 {
+		t.Fatalf("unexpected endpoint %v", ep)
+	}
+	hc := cluster.HealthChecks[0]
+	if hc.Timeout.AsDuration() != time.Second || hc.HealthyThreshold.GetValue() != 4 || hc.GetTcpHealthCheck() == nil {
+		t.Fatalf("unexpected health check %v", hc)
+	}
+}
+
+func TestStaticConfPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, staticConfFile), `
+static_resources:
+  listeners:
+    - name: "net/http"
+      protocol_type: "SCTP"
+`)
+	if _, err := loadPixiuConfig(dir); err == nil {
+		t.Fatal("expected an unsupported protocol_type to be rejected")
+	}
+
+	// lds.json next to static.yaml wins; clusters still come from the YAML.
+	writeFile(t, filepath.Join(dir, staticConfFile), `
+static_resources:
+  clusters:
+    - name: "from-yaml"
+`)
+	writeFile(t, filepath.Join(dir, listenersFile), `{"listeners": [{"name": "from-json"}]}`)
+	cfg, err := loadPixiuConfig(dir, staticDemoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.listeners.Listeners[0].Name != "from-json" || cfg.clusters.Clusters[0].Name != "from-yaml" {
+		t.Fatalf("unexpected precedence: %v %v", cfg.listeners, cfg.clusters)
+	}
+}
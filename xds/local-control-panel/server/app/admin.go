@@ -0,0 +1,172 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// adminHandler serves the REST API that changes the served config:
+//
+//	GET    /config                                version, routes and clusters
+//	GET    /nodes                                 connected nodes and their ACK/NACK state
+//	PUT    /clusters/{cluster}                    add or replace a cluster (protojson Cluster)
+//	DELETE /clusters/{cluster}                    remove a cluster no route uses
+//	PUT    /clusters/{cluster}/endpoints/{id}     add or replace an endpoint (protojson Endpoint)
+//	DELETE /clusters/{cluster}/endpoints/{id}     remove an endpoint
+//	PUT    /routes                                add or update the route of a prefix
+//	DELETE /routes?prefix={prefix}                remove a route
+//
+// Every successful change publishes a new snapshot version to all nodes.
+func adminHandler(p *controlPanel, nodes *nodeTracker) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		if !allowGet(w, r) {
+			return
+		}
+		writeConfig(w, p)
+	})
+	mux.HandleFunc("/nodes", func(w http.ResponseWriter, r *http.Request) {
+		if !allowGet(w, r) {
+			return
+		}
+		writeJSON(w, http.StatusOK, nodes.status())
+	})
+	mux.HandleFunc("/clusters/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/clusters/"), "/")
+		for _, part := range parts {
+			if part == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cluster and endpoint names must not be empty"})
+				return
+			}
+		}
+		var err error
+		switch {
+		case len(parts) == 1 && r.Method == http.MethodPut:
+			cluster := &pixiupb.Cluster{}
+			if err = readProto(r, cluster); err == nil {
+				cluster.Name = parts[0]
+				err = p.putCluster(cluster)
+			}
+		case len(parts) == 1 && r.Method == http.MethodDelete:
+			err = p.deleteCluster(parts[0])
+		case len(parts) == 3 && parts[1] == "endpoints" && r.Method == http.MethodPut:
+			endpoint := &pixiupb.Endpoint{}
+			if err = readProto(r, endpoint); err == nil {
+				endpoint.Id = parts[2]
+				err = p.putEndpoint(parts[0], endpoint)
+			}
+		case len(parts) == 3 && parts[1] == "endpoints" && r.Method == http.MethodDelete:
+			err = p.deleteEndpoint(parts[0], parts[2])
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+		writeResult(w, p, err)
+	})
+	mux.HandleFunc("/routes", func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var route routeSpec
+			if err = json.NewDecoder(r.Body).Decode(&route); err == nil && (route.Prefix == "" || route.Cluster == "") {
+				err = errors.New("prefix and cluster are required")
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			err = p.putRoute(route)
+		case http.MethodDelete:
+			err = p.deleteRoute(r.URL.Query().Get("prefix"))
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		writeResult(w, p, err)
+	})
+	return mux
+}
+
+// allowGet answers 405 to anything but a GET of a read-only endpoint.
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return true
+	}
+	w.Header().Set("Allow", http.MethodGet)
+	writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	return false
+}
+
+// readProto decodes a protojson request body into m.
+func readProto(r *http.Request, m proto.Message) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(data, m)
+}
+
+func writeResult(w http.ResponseWriter, p *controlPanel, err error) {
+	switch {
+	case err == nil:
+		writeConfig(w, p)
+	case errors.Is(err, errNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, errConflict):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+}
+
+func writeConfig(w http.ResponseWriter, p *controlPanel) {
+	version, routes, clusters := p.config()
+	out := make([]json.RawMessage, 0, len(clusters))
+	for _, cluster := range clusters {
+		data, err := protojson.Marshal(cluster)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		out = append(out, data)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"version":  version,
+		"routes":   routes,
+		"clusters": out,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,208 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+)
+
+// failingStore rejects every publish while fail is set.
+type failingStore struct {
+	*snapshotStore
+	fail bool
+}
+
+func (s *failingStore) publish(node string, snap *cache.Snapshot) error {
+	if s.fail {
+		return errors.New("publish failed")
+	}
+	return s.snapshotStore.publish(node, snap)
+}
+
+type adminConfig struct {
+	Version  int         `json:"version"`
+	Routes   []routeSpec `json:"routes"`
+	Clusters []struct {
+		Name      string `json:"name"`
+		Endpoints []struct {
+			ID string `json:"id"`
+		} `json:"endpoints"`
+	} `json:"clusters"`
+}
+
+// endpoints lists the served clusters as cluster/endpoint IDs.
+func (c adminConfig) endpoints() []string {
+	out := []string{}
+	for _, cluster := range c.Clusters {
+		for _, e := range cluster.Endpoints {
+			out = append(out, cluster.Name+"/"+e.ID)
+		}
+	}
+	return out
+}
+
+func newTestAdmin(t *testing.T) (*controlPanel, *failingStore, *httptest.Server) {
+	t.Helper()
+	store := &failingStore{snapshotStore: newSnapshotStore(false)}
+	panel := newControlPanel(store)
+	if err := panel.addNode(nodeID); err != nil {
+		t.Fatal(err)
+	}
+	admin := httptest.NewServer(adminHandler(panel, newNodeTracker(false, nil)))
+	t.Cleanup(admin.Close)
+	return panel, store, admin
+}
+
+// call sends a request to the admin API and decodes the config it returns
+// on success.
+func call(t *testing.T, admin *httptest.Server, method string, path string, body string) (int, adminConfig) {
+	t.Helper()
+	req, err := http.NewRequest(method, admin.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var config adminConfig
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, config
+}
+
+func TestAdminChangesConfig(t *testing.T) {
+	panel, _, admin := newTestAdmin(t)
+	_, config := call(t, admin, http.MethodGet, "/config", "")
+	if config.Version != 1 || !reflect.DeepEqual(config.endpoints(), []string{"http_bin/backend"}) {
+		t.Fatalf("expected version 1 with the default cluster, got %+v", config)
+	}
+
+	for i, step := range []struct {
+		method, path, body string
+		endpoints          []string
+		routes             []string
+	}{
+		{http.MethodPut, "/clusters/backup", `{"type_str": "http", "endpoints": [{"id": "b1", "address": {"address": "127.0.0.1", "port": 8081}}]}`,
+			[]string{"http_bin/backend", "backup/b1"}, []string{"/"}},
+		{http.MethodPut, "/clusters/backup/endpoints/b2", `{"address": {"address": "127.0.0.1", "port": 8082}}`,
+			[]string{"http_bin/backend", "backup/b1", "backup/b2"}, []string{"/"}},
+		{http.MethodPut, "/routes", `{"prefix": "/backup", "cluster": "backup"}`,
+			[]string{"http_bin/backend", "backup/b1", "backup/b2"}, []string{"/backup", "/"}},
+		{http.MethodDelete, "/clusters/backup/endpoints/b1", "",
+			[]string{"http_bin/backend", "backup/b2"}, []string{"/backup", "/"}},
+		{http.MethodDelete, "/routes?prefix=/backup", "",
+			[]string{"http_bin/backend", "backup/b2"}, []string{"/"}},
+		{http.MethodDelete, "/clusters/backup", "",
+			[]string{"http_bin/backend"}, []string{"/"}},
+	} {
+		code, config := call(t, admin, step.method, step.path, step.body)
+		if code != http.StatusOK {
+			t.Fatalf("%s %s returned %d", step.method, step.path, code)
+		}
+		if want := i + 2; config.Version != want {
+			t.Fatalf("%s %s: expected version %d, got %d", step.method, step.path, want, config.Version)
+		}
+		if got := config.endpoints(); !reflect.DeepEqual(got, step.endpoints) {
+			t.Fatalf("%s %s: expected endpoints %v, got %v", step.method, step.path, step.endpoints, got)
+		}
+		prefixes := []string{}
+		for _, r := range config.Routes {
+			prefixes = append(prefixes, r.Prefix)
+		}
+		if !reflect.DeepEqual(prefixes, step.routes) {
+			t.Fatalf("%s %s: expected routes %v, got %v", step.method, step.path, step.routes, prefixes)
+		}
+	}
+
+	snap, err := panel.store.(*failingStore).GetSnapshot(nodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := snap.GetVersion(resource.ExtensionConfigType); got != "7" {
+		t.Fatalf("expected the node to be served version 7, got %s", got)
+	}
+}
+
+func TestAdminRejectsChanges(t *testing.T) {
+	panel, _, admin := newTestAdmin(t)
+	for _, c := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodDelete, "/clusters/missing", "", http.StatusNotFound},
+		{http.MethodPut, "/clusters/missing/endpoints/e1", `{"address": {"address": "127.0.0.1", "port": 8081}}`, http.StatusNotFound},
+		{http.MethodDelete, "/clusters/http_bin/endpoints/missing", "", http.StatusNotFound},
+		{http.MethodPut, "/routes", `{"prefix": "/missing", "cluster": "missing"}`, http.StatusNotFound},
+		{http.MethodDelete, "/routes?prefix=/missing", "", http.StatusNotFound},
+		// The default route "/" still points to http_bin.
+		{http.MethodDelete, "/clusters/http_bin", "", http.StatusConflict},
+		{http.MethodPut, "/routes", `{"prefix": "/nocluster"}`, http.StatusBadRequest},
+		{http.MethodPut, "/clusters/bad", `{"endpoints": "not a list"}`, http.StatusBadRequest},
+		{http.MethodPut, "/clusters/http_bin/endpoints/", `{}`, http.StatusBadRequest},
+		{http.MethodGet, "/clusters/http_bin", "", http.StatusNotFound},
+		{http.MethodPost, "/routes", `{}`, http.StatusMethodNotAllowed},
+		{http.MethodPut, "/config", "", http.StatusMethodNotAllowed},
+	} {
+		if code, _ := call(t, admin, c.method, c.path, c.body); code != c.code {
+			t.Fatalf("%s %s: expected %d, got %d", c.method, c.path, c.code, code)
+		}
+	}
+	if version, _, clusters := panel.config(); version != 1 || len(clusters) != 1 {
+		t.Fatalf("rejected changes must not publish, got version %d with %d clusters", version, len(clusters))
+	}
+}
+
+func TestAdminRestoresConfigOnPublishError(t *testing.T) {
+	panel, store, admin := newTestAdmin(t)
+	store.fail = true
+	if code, _ := call(t, admin, http.MethodPut, "/clusters/backup", `{"type_str": "http"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected a failed publish to be reported, got %d", code)
+	}
+	if code, _ := call(t, admin, http.MethodPut, "/clusters/http_bin/endpoints/backend", `{"address": {"address": "127.0.0.1", "port": 9999}}`); code != http.StatusBadRequest {
+		t.Fatalf("expected a failed publish to be reported, got %d", code)
+	}
+
+	version, routes, clusters := panel.config()
+	if version != 1 || len(clusters) != 1 || !reflect.DeepEqual(routes, defaultRoutes) {
+		t.Fatalf("expected the previous config back, got version %d, routes %v, %d clusters", version, routes, len(clusters))
+	}
+	if port := clusters[0].Endpoints[0].Address.Port; port == 9999 {
+		t.Fatal("expected the edited endpoint to be restored")
+	}
+
+	store.fail = false
+	if _, config := call(t, admin, http.MethodPut, "/clusters/backup", `{"type_str": "http"}`); config.Version != 2 {
+		t.Fatalf("expected the next change to publish version 2, got %d", config.Version)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+)
+
+// nodeStatus is what the control panel knows about one connected node.
+type nodeStatus struct {
+	Node     string    `json:"node"`
+	Cluster  string    `json:"cluster,omitempty"`
+	Streams  int       `json:"streams"`
+	LastSeen time.Time `json:"last_seen"`
+
+	// SentVersion is the last version pushed to the node, AckedVersion the
+	// last one it applied. State is "ACK", "NACK" or "PENDING" for the
+	// latest response.
+	SentVersion  string `json:"sent_version,omitempty"`
+	AckedVersion string `json:"acked_version,omitempty"`
+	State        string `json:"state,omitempty"`
+	LastNack     string `json:"last_nack,omitempty"`
+}
+
+type streamState struct {
+	node    string
+	nonce   string
+	version string
+}
+
+// nodeTracker implements the xDS server callbacks and records, per node,
+// which versions were sent and whether the node acknowledged them.
+type nodeTracker struct {
+	mu sync.Mutex
+
+	debug   bool
+	streams map[int64]*streamState
+	nodes   map[string]*nodeStatus
+	// onNode is called the first time a node sends a request.
+	onNode func(node string)
+}
+
+func newNodeTracker(debug bool, onNode func(node string)) *nodeTracker {
+	return &nodeTracker{
+		debug:   debug,
+		streams: map[int64]*streamState{},
+		nodes:   map[string]*nodeStatus{},
+		onNode:  onNode,
+	}
+}
+
+func (t *nodeTracker) status() []nodeStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]nodeStatus, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		out = append(out, *n)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Node < out[j].Node })
+	return out
+}
+
+// request records a request on stream id. A request carrying the nonce of
+// the last response acknowledges it, or rejects it when errorDetail is set.
+func (t *nodeTracker) request(id int64, node *core.Node, nonce string, errorDetail string, rejected bool) {
+	t.mu.Lock()
+	stream, ok := t.streams[id]
+	if !ok {
+		stream = &streamState{}
+		t.streams[id] = stream
+	}
+	newNode := false
+	if stream.node == "" && node.GetId() != "" {
+		stream.node = node.GetId()
+		n, seen := t.nodes[stream.node]
+		if !seen {
+			n = &nodeStatus{Node: stream.node}
+			t.nodes[stream.node] = n
+			newNode = true
+		}
+		n.Cluster = node.GetCluster()
+		n.Streams++
+	}
+	if n, ok := t.nodes[stream.node]; ok {
+		n.LastSeen = time.Now()
+		if nonce != "" && nonce == stream.nonce {
+			if rejected {
+				n.State = "NACK"
+				n.LastNack = errorDetail
+			} else {
+				n.State = "ACK"
+				n.AckedVersion = stream.version
+			}
+		}
+	}
+	name := stream.node
+	t.mu.Unlock()
+
+	if newNode && t.onNode != nil {
+		t.onNode(name)
+	}
+}
+
+func (t *nodeTracker) response(id int64, nonce string, version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stream, ok := t.streams[id]
+	if !ok {
+		return
+	}
+	stream.nonce, stream.version = nonce, version
+	if n, ok := t.nodes[stream.node]; ok {
+		n.SentVersion = version
+		n.State = "PENDING"
+	}
+}
+
+func (t *nodeTracker) closed(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if stream, ok := t.streams[id]; ok {
+		if n, ok := t.nodes[stream.node]; ok {
+			n.Streams--
+		}
+		delete(t.streams, id)
+	}
+}
+
+func (t *nodeTracker) OnStreamOpen(_ context.Context, id int64, typ string) error {
+	if t.debug {
+		l.Debugf("stream %d open for %s", id, typ)
+	}
+	return nil
+}
+
+func (t *nodeTracker) OnStreamClosed(id int64, node *core.Node) {
+	if t.debug {
+		l.Debugf("stream %d of node %s closed", id, node.GetId())
+	}
+	t.closed(id)
+}
+
+func (t *nodeTracker) OnStreamRequest(id int64, req *discovery.DiscoveryRequest) error {
+	t.request(id, req.GetNode(), req.GetResponseNonce(), req.GetErrorDetail().GetMessage(), req.GetErrorDetail() != nil)
+	return nil
+}
+
+func (t *nodeTracker) OnStreamResponse(_ context.Context, id int64, _ *discovery.DiscoveryRequest, resp *discovery.DiscoveryResponse) {
+	t.response(id, resp.GetNonce(), resp.GetVersionInfo())
+}
+
+func (t *nodeTracker) OnDeltaStreamOpen(_ context.Context, id int64, typ string) error {
+	if t.debug {
+		l.Debugf("delta stream %d open for %s", id, typ)
+	}
+	return nil
+}
+
+func (t *nodeTracker) OnDeltaStreamClosed(id int64, node *core.Node) {
+	if t.debug {
+		l.Debugf("delta stream %d of node %s closed", id, node.GetId())
+	}
+	t.closed(id)
+}
+
+func (t *nodeTracker) OnStreamDeltaRequest(id int64, req *discovery.DeltaDiscoveryRequest) error {
+	t.request(id, req.GetNode(), req.GetResponseNonce(), req.GetErrorDetail().GetMessage(), req.GetErrorDetail() != nil)
+	return nil
+}
+
+func (t *nodeTracker) OnStreamDeltaResponse(id int64, _ *discovery.DeltaDiscoveryRequest, resp *discovery.DeltaDiscoveryResponse) {
+	t.response(id, resp.GetNonce(), resp.GetSystemVersionInfo())
+}
+
+func (t *nodeTracker) OnFetchRequest(context.Context, *discovery.DiscoveryRequest) error {
+	return nil
+}
+
+func (t *nodeTracker) OnFetchResponse(*discovery.DiscoveryRequest, *discovery.DiscoveryResponse) {}
@@ -0,0 +1,91 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"google.golang.org/genproto/googleapis/rpc/status"
+)
+
+func TestNodeTrackerAckAndNack(t *testing.T) {
+	var added []string
+	tracker := newNodeTracker(false, func(node string) { added = append(added, node) })
+	ctx := context.Background()
+	node := &core.Node{Id: "pixiu-1", Cluster: "edge"}
+
+	nodeState := func() nodeStatus {
+		t.Helper()
+		status := tracker.status()
+		if len(status) != 1 {
+			t.Fatalf("expected one node, got %+v", status)
+		}
+		return status[0]
+	}
+
+	if err := tracker.OnStreamRequest(1, &discovery.DiscoveryRequest{Node: node}); err != nil {
+		t.Fatal(err)
+	}
+	tracker.OnStreamResponse(ctx, 1, nil, &discovery.DiscoveryResponse{Nonce: "n1", VersionInfo: "1"})
+	if n := nodeState(); n.State != "PENDING" || n.SentVersion != "1" || n.AckedVersion != "" || n.Cluster != "edge" || n.Streams != 1 {
+		t.Fatalf("expected version 1 to be pending, got %+v", n)
+	}
+
+	// A request with an older nonce does not acknowledge the latest response.
+	_ = tracker.OnStreamRequest(1, &discovery.DiscoveryRequest{ResponseNonce: "n0"})
+	if n := nodeState(); n.State != "PENDING" {
+		t.Fatalf("expected a stale nonce to leave the node pending, got %+v", n)
+	}
+
+	_ = tracker.OnStreamRequest(1, &discovery.DiscoveryRequest{ResponseNonce: "n1"})
+	if n := nodeState(); n.State != "ACK" || n.AckedVersion != "1" {
+		t.Fatalf("expected version 1 to be acknowledged, got %+v", n)
+	}
+
+	tracker.OnStreamResponse(ctx, 1, nil, &discovery.DiscoveryResponse{Nonce: "n2", VersionInfo: "2"})
+	_ = tracker.OnStreamRequest(1, &discovery.DiscoveryRequest{
+		ResponseNonce: "n2",
+		ErrorDetail:   &status.Status{Message: "bad cluster"},
+	})
+	if n := nodeState(); n.State != "NACK" || n.LastNack != "bad cluster" || n.SentVersion != "2" || n.AckedVersion != "1" {
+		t.Fatalf("expected version 2 to be rejected with version 1 still applied, got %+v", n)
+	}
+
+	// A second stream of the same node, here a delta one, is counted on it.
+	_ = tracker.OnStreamDeltaRequest(2, &discovery.DeltaDiscoveryRequest{Node: node})
+	tracker.OnStreamDeltaResponse(2, nil, &discovery.DeltaDiscoveryResponse{Nonce: "d1", SystemVersionInfo: "3"})
+	_ = tracker.OnStreamDeltaRequest(2, &discovery.DeltaDiscoveryRequest{ResponseNonce: "d1"})
+	if n := nodeState(); n.Streams != 2 || n.State != "ACK" || n.AckedVersion != "3" {
+		t.Fatalf("expected the delta stream to acknowledge version 3, got %+v", n)
+	}
+
+	tracker.OnStreamClosed(1, node)
+	tracker.OnDeltaStreamClosed(2, node)
+	if n := nodeState(); n.Streams != 0 {
+		t.Fatalf("expected no open streams, got %+v", n)
+	}
+	if len(added) != 1 || added[0] != "pixiu-1" {
+		t.Fatalf("expected onNode once for pixiu-1, got %v", added)
+	}
+}
@@ -20,19 +20,22 @@ package main
 import (
 	"context"
 	"flag"
+	"log"
+	"net/http"
 	"os"
 )
 
 import (
-	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
-	"github.com/envoyproxy/go-control-plane/pkg/test/v3"
 )
 
 var (
-	l      Logger
-	port   = uint(18000)
-	nodeID = "test-id"
+	l         Logger
+	port      = uint(18000)
+	nodeID    = "test-id"
+	adminAddr = flag.String("admin", ":18001", "address of the admin REST API")
+	delta     = flag.Bool("delta", false, "serve from per-node linear caches, so incremental xDS clients only receive changed resources")
+	adsOnly   = flag.Bool("ads", false, "register only the aggregated discovery service")
 )
 
 func init() {
@@ -43,39 +46,33 @@ func init() {
 func main() {
 	flag.Parse()
 
-	// Create a snaphost
-	snaphost := cache.NewSnapshotCache(false, cache.IDHash{}, l)
+	var store resourceStore = newSnapshotStore(*adsOnly)
+	if *delta {
+		store = newLinearStore()
+	}
+	panel := newControlPanel(store)
 
-	go func() {
-		// Create the config that we'll serve to Envoy
-		config := GenerateSnapshotPixiu()
-		if err := config.Consistent(); err != nil {
-			l.Errorf("config inconsistency: %+v\n%+v", config, err)
-			os.Exit(1)
+	// Serve the config to nodeID right away and to any other node once it
+	// connects.
+	if err := panel.addNode(nodeID); err != nil {
+		l.Errorf("config error %q", err)
+		os.Exit(1)
+	}
+	cb := newNodeTracker(l.Debug, func(node string) {
+		if err := panel.addNode(node); err != nil {
+			l.Errorf("serve node %s: %s", node, err)
 		}
-		l.Debugf("will serve config %+v", config)
+	})
 
-		// Add the config to the snaphost
-		if err := snaphost.SetSnapshot(context.Background(), nodeID, config); err != nil {
-			l.Errorf("config error %q for %+v", err, config)
-			os.Exit(1)
+	go func() {
+		log.Printf("admin API listening on %s\n", *adminAddr)
+		if err := http.ListenAndServe(*adminAddr, adminHandler(panel, cb)); err != nil {
+			log.Fatal(err)
 		}
-
-		//time.Sleep(30 * time.Second)
-		//config2 := GenerateSnapshotPixiu2()
-		//if err := config2.Consistent(); err != nil {
-		//	l.Errorf("config inconsistency: %+v\n%+v", config, err)
-		//	os.Exit(1)
-		//}
-		//if err := snaphost.SetSnapshot(context.Background(), nodeID, config2); err != nil {
-		//	l.Errorf("config error %q for %+v", err, config)
-		//	os.Exit(1)
-		//}
 	}()
 
 	// Run the xDS server
 	ctx := context.Background()
-	cb := &test.Callbacks{Debug: l.Debug}
-	srv := server.NewServer(ctx, snaphost, cb)
-	RunServer(ctx, srv, port)
+	srv := server.NewServer(ctx, store, cb)
+	RunServer(ctx, srv, port, *adsOnly)
 }
@@ -0,0 +1,244 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+import (
+	pixiupb "github.com/dubbo-go-pixiu/pixiu-api/pkg/xds/model"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	errNotFound = errors.New("not found")
+	errConflict = errors.New("conflict")
+)
+
+// controlPanel holds the routes and clusters served to every node and
+// publishes a new snapshot version after each change.
+type controlPanel struct {
+	mu sync.Mutex
+
+	store    resourceStore
+	version  int
+	routes   []routeSpec
+	clusters []*pixiupb.Cluster
+	nodes    map[string]bool
+}
+
+func newControlPanel(store resourceStore) *controlPanel {
+	return &controlPanel{
+		store:    store,
+		routes:   append([]routeSpec(nil), defaultRoutes...),
+		clusters: makeClusters().Clusters,
+		nodes:    map[string]bool{},
+	}
+}
+
+// addNode starts serving node with the current snapshot.
+func (p *controlPanel) addNode(node string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.nodes[node] {
+		return nil
+	}
+	p.nodes[node] = true
+	if p.version == 0 {
+		return p.publishLocked()
+	}
+	snap, err := p.snapshotLocked(strconv.Itoa(p.version))
+	if err != nil {
+		return err
+	}
+	return p.store.publish(node, snap)
+}
+
+func (p *controlPanel) snapshotLocked(version string) (*cache.Snapshot, error) {
+	snap, err := GenerateSnapshotPixiu(version, p.routes, &pixiupb.PixiuExtensionClusters{Clusters: p.clusters})
+	if err != nil {
+		return nil, err
+	}
+	if err := snap.Consistent(); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// publishLocked pushes the current config to every node under a new version.
+func (p *controlPanel) publishLocked() error {
+	version := strconv.Itoa(p.version + 1)
+	snap, err := p.snapshotLocked(version)
+	if err != nil {
+		return err
+	}
+	for node := range p.nodes {
+		if err := p.store.publish(node, snap); err != nil {
+			return err
+		}
+	}
+	p.version++
+	l.Infof("published version %s to %d nodes", version, len(p.nodes))
+	return nil
+}
+
+// update applies change and publishes the result. The previous config is
+// restored if change fails or the result cannot be published.
+func (p *controlPanel) update(change func() error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	routes := append([]routeSpec(nil), p.routes...)
+	clusters := make([]*pixiupb.Cluster, len(p.clusters))
+	for i, c := range p.clusters {
+		clusters[i] = proto.Clone(c).(*pixiupb.Cluster)
+	}
+	err := change()
+	if err == nil {
+		err = p.publishLocked()
+	}
+	if err != nil {
+		p.routes, p.clusters = routes, clusters
+	}
+	return err
+}
+
+func (p *controlPanel) clusterLocked(name string) (int, *pixiupb.Cluster) {
+	for i, c := range p.clusters {
+		if c.Name == name {
+			return i, c
+		}
+	}
+	return -1, nil
+}
+
+// putCluster adds cluster or replaces the cluster of the same name.
+func (p *controlPanel) putCluster(cluster *pixiupb.Cluster) error {
+	return p.update(func() error {
+		if i, _ := p.clusterLocked(cluster.Name); i >= 0 {
+			p.clusters[i] = cluster
+		} else {
+			p.clusters = append(p.clusters, cluster)
+		}
+		return nil
+	})
+}
+
+// deleteCluster removes a cluster that no route points to.
+func (p *controlPanel) deleteCluster(name string) error {
+	return p.update(func() error {
+		i, _ := p.clusterLocked(name)
+		if i < 0 {
+			return fmt.Errorf("cluster %q: %w", name, errNotFound)
+		}
+		for _, r := range p.routes {
+			if r.Cluster == name {
+				return fmt.Errorf("cluster %q is used by route %q: %w", name, r.Prefix, errConflict)
+			}
+		}
+		p.clusters = append(p.clusters[:i], p.clusters[i+1:]...)
+		return nil
+	})
+}
+
+// putEndpoint adds endpoint to a cluster or replaces the one with its ID.
+func (p *controlPanel) putEndpoint(clusterName string, endpoint *pixiupb.Endpoint) error {
+	return p.update(func() error {
+		_, cluster := p.clusterLocked(clusterName)
+		if cluster == nil {
+			return fmt.Errorf("cluster %q: %w", clusterName, errNotFound)
+		}
+		for i, e := range cluster.Endpoints {
+			if e.Id == endpoint.Id {
+				cluster.Endpoints[i] = endpoint
+				return nil
+			}
+		}
+		cluster.Endpoints = append(cluster.Endpoints, endpoint)
+		return nil
+	})
+}
+
+func (p *controlPanel) deleteEndpoint(clusterName string, id string) error {
+	return p.update(func() error {
+		_, cluster := p.clusterLocked(clusterName)
+		if cluster == nil {
+			return fmt.Errorf("cluster %q: %w", clusterName, errNotFound)
+		}
+		for i, e := range cluster.Endpoints {
+			if e.Id == id {
+				cluster.Endpoints = append(cluster.Endpoints[:i], cluster.Endpoints[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("endpoint %q of cluster %q: %w", id, clusterName, errNotFound)
+	})
+}
+
+// putRoute adds or updates the route of r.Prefix. Routes are kept longest
+// prefix first, so a new specific route is not shadowed by "/".
+func (p *controlPanel) putRoute(r routeSpec) error {
+	return p.update(func() error {
+		if _, cluster := p.clusterLocked(r.Cluster); cluster == nil {
+			return fmt.Errorf("cluster %q: %w", r.Cluster, errNotFound)
+		}
+		replaced := false
+		for i := range p.routes {
+			if p.routes[i].Prefix == r.Prefix {
+				p.routes[i] = r
+				replaced = true
+			}
+		}
+		if !replaced {
+			p.routes = append(p.routes, r)
+		}
+		sort.SliceStable(p.routes, func(i, j int) bool { return len(p.routes[i].Prefix) > len(p.routes[j].Prefix) })
+		return nil
+	})
+}
+
+func (p *controlPanel) deleteRoute(prefix string) error {
+	return p.update(func() error {
+		for i, r := range p.routes {
+			if r.Prefix == prefix {
+				p.routes = append(p.routes[:i], p.routes[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("route %q: %w", prefix, errNotFound)
+	})
+}
+
+// config returns the served version, routes and clusters.
+func (p *controlPanel) config() (int, []routeSpec, []*pixiupb.Cluster) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clusters := make([]*pixiupb.Cluster, len(p.clusters))
+	for i, c := range p.clusters {
+		clusters[i] = proto.Clone(c).(*pixiupb.Cluster)
+	}
+	return p.version, append([]routeSpec(nil), p.routes...), clusters
+}
@@ -28,9 +28,9 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/structpb"
-	structpb2 "google.golang.org/protobuf/types/known/structpb"
 )
 
 var httpManagerConfigYaml = `
@@ -46,7 +46,46 @@ http_filters:
     config:
 `
 
-func makeHttpFilter() *pixiupb.FilterChain {
+// routeSpec is one route of the http connection manager, matched by path
+// prefix.
+type routeSpec struct {
+	Prefix                      string `json:"prefix"`
+	Cluster                     string `json:"cluster"`
+	ClusterNotFoundResponseCode int    `json:"cluster_not_found_response_code,omitempty"`
+}
+
+var defaultRoutes = []routeSpec{
+	{Prefix: "/", Cluster: "http_bin", ClusterNotFoundResponseCode: 505},
+}
+
+func makeHttpFilter(routes []routeSpec) (*pixiupb.FilterChain, error) {
+	routeConfigs := make([]interface{}, 0, len(routes))
+	for _, r := range routes {
+		route := map[string]interface{}{"cluster": r.Cluster}
+		if r.ClusterNotFoundResponseCode != 0 {
+			route["cluster_not_found_response_code"] = r.ClusterNotFoundResponseCode
+		}
+		routeConfigs = append(routeConfigs, map[string]interface{}{
+			"match": map[string]interface{}{
+				"prefix": r.Prefix,
+			},
+			"route": route,
+		})
+	}
+	v, err := structpb.NewStruct(map[string]interface{}{
+		"route_config": map[string]interface{}{
+			"routes": routeConfigs,
+		},
+		"http_filters": []interface{}{
+			map[string]interface{}{
+				"name":   "dgp.filter.http.httpproxy",
+				"config": nil,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
 	return &pixiupb.FilterChain{
 		Filters: []*pixiupb.NetworkFilter{
 			{
@@ -54,40 +93,17 @@ func makeHttpFilter() *pixiupb.FilterChain {
 				//Config: &pixiupb.Filter_Yaml{Yaml: &pixiupb.Config{
 				//	Content: httpManagerConfigYaml,
 				//}},
-				Config: &pixiupb.NetworkFilter_Struct{
-					Struct: func() *structpb.Struct {
-						v, err := structpb2.NewStruct(map[string]interface{}{
-							"route_config": map[string]interface{}{
-								"routes": []interface{}{
-									map[string]interface{}{
-										"match": map[string]interface{}{
-											"prefix": "/",
-										},
-										"route": map[string]interface{}{
-											"cluster":                         "http_bin",
-											"cluster_not_found_response_code": 505,
-										},
-									},
-								},
-							},
-							"http_filters": []interface{}{
-								map[string]interface{}{
-									"name":   "dgp.filter.http.httpproxy",
-									"config": nil,
-								},
-							},
-						})
-						if err != nil {
-							panic(err)
-						}
-						return v
-					}(),
-				},
+				Config: &pixiupb.NetworkFilter_Struct{Struct: v},
 			},
 		},
-	}
+	}, nil
 }
-func makeListeners() *pixiupb.PixiuExtensionListeners {
+
+func makeListeners(routes []routeSpec) (*pixiupb.PixiuExtensionListeners, error) {
+	filterChain, err := makeHttpFilter(routes)
+	if err != nil {
+		return nil, err
+	}
 	return &pixiupb.PixiuExtensionListeners{
 		Listeners: []*pixiupb.Listener{
 			{
@@ -99,10 +115,10 @@ func makeListeners() *pixiupb.PixiuExtensionListeners {
 					},
 					Name: "http_8888",
 				},
-				FilterChain: makeHttpFilter(),
+				FilterChain: filterChain,
 			},
 		},
-	}
+	}, nil
 }
 
 func makeClusters() *pixiupb.PixiuExtensionClusters {
@@ -126,10 +142,22 @@ func makeClusters() *pixiupb.PixiuExtensionClusters {
 	}
 }
 
-func GenerateSnapshotPixiu() *cache.Snapshot {
-	ldsResource, _ := anypb.New(makeListeners())
-	cdsResource, _ := anypb.New(makeClusters())
-	snap, _ := cache.NewSnapshot("2",
+// GenerateSnapshotPixiu builds the snapshot for the given routes and
+// clusters.
+func GenerateSnapshotPixiu(version string, routes []routeSpec, clusters *pixiupb.PixiuExtensionClusters) (*cache.Snapshot, error) {
+	listeners, err := makeListeners(routes)
+	if err != nil {
+		return nil, err
+	}
+	ldsResource, err := marshalAny(listeners)
+	if err != nil {
+		return nil, err
+	}
+	cdsResource, err := marshalAny(clusters)
+	if err != nil {
+		return nil, err
+	}
+	return cache.NewSnapshot(version,
 		map[resource.Type][]types.Resource{
 			resource.ExtensionConfigType: {
 				&core.TypedExtensionConfig{
@@ -143,5 +171,14 @@ func GenerateSnapshotPixiu() *cache.Snapshot {
 			},
 		},
 	)
-	return snap
+}
+
+// marshalAny wraps m like anypb.New, but with deterministic map ordering so an
+// unchanged config yields identical bytes and is not resent as a change.
+func marshalAny(m proto.Message) (*anypb.Any, error) {
+	out := &anypb.Any{}
+	if err := anypb.MarshalFrom(out, m, proto.MarshalOptions{Deterministic: true}); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
@@ -47,9 +47,12 @@ const (
 	grpcMaxConcurrentStreams = 1000000
 )
 
-func registerServer(grpcServer *grpc.Server, server server.Server) {
+func registerServer(grpcServer *grpc.Server, server server.Server, adsOnly bool) {
 	// register services
 	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(grpcServer, server)
+	if adsOnly {
+		return
+	}
 	endpointservice.RegisterEndpointDiscoveryServiceServer(grpcServer, server)
 	clusterservice.RegisterClusterDiscoveryServiceServer(grpcServer, server)
 	routeservice.RegisterRouteDiscoveryServiceServer(grpcServer, server)
@@ -59,8 +62,21 @@ func registerServer(grpcServer *grpc.Server, server server.Server) {
 	extensionpb.RegisterExtensionConfigDiscoveryServiceServer(grpcServer, server)
 }
 
-// RunServer starts an xDS server at the given port.
-func RunServer(ctx context.Context, srv server.Server, port uint) {
+// RunServer starts an xDS server at the given port. With adsOnly set only the
+// aggregated discovery service is registered.
+func RunServer(ctx context.Context, srv server.Server, port uint, adsOnly bool) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("management server listening on %d\n", port)
+	if err = newGRPCServer(srv, adsOnly).Serve(lis); err != nil {
+		log.Println(err)
+	}
+}
+
+func newGRPCServer(srv server.Server, adsOnly bool) *grpc.Server {
 	// gRPC golang library sets a very small upper bound for the number gRPC/h2
 	// streams over a single TCP connection. If a proxy multiplexes requests over
 	// a single connection to the management server, then it might lead to
@@ -78,16 +94,6 @@ func RunServer(ctx context.Context, srv server.Server, port uint) {
 		}),
 	)
 	grpcServer := grpc.NewServer(grpcOptions...)
-
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	registerServer(grpcServer, srv)
-
-	log.Printf("management server listening on %d\n", port)
-	if err = grpcServer.Serve(lis); err != nil {
-		log.Println(err)
-	}
+	registerServer(grpcServer, srv, adsOnly)
+	return grpcServer
 }
@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+import (
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// resourceStore is the cache the control panel publishes node snapshots to
+// and the xDS server answers from.
+type resourceStore interface {
+	cache.Cache
+	publish(node string, snap *cache.Snapshot) error
+}
+
+// snapshotStore serves every node a full snapshot. Any change resends all
+// resources to state-of-the-world clients.
+type snapshotStore struct {
+	cache.SnapshotCache
+}
+
+func newSnapshotStore(ads bool) *snapshotStore {
+	return &snapshotStore{cache.NewSnapshotCache(ads, cache.IDHash{}, l)}
+}
+
+func (s *snapshotStore) publish(node string, snap *cache.Snapshot) error {
+	return s.SetSnapshot(context.Background(), node, snap)
+}
+
+// linearStore keeps a linear cache per node and hands each watch to the
+// cache of the requesting node, muxing by node ID. Publishing only touches
+// the resources that differ from what the node has, so incremental (delta)
+// clients receive just the changed ones.
+//
+// The unit of change is a whole resource, and Pixiu's model has only two per
+// node: the PixiuExtensionClusters and PixiuExtensionListeners blobs. Editing
+// one cluster therefore still resends every cluster. They cannot be split
+// into one resource per cluster either, because Pixiu applies each delta
+// update as the complete cluster set and removes the clusters it lacks.
+type linearStore struct {
+	mu     sync.Mutex
+	caches map[string]*cache.LinearCache
+}
+
+func newLinearStore() *linearStore {
+	return &linearStore{caches: map[string]*cache.LinearCache{}}
+}
+
+// node returns the cache of a node, creating an empty one for nodes that
+// connect before their config has been published.
+func (s *linearStore) node(id string) *cache.LinearCache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.caches[id]
+	if !ok {
+		c = cache.NewLinearCache(resource.ExtensionConfigType, cache.WithLogger(l))
+		s.caches[id] = c
+	}
+	return c
+}
+
+func (s *linearStore) publish(node string, snap *cache.Snapshot) error {
+	c := s.node(node)
+	current := c.GetResources()
+	next := snap.GetResources(resource.ExtensionConfigType)
+
+	changed := map[string]types.Resource{}
+	for name, res := range next {
+		if old, ok := current[name]; !ok || !proto.Equal(old, res) {
+			changed[name] = res
+		}
+	}
+	var removed []string
+	for name := range current {
+		if _, ok := next[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	if len(changed) == 0 && len(removed) == 0 {
+		return nil
+	}
+	return c.UpdateResources(changed, removed)
+}
+
+func (s *linearStore) CreateWatch(req *cache.Request, state stream.StreamState, value chan cache.Response) func() {
+	return s.node(req.GetNode().GetId()).CreateWatch(req, state, value)
+}
+
+func (s *linearStore) CreateDeltaWatch(req *cache.DeltaRequest, state stream.StreamState, value chan cache.DeltaResponse) func() {
+	return s.node(req.GetNode().GetId()).CreateDeltaWatch(req, state, value)
+}
+
+func (s *linearStore) Fetch(ctx context.Context, req *cache.Request) (cache.Response, error) {
+	return s.node(req.GetNode().GetId()).Fetch(ctx, req)
+}